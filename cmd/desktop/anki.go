@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/anki"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// exportAnki asks for a file and writes the starred articles to it as an
+// Anki import file, only those tagged tag when it is not empty
+func exportAnki(w fyne.Window, settings *state.Store, db *search.DB, tag string) {
+	notes, err := anki.Collect(settings, db, tag)
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	if len(notes) == 0 {
+		dialog.ShowInformation("Export to Anki", "There are no starred articles to export.", w)
+		return
+	}
+
+	defaultName := "sanskrit-starred.txt"
+	if tag != "" {
+		defaultName = "sanskrit-" + strings.Join(strings.Fields(tag), "-") + ".txt"
+	}
+
+	fd := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		if writer == nil {
+			return // User cancelled
+		}
+		defer writer.Close()
+
+		if err := anki.WriteTSV(writer, notes); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to export: %v", err), w)
+			return
+		}
+		dialog.ShowInformation("Export to Anki",
+			fmt.Sprintf("Exported %d notes.\nIn Anki choose File → Import and pick %s.", len(notes), writer.URI().Name()), w)
+	}, w)
+
+	fd.SetFileName(defaultName)
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".txt"}))
+	fd.Show()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+)
+
+// articleView renders a dictionary article with headwords, Sanskrit spans,
+// numbered senses and indented sub-senses.
+// RichText cannot be selected, so a right-click menu offers copying and a
+// plain-text selection mode backed by a selectable Label.
+// Cross-references are shown as links when onLink is set, and known
+// abbreviations explain themselves on hover. A page reference set with
+// SetPage opens the scan of the printed page.
+type articleView struct {
+	widget.BaseWidget
+	blocks    []article.Block
+	plain     string
+	onLink    func(word string)
+	page      string
+	onPage    func()
+	selecting bool
+	holder    *fyne.Container
+}
+
+func newArticleView(content string, abbreviations map[string]string, onLink func(word string)) *articleView {
+	blocks := article.Parse(content)
+	article.MarkAbbreviations(blocks, abbreviations)
+	v := &articleView{
+		blocks: blocks,
+		plain:  article.PlainText(blocks),
+		onLink: onLink,
+	}
+	v.ExtendBaseWidget(v)
+	return v
+}
+
+func (v *articleView) CreateRenderer() fyne.WidgetRenderer {
+	v.holder = container.NewStack(v.buildFormatted())
+	return widget.NewSimpleRenderer(v.holder)
+}
+
+// SetPage shows the printed page reference ("p. 510, col. 2") under the
+// article; tapping it calls open.
+func (v *articleView) SetPage(page string, open func()) {
+	v.page = page
+	v.onPage = open
+	if v.holder != nil && !v.selecting {
+		v.holder.RemoveAll()
+		v.holder.Add(v.buildFormatted())
+		v.holder.Refresh()
+	}
+}
+
+// buildFormatted lays out one RichText per block, indented by sense depth
+func (v *articleView) buildFormatted() fyne.CanvasObject {
+	box := container.NewVBox()
+	for _, b := range v.blocks {
+		rt := widget.NewRichText(blockSegments(b, v.onLink)...)
+		rt.Wrapping = fyne.TextWrapWord
+		box.Add(container.New(&indentLayout{level: b.Indent}, rt))
+	}
+	if v.page != "" {
+		pageBtn := widget.NewButtonWithIcon(v.page, theme.FileImageIcon(), v.onPage)
+		pageBtn.Importance = widget.LowImportance
+		box.Add(container.NewHBox(layout.NewSpacer(), pageBtn))
+	}
+	return box
+}
+
+// buildSelectable shows the article as plain selectable text
+func (v *articleView) buildSelectable() fyne.CanvasObject {
+	label := widget.NewLabel(v.plain)
+	label.Wrapping = fyne.TextWrapWord
+	label.Selectable = true
+
+	doneBtn := widget.NewButtonWithIcon("Formatted", theme.VisibilityIcon(), func() {
+		v.setSelecting(false)
+	})
+	doneBtn.Importance = widget.LowImportance
+	return container.NewBorder(container.NewHBox(layout.NewSpacer(), doneBtn), nil, nil, nil, label)
+}
+
+func (v *articleView) setSelecting(selecting bool) {
+	if v.holder == nil || v.selecting == selecting {
+		return
+	}
+	v.selecting = selecting
+	v.holder.RemoveAll()
+	if selecting {
+		v.holder.Add(v.buildSelectable())
+	} else {
+		v.holder.Add(v.buildFormatted())
+	}
+	v.holder.Refresh()
+}
+
+// TappedSecondary shows the copy/select context menu
+func (v *articleView) TappedSecondary(ev *fyne.PointEvent) {
+	c := fyne.CurrentApp().Driver().CanvasForObject(v)
+	if c == nil {
+		return
+	}
+	menu := fyne.NewMenu("",
+		fyne.NewMenuItem("Copy Article", func() {
+			fyne.CurrentApp().Clipboard().SetContent(v.plain)
+		}),
+		fyne.NewMenuItem("Select Text", func() {
+			v.setSelecting(true)
+		}),
+	)
+	if v.onPage != nil {
+		menu.Items = append(menu.Items, fyne.NewMenuItem("Open Page Scan", v.onPage))
+	}
+	widget.ShowPopUpMenuAtPosition(menu, c, ev.AbsolutePosition)
+}
+
+// blockSegments converts a parsed block into rich text segments.
+// The bundled Noto face has no bold or italic cut, so emphasis is carried
+// by size and colour as well as by the text style.
+func blockSegments(b article.Block, onLink func(word string)) []widget.RichTextSegment {
+	var segs []widget.RichTextSegment
+	if label := b.Label(); label != "" {
+		segs = append(segs, &widget.TextSegment{
+			Text: label + " ",
+			Style: widget.RichTextStyle{
+				Inline:    true,
+				ColorName: colorNameEmphasis,
+				TextStyle: fyne.TextStyle{Bold: true},
+			},
+		})
+	}
+	for _, s := range b.Spans {
+		if s.Link != "" && onLink != nil {
+			target := s.Link
+			segs = append(segs, &widget.HyperlinkSegment{
+				Text:     s.Text,
+				OnTapped: func() { onLink(target) },
+			})
+			continue
+		}
+		if s.Abbr != "" {
+			segs = append(segs, &abbrSegment{
+				TextSegment: widget.TextSegment{Text: s.Text, Style: spanStyle(s)},
+				Meaning:     s.Abbr,
+			})
+			continue
+		}
+		segs = append(segs, &widget.TextSegment{Text: s.Text, Style: spanStyle(s)})
+	}
+	return segs
+}
+
+// spanStyle maps a parsed span to a rich text style
+func spanStyle(s article.Span) widget.RichTextStyle {
+	style := widget.RichTextStyle{Inline: true}
+	switch {
+	case s.Headword:
+		style.SizeName = theme.SizeNameSubHeadingText
+		style.ColorName = colorNameEmphasis
+	case s.Bold:
+		style.ColorName = colorNameEmphasis
+	case s.Sanskrit || s.Italic:
+		style.ColorName = colorNameSanskrit
+	}
+	style.TextStyle = fyne.TextStyle{Bold: s.Bold, Italic: s.Italic}
+	return style
+}
+
+// indentLayout offsets its single child by a zoom-aware amount per level
+type indentLayout struct {
+	level int
+}
+
+func (l *indentLayout) offset() float32 {
+	return float32(l.level) * theme.Size(theme.SizeNameText) * 1.5
+}
+
+func (l *indentLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	off := l.offset()
+	for _, o := range objects {
+		o.Move(fyne.NewPos(off, 0))
+		o.Resize(fyne.NewSize(size.Width-off, size.Height))
+	}
+}
+
+func (l *indentLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
+	var min fyne.Size
+	for _, o := range objects {
+		min = min.Max(o.MinSize())
+	}
+	return fyne.NewSize(min.Width+l.offset(), min.Height)
+}
@@ -0,0 +1,66 @@
+package main
+
+import "strings"
+
+// Compare mode shows two to four dictionaries side by side
+const (
+	minCompareColumns = 2
+	maxCompareColumns = 4
+)
+
+// parseCompareDicts reads the saved column set ("mw,ap90,pwg")
+func parseCompareDicts(saved string) []string {
+	var codes []string
+	for _, code := range strings.Split(saved, ",") {
+		if code = strings.TrimSpace(code); code != "" && len(codes) < maxCompareColumns {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) < minCompareColumns {
+		return nil
+	}
+	return codes
+}
+
+// compareColumns picks the dictionaries to show side by side: the saved set
+// if there is one, otherwise up to maxCompareColumns dictionaries that have
+// an entry for the word, in display order, topped up from the display order
+// to minCompareColumns. It returns nil if fewer dictionaries are installed,
+// leaving nothing to compare.
+func compareColumns(saved []string, gr *GroupedResult, dictOrder []string) []string {
+	if len(saved) >= minCompareColumns {
+		return saved
+	}
+
+	var codes []string
+	used := make(map[string]bool)
+	add := func(code string) {
+		if !used[code] && len(codes) < maxCompareColumns {
+			used[code] = true
+			codes = append(codes, code)
+		}
+	}
+	for _, e := range gr.Entries {
+		add(e.DictCode)
+	}
+	for _, code := range dictOrder {
+		if len(codes) >= minCompareColumns {
+			break
+		}
+		add(code)
+	}
+	if len(codes) < minCompareColumns {
+		return nil
+	}
+	return codes
+}
+
+// findEntry returns the group's entry for a dictionary, or nil
+func findEntry(gr *GroupedResult, dictCode string) *DictEntry {
+	for i := range gr.Entries {
+		if gr.Entries[i].DictCode == dictCode {
+			return &gr.Entries[i]
+		}
+	}
+	return nil
+}
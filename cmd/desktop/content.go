@@ -1,31 +1,23 @@
 package main
 
 import (
-	"strings"
-
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/widget"
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/dictdata"
 )
 
-// cleanHTML removes HTML tags and converts breaks to newlines
+// cleanHTML removes markup and converts breaks and senses to plain-text paragraphs
 func cleanHTML(content string) string {
-	result := content
-	result = strings.ReplaceAll(result, "<BR>", "\n\n")
-	result = strings.ReplaceAll(result, "<br>", "\n\n")
-	result = strings.ReplaceAll(result, "<P>", "\n\n")
-	result = strings.ReplaceAll(result, "<p>", "\n\n")
-	// Remove other HTML tags
-	for _, tag := range []string{"<b>", "</b>", "<i>", "</i>", "<B>", "</B>", "<I>", "</I>"} {
-		result = strings.ReplaceAll(result, tag, "")
-	}
-	return result
+	return article.PlainText(article.Parse(content))
 }
 
-// createSelectableArticleContent creates a selectable Label for article content
-func createSelectableArticleContent(content string) fyne.CanvasObject {
-	cleanContent := cleanHTML(content)
-	label := widget.NewLabel(cleanContent)
-	label.Wrapping = fyne.TextWrapWord
-	label.Selectable = true
-	return label
+// createArticleContent renders article content with rich formatting.
+// Abbreviations from the dictionary's table get hover tooltips; pass an empty
+// dictCode to skip them. onLink is called with the target headword when a
+// cross-reference is tapped; pass nil to render references as plain text.
+func createArticleContent(content, dictCode string, onLink func(word string)) *articleView {
+	var abbreviations map[string]string
+	if dictCode != "" {
+		abbreviations = dictdata.Abbreviations(dictCode)
+	}
+	return newArticleView(content, abbreviations, onLink)
 }
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/download"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// latestManifest fetches the manifest of the published data, if this
+// version of the app can read the data
+func latestManifest(ctx context.Context) (*download.Manifest, error) {
+	m, err := download.FetchManifest(ctx, *manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	if !m.Supports(Version) {
+		return nil, fmt.Errorf("%w (version %s or later)", download.ErrAppTooOld, m.MinAppVersion)
+	}
+	return m, nil
+}
+
+// downloadDatabase downloads the chosen dictionaries of a manifest, or
+// its whole database if none are chosen. The download is installed by
+// download.InstallPending.
+func downloadDatabase(ctx context.Context, m *download.Manifest, dictCodes []string, progress download.ProgressFunc) error {
+	if len(dictCodes) == 0 {
+		return download.Download(ctx, m, progress)
+	}
+	return download.DownloadDicts(ctx, m, dictCodes, progress)
+}
+
+// installLocal verifies the dictionary data at path, a database file or a
+// folder holding one, and prepares it to be installed by
+// download.InstallPending
+func installLocal(ctx context.Context, path string, progress download.ProgressFunc) error {
+	src, err := download.FindLocal(path)
+	if err != nil {
+		return err
+	}
+	if !src.Manifest.Supports(Version) {
+		return fmt.Errorf("%w (version %s or later)", download.ErrAppTooOld, src.Manifest.MinAppVersion)
+	}
+	return src.Install(ctx, progress)
+}
+
+// installPending installs a completed download with
+// download.InstallPending. Data from before articles had stable IDs
+// numbered them otherwise, so what every profile keeps by article ID, such
+// as stars and notes, is moved to the IDs of the new data.
+func installPending(settings *state.Store) (bool, error) {
+	stores, closeStores := profileStores(settings)
+	defer closeStores()
+	moved, err := download.MovedArticles(keptArticles(stores))
+	if err != nil {
+		log.Printf("Warning: Could not find starred articles in the new data: %v", err)
+	}
+	installed, err := download.InstallPending()
+	if installed {
+		remapArticles(stores, moved)
+	}
+	return installed, err
+}
+
+// addDict adds a dictionary to the open database with download.AddDict,
+// moving what every profile keeps for its articles to their new IDs like
+// installPending
+func addDict(ctx context.Context, db *search.DB, settings *state.Store, latest *download.Manifest, dictCode string, progress download.ProgressFunc) error {
+	stores, closeStores := profileStores(settings)
+	defer closeStores()
+	moved, err := download.AddDict(ctx, db, latest, dictCode, keptArticles(stores), progress)
+	remapArticles(stores, moved)
+	return err
+}
+
+// profileStores opens the state of every profile, using settings for its
+// own, and returns a function closing the ones it opened
+func profileStores(settings *state.Store) ([]*state.Store, func()) {
+	profiles, err := state.Profiles()
+	if err != nil {
+		log.Printf("Warning: Could not list profiles: %v", err)
+	}
+	var stores, opened []*state.Store
+	if settings != nil {
+		stores = append(stores, settings)
+	}
+	for _, name := range profiles {
+		if settings != nil && strings.EqualFold(name, settings.Profile()) {
+			continue
+		}
+		store, err := state.OpenProfile(name)
+		if err != nil {
+			log.Printf("Warning: Could not open profile %s: %v", name, err)
+			continue
+		}
+		stores = append(stores, store)
+		opened = append(opened, store)
+	}
+	return stores, func() {
+		for _, store := range opened {
+			store.Close()
+		}
+	}
+}
+
+// keptArticles returns the IDs of the articles that the profiles keep
+// anything for
+func keptArticles(stores []*state.Store) []int64 {
+	var ids []int64
+	for _, store := range stores {
+		kept, err := store.ArticleIDs()
+		if err != nil {
+			log.Printf("Warning: Could not read articles of profile %s: %v", store.Profile(), err)
+			continue
+		}
+		ids = append(ids, kept...)
+	}
+	return ids
+}
+
+// remapArticles moves what the profiles keep for articles to their new IDs
+func remapArticles(stores []*state.Store, moved map[int64]int64) {
+	for _, store := range stores {
+		if err := store.RemapArticles(moved); err != nil {
+			log.Printf("Warning: Could not move the articles of profile %s to the new data: %v", store.Profile(), err)
+		}
+	}
+}
+
+// chooseDictionaries asks which of the published dictionaries to download
+// and waits for the answer. It returns nil for all of them, as a single
+// database. It must not be called from the UI goroutine.
+func chooseDictionaries(w fyne.Window, m *download.Manifest) []string {
+	chosen := make(chan []string, 1)
+	fyne.Do(func() {
+		var titles []string
+		codeByTitle := make(map[string]string)
+		for _, f := range m.Shards() {
+			title := fmt.Sprintf("%s (%s, %.1f MB)", f.Title, f.Dict, float64(f.TransferSize())/(1024*1024))
+			titles = append(titles, title)
+			codeByTitle[title] = f.Dict
+		}
+		checks := widget.NewCheckGroup(titles, nil)
+		scroll := container.NewVScroll(checks)
+		scroll.SetMinSize(fyne.NewSize(500, 400))
+		content := container.NewBorder(
+			widget.NewLabel(fmt.Sprintf("Download all dictionaries (%d MB) or only the selected ones?", m.Size()/(1024*1024))),
+			nil, nil, nil, scroll)
+
+		dlg := dialog.NewCustomConfirm("Dictionaries", "Download Selected", "Download All", content, func(selected bool) {
+			var codes []string
+			if selected {
+				for _, title := range checks.Selected {
+					codes = append(codes, codeByTitle[title])
+				}
+			}
+			chosen <- codes
+		}, w)
+		dlg.Resize(fyne.NewSize(600, 550))
+		dlg.Show()
+	})
+	return <-chosen
+}
+
+// updateData updates the installed data to latest: with the delta from
+// the installed version applied to the open database if there is one, or
+// else with a download used from the next start. It reports whether the
+// update is already in use.
+func updateData(ctx context.Context, db *search.DB, latest *download.Manifest, progress download.ProgressFunc) (bool, error) {
+	err := download.Patch(ctx, db, latest, progress)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, download.ErrNoDelta) && !errors.Is(err, search.ErrNoSourceIDs) {
+		return false, err
+	}
+	return false, download.Update(ctx, latest, progress)
+}
+
+// updateSize returns how much updateData downloads
+func updateSize(db *search.DB, latest *download.Manifest) int64 {
+	installed := download.InstalledManifest()
+	if f, ok := download.DeltaFile(installed, latest); ok && db.HasSourceIDs() {
+		return f.TransferSize()
+	}
+	var size int64
+	for _, f := range download.UpdateFiles(installed, latest) {
+		size += f.TransferSize()
+	}
+	return size
+}
+
+// formatSize formats a download size, in MB unless it is smaller
+func formatSize(size int64) string {
+	if size < 1024*1024 {
+		return fmt.Sprintf("%d KB", max(size/1024, 1))
+	}
+	return fmt.Sprintf("%d MB", size/(1024*1024))
+}
+
+// newDataUpdateButton returns a hidden button that appears when newer
+// dictionary data is published. It downloads the data, reporting progress
+// through setStatus, and calls onChange when the update is applied at once.
+func newDataUpdateButton(w fyne.Window, db *search.DB, setStatus func(string), onChange func()) *widget.Button {
+	btn := widget.NewButton("", nil)
+	btn.Importance = widget.LowImportance
+	btn.Hide()
+	if db == nil {
+		return btn
+	}
+
+	go func() {
+		latest, err := download.FetchManifest(context.Background(), *manifestURL)
+		if err != nil {
+			log.Printf("Could not check for dictionary updates: %v", err)
+			return
+		}
+		if !download.HasUpdate(download.InstalledManifest(), latest) || !latest.Supports(Version) {
+			return
+		}
+		fyne.Do(func() {
+			btn.SetText("Dictionary update: " + latest.Version)
+			btn.OnTapped = func() { downloadDataUpdate(w, btn, db, latest, setStatus, onChange) }
+			btn.Show()
+		})
+	}()
+	return btn
+}
+
+// downloadDataUpdate asks whether to download newer data and downloads it
+// in the background
+func downloadDataUpdate(w fyne.Window, btn *widget.Button, db *search.DB, latest *download.Manifest, setStatus func(string), onChange func()) {
+	msg := fmt.Sprintf("Download dictionary data %s (%s)?", latest.Version, formatSize(updateSize(db, latest)))
+	dialog.ShowConfirm("Dictionary Update", msg, func(ok bool) {
+		if !ok {
+			return
+		}
+		btn.Disable()
+		go func() {
+			lastPercent := -1
+			applied, err := updateData(context.Background(), db, latest, func(downloaded, total int64) {
+				if total <= 0 {
+					return
+				}
+				if percent := int(downloaded * 100 / total); percent != lastPercent {
+					lastPercent = percent
+					fyne.Do(func() {
+						setStatus(fmt.Sprintf("Downloading dictionary update... %d%%", percent))
+					})
+				}
+			})
+			fyne.Do(func() {
+				if err != nil {
+					btn.Enable()
+					dialog.ShowError(fmt.Errorf("Dictionary update failed: %v", err), w)
+					return
+				}
+				btn.Hide()
+				if applied {
+					setStatus("Dictionary data updated to " + latest.Version)
+					onChange()
+					return
+				}
+				setStatus("Dictionary update downloaded, restart to use it")
+			})
+		}()
+	}, w)
+}
+
+// newDataRow is the settings row showing the installed dictionary data
+// version, with buttons to open the dictionary manager and to verify the
+// database against its checksum
+func newDataRow(w fyne.Window, openManager func()) fyne.CanvasObject {
+	label := widget.NewLabel("Unknown version")
+	if m := download.InstalledManifest(); m != nil && m.Version != "" {
+		label.SetText("Version " + m.Version)
+	}
+
+	var verifyBtn *widget.Button
+	verifyBtn = widget.NewButton("Verify", func() {
+		verifyBtn.Disable()
+		verifyBtn.SetText("Verifying...")
+		go func() {
+			err := download.VerifyDatabase()
+			fyne.Do(func() {
+				verifyBtn.SetText("Verify")
+				verifyBtn.Enable()
+				if errors.Is(err, download.ErrChecksumMismatch) {
+					dialog.ShowError(errors.New("The dictionary database is damaged and will be downloaded again at the next start."), w)
+					return
+				}
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("Could not verify the dictionary database: %v", err), w)
+					return
+				}
+				dialog.ShowInformation("Verify", "The dictionary database is intact.", w)
+			})
+		}()
+	})
+
+	manageBtn := widget.NewButton("Dictionaries...", openManager)
+	return container.NewBorder(nil, nil, widget.NewLabel("Dictionary data:"),
+		container.NewHBox(manageBtn, verifyBtn), label)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/download"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// dictRow is a dictionary of the manager: published, installed or both
+type dictRow struct {
+	Code      string
+	Title     string
+	Size      int64 // Download size, 0 if not published
+	Installed bool
+	Outdated  bool // Installed, and another copy is published
+}
+
+// dictRows lists the published and the installed dictionaries by title.
+// m is the manifest of the installed data.
+func dictRows(latest, m *download.Manifest, installed []search.Dict) []dictRow {
+	byCode := make(map[string]*dictRow)
+	var rows []*dictRow
+	if latest != nil {
+		for _, f := range latest.Shards() {
+			row := &dictRow{Code: f.Dict, Title: f.Title, Size: f.TransferSize()}
+			byCode[f.Dict] = row
+			rows = append(rows, row)
+		}
+	}
+	for _, d := range installed {
+		row, ok := byCode[d.Code]
+		if !ok {
+			row = &dictRow{Code: d.Code, Title: d.Name}
+			byCode[d.Code] = row
+			rows = append(rows, row)
+		}
+		row.Installed = true
+		row.Outdated = latest != nil && download.ShardOutdated(m, latest, d.Code)
+		if row.Title == "" {
+			row.Title = d.Name
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Title < rows[j].Title })
+	result := make([]dictRow, len(rows))
+	for i, row := range rows {
+		result[i] = *row
+	}
+	return result
+}
+
+// DictManagerWindow downloads, updates and removes single dictionaries,
+// and updates all installed ones to newer dictionary data.
+type DictManagerWindow struct {
+	window   fyne.Window
+	db       *search.DB
+	settings *state.Store // Nil if the profile's settings couldn't be opened
+	onChange func()       // Called after dictionaries were added or removed
+
+	latest    *download.Manifest
+	rows      []dictRow
+	installed int
+	current   bool // The installed data is the published version
+	busy      bool
+	updated   bool // The update is downloaded, to be installed at the next start
+
+	info      *widget.Label
+	updateBtn *widget.Button
+	list      *widget.List
+	status    *widget.Label
+
+	closed bool
+}
+
+// NewDictManagerWindow creates a dictionary manager for the open database
+// and the open profile's settings.
+func NewDictManagerWindow(app fyne.App, db *search.DB, settings *state.Store, onChange func()) *DictManagerWindow {
+	w := &DictManagerWindow{
+		db:       db,
+		settings: settings,
+		onChange: onChange,
+	}
+
+	w.window = app.NewWindow("Dictionary Manager")
+	w.window.Resize(fyne.NewSize(600, 600))
+	w.window.SetOnClosed(func() {
+		w.closed = true
+	})
+
+	w.buildUI()
+	w.reload()
+	go w.fetchLatest()
+	return w
+}
+
+func (w *DictManagerWindow) buildUI() {
+	w.info = widget.NewLabel("Checking for available dictionaries...")
+	w.info.Wrapping = fyne.TextWrapWord
+	w.updateBtn = widget.NewButton("Update All", w.updateAll)
+	w.updateBtn.Hide()
+
+	w.list = widget.NewList(
+		func() int { return len(w.rows) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabel("title")
+			detail := widget.NewLabel("detail")
+			detail.Importance = widget.LowImportance
+			updateBtn := widget.NewButton("Update", nil)
+			updateBtn.Hide()
+			actionBtn := widget.NewButton("Download", nil)
+			return container.NewBorder(nil, nil, nil, container.NewHBox(updateBtn, actionBtn), container.NewHBox(title, detail))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(w.rows) {
+				return
+			}
+			row := w.rows[id]
+			border := obj.(*fyne.Container)
+			labels := border.Objects[0].(*fyne.Container)
+			labels.Objects[0].(*widget.Label).SetText(row.Title)
+			labels.Objects[1].(*widget.Label).SetText(w.rowDetail(row))
+			buttons := border.Objects[1].(*fyne.Container)
+			updateBtn := buttons.Objects[0].(*widget.Button)
+			actionBtn := buttons.Objects[1].(*widget.Button)
+
+			updateBtn.OnTapped = func() { w.add(row) }
+			if row.Outdated {
+				updateBtn.Show()
+			} else {
+				updateBtn.Hide()
+			}
+			if w.busy {
+				updateBtn.Disable()
+			} else {
+				updateBtn.Enable()
+			}
+			if row.Installed {
+				actionBtn.SetText("Remove")
+				actionBtn.OnTapped = func() { w.remove(row) }
+				if w.busy || w.installed <= 1 {
+					actionBtn.Disable()
+				} else {
+					actionBtn.Enable()
+				}
+				return
+			}
+			actionBtn.SetText("Download")
+			actionBtn.OnTapped = func() { w.add(row) }
+			if w.busy {
+				actionBtn.Disable()
+			} else {
+				actionBtn.Enable()
+			}
+		},
+	)
+
+	w.status = widget.NewLabel("")
+	w.window.SetContent(container.NewBorder(
+		container.NewBorder(nil, nil, nil, w.updateBtn, w.info),
+		container.NewHBox(w.status, layout.NewSpacer()),
+		nil, nil,
+		w.list,
+	))
+}
+
+// rowDetail describes a dictionary's code, size and state
+func (w *DictManagerWindow) rowDetail(row dictRow) string {
+	detail := row.Code
+	if row.Size > 0 {
+		detail += fmt.Sprintf(" · %.1f MB", float64(row.Size)/(1024*1024))
+	}
+	if row.Outdated {
+		detail += " · update available"
+	} else if row.Installed {
+		detail += " · installed"
+	}
+	return detail
+}
+
+// fetchLatest fetches the manifest of the published dictionaries
+func (w *DictManagerWindow) fetchLatest() {
+	latest, err := download.FetchManifest(context.Background(), *manifestURL)
+	fyne.Do(func() {
+		if err != nil {
+			log.Printf("Could not fetch the dictionary list: %v", err)
+			w.info.SetText("Could not fetch the list of available dictionaries. Installed dictionaries can still be removed.")
+			return
+		}
+		if !latest.Supports(Version) {
+			w.info.SetText(fmt.Sprintf("New dictionaries need app version %s or later.", latest.MinAppVersion))
+			return
+		}
+		w.latest = latest
+		w.reload()
+	})
+}
+
+// reload lists the installed and published dictionaries
+func (w *DictManagerWindow) reload() {
+	installed, err := w.db.GetDicts()
+	if err != nil {
+		log.Printf("Warning: Could not load dictionaries: %v", err)
+	}
+	w.installed = len(installed)
+	m := download.InstalledManifest()
+	w.rows = dictRows(w.latest, m, installed)
+	w.current = w.latest != nil && m != nil && m.Version == w.latest.Version
+
+	w.updateBtn.Hide()
+	switch {
+	case w.latest == nil:
+	case w.latest.Shards() == nil:
+		w.info.SetText("Dictionaries are only published together; use the dictionary update to get new data.")
+	case w.updated:
+		w.info.SetText(fmt.Sprintf("Dictionary data %s is downloaded and used from the next start.", w.latest.Version))
+	case !w.current:
+		w.info.SetText(fmt.Sprintf("Dictionary data %s is available. Update the dictionaries one by one, or all of them.", w.latest.Version))
+		w.updateBtn.Show()
+	default:
+		w.info.SetText(fmt.Sprintf("%d of %d dictionaries installed (data %s).", len(installed), len(w.latest.Shards()), w.latest.Version))
+	}
+	if w.busy {
+		w.updateBtn.Disable()
+	} else {
+		w.updateBtn.Enable()
+	}
+	w.list.Refresh()
+}
+
+// setBusy blocks other changes while one is running
+func (w *DictManagerWindow) setBusy(busy bool, status string) {
+	w.busy = busy
+	w.status.SetText(status)
+	w.reload()
+}
+
+// progress reports download progress in the status line
+func (w *DictManagerWindow) progress(what string) download.ProgressFunc {
+	lastPercent := -1
+	return func(downloaded, total int64) {
+		if total <= 0 {
+			return
+		}
+		if percent := int(downloaded * 100 / total); percent != lastPercent {
+			lastPercent = percent
+			fyne.Do(func() {
+				w.status.SetText(fmt.Sprintf("Downloading %s... %d%%", what, percent))
+			})
+		}
+	}
+}
+
+// add downloads a dictionary into the open database
+func (w *DictManagerWindow) add(row dictRow) {
+	w.setBusy(true, "Downloading "+row.Title+"...")
+	latest := w.latest
+	go func() {
+		err := addDict(context.Background(), w.db, w.settings, latest, row.Code, w.progress(row.Code))
+		fyne.Do(func() {
+			if err != nil {
+				w.setBusy(false, "")
+				if errors.Is(err, search.ErrShardConflict) {
+					dialog.ShowInformation("Dictionary Manager", "The dictionary doesn't fit the installed data. Update all dictionaries first.", w.window)
+					return
+				}
+				dialog.ShowError(fmt.Errorf("Failed to download %s: %v", row.Title, err), w.window)
+				return
+			}
+			w.setBusy(false, row.Title+" installed")
+			w.onChange()
+		})
+	}()
+}
+
+// remove deletes a dictionary from the open database
+func (w *DictManagerWindow) remove(row dictRow) {
+	dialog.ShowConfirm("Remove Dictionary",
+		fmt.Sprintf("Remove %s? It can be downloaded again later.", row.Title),
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			w.setBusy(true, "Removing "+row.Title+"...")
+			go func() {
+				err := download.RemoveDict(w.db, row.Code)
+				fyne.Do(func() {
+					if err != nil {
+						w.setBusy(false, "")
+						dialog.ShowError(fmt.Errorf("Failed to remove %s: %v", row.Title, err), w.window)
+						return
+					}
+					w.setBusy(false, row.Title+" removed")
+					w.onChange()
+				})
+			}()
+		}, w.window)
+}
+
+// updateAll updates the installed dictionaries to the published data, at
+// once with a delta or else from the next start
+func (w *DictManagerWindow) updateAll() {
+	latest := w.latest
+	msg := fmt.Sprintf("Download dictionary data %s (%s)?", latest.Version, formatSize(updateSize(w.db, latest)))
+	dialog.ShowConfirm("Update Dictionaries", msg, func(ok bool) {
+		if !ok {
+			return
+		}
+		w.setBusy(true, "Downloading dictionary update...")
+		go func() {
+			applied, err := updateData(context.Background(), w.db, latest, w.progress("dictionary update"))
+			fyne.Do(func() {
+				if err != nil {
+					w.setBusy(false, "")
+					dialog.ShowError(fmt.Errorf("Dictionary update failed: %v", err), w.window)
+					return
+				}
+				if applied {
+					w.setBusy(false, "Dictionary data updated to "+latest.Version)
+					w.onChange()
+					return
+				}
+				w.updated = true
+				w.setBusy(false, "Dictionary update downloaded, restart to use it")
+			})
+		}()
+	}, w.window)
+}
+
+// Show displays the window
+func (w *DictManagerWindow) Show() {
+	w.reload()
+	w.window.Show()
+	w.window.RequestFocus()
+}
+
+// IsClosed returns true if the window was closed
+func (w *DictManagerWindow) IsClosed() bool {
+	return w.closed
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// historyWindowLimit is how many entries the history window lists
+const historyWindowLimit = 1000
+
+// historyRow is a row of the history list: a date heading or an entry
+type historyRow struct {
+	Heading string // Set for headings only
+	Entry   state.HistoryEntry
+}
+
+// historyRows lists the entries under a heading per day: "Today",
+// "Yesterday", the weekday for the last week and the date before that.
+// Entries must be most recent first.
+func historyRows(entries []state.HistoryEntry, now time.Time) []historyRow {
+	var rows []historyRow
+	last := ""
+	for _, e := range entries {
+		if heading := historyDay(e.LastUsed.In(now.Location()), now); heading != last {
+			rows = append(rows, historyRow{Heading: heading})
+			last = heading
+		}
+		rows = append(rows, historyRow{Entry: e})
+	}
+	return rows
+}
+
+// historyDay names the day of t as seen from now
+func historyDay(t, now time.Time) string {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch {
+	case !t.Before(today):
+		return "Today"
+	case !t.Before(today.AddDate(0, 0, -1)):
+		return "Yesterday"
+	case !t.Before(today.AddDate(0, 0, -6)):
+		return t.Weekday().String()
+	case t.Year() == now.Year():
+		return t.Format("2 January")
+	default:
+		return t.Format("2 January 2006")
+	}
+}
+
+// historyContext describes the context of a search: its mode, the number
+// of results and dictionaries, and the article opened from it
+func historyContext(e state.HistoryEntry) string {
+	var parts []string
+	if e.Mode != "" {
+		parts = append(parts, e.Mode)
+	}
+	if e.Results == 1 {
+		parts = append(parts, "1 result")
+	} else if e.Results > 1 {
+		parts = append(parts, fmt.Sprintf("%d results", e.Results))
+	}
+	if len(e.DictCodes) == 1 {
+		parts = append(parts, e.DictCodes[0])
+	} else if len(e.DictCodes) > 1 {
+		parts = append(parts, fmt.Sprintf("%d dicts", len(e.DictCodes)))
+	}
+	if e.ArticleWord != "" && e.ArticleWord != e.Query {
+		parts = append(parts, "→ "+e.ArticleWord)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// HistoryWindow lists the whole search history by day, with a filter and
+// deletion of single searches or all of them.
+type HistoryWindow struct {
+	window   fyne.Window
+	settings *state.Store
+	onOpen   func(state.HistoryEntry)
+
+	filter *widget.Entry
+	list   *widget.List
+	status *widget.Label
+	rows   []historyRow
+
+	closed bool
+}
+
+// NewHistoryWindow creates a history window. onOpen repeats a search from
+// the history.
+func NewHistoryWindow(app fyne.App, settings *state.Store, onOpen func(state.HistoryEntry)) *HistoryWindow {
+	w := &HistoryWindow{
+		settings: settings,
+		onOpen:   onOpen,
+	}
+
+	w.window = app.NewWindow("Search History")
+	w.window.Resize(fyne.NewSize(500, 600))
+	w.window.SetOnClosed(func() {
+		w.closed = true
+	})
+
+	w.buildUI()
+	w.reload()
+	return w
+}
+
+func (w *HistoryWindow) buildUI() {
+	w.filter = widget.NewEntry()
+	w.filter.SetPlaceHolder("Filter by query or opened word...")
+	w.filter.OnChanged = func(string) {
+		w.reload()
+	}
+
+	w.list = widget.NewList(
+		func() int { return len(w.rows) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabel("query")
+			context := widget.NewLabel("context")
+			context.Importance = widget.LowImportance
+			deleteBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
+			deleteBtn.Importance = widget.LowImportance
+			return container.NewBorder(nil, nil, nil, deleteBtn, container.NewHBox(title, context))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(w.rows) {
+				return
+			}
+			row := w.rows[id]
+			border := obj.(*fyne.Container)
+			labels := border.Objects[0].(*fyne.Container)
+			title := labels.Objects[0].(*widget.Label)
+			context := labels.Objects[1].(*widget.Label)
+			deleteBtn := border.Objects[1].(*widget.Button)
+
+			if row.Heading != "" {
+				title.TextStyle = fyne.TextStyle{Bold: true}
+				title.SetText(row.Heading)
+				context.Hide()
+				deleteBtn.Hide()
+				return
+			}
+			title.TextStyle = fyne.TextStyle{}
+			title.SetText(row.Entry.Query)
+			context.SetText(historyContext(row.Entry))
+			context.Show()
+			query := row.Entry.Query
+			deleteBtn.OnTapped = func() {
+				w.settings.DeleteHistory(query)
+				w.reload()
+			}
+			deleteBtn.Show()
+		},
+	)
+	w.list.OnSelected = func(id widget.ListItemID) {
+		w.list.UnselectAll()
+		if id < len(w.rows) && w.rows[id].Heading == "" {
+			w.onOpen(w.rows[id].Entry)
+		}
+	}
+
+	w.status = widget.NewLabel("")
+	clearBtn := widget.NewButtonWithIcon("Clear All", theme.ContentClearIcon(), func() {
+		dialog.ShowConfirm("Clear History", "Delete the whole search history?", func(ok bool) {
+			if ok {
+				w.settings.ClearHistory()
+				w.reload()
+			}
+		}, w.window)
+	})
+
+	w.window.SetContent(container.NewBorder(
+		w.filter,
+		container.NewHBox(w.status, layout.NewSpacer(), clearBtn),
+		nil, nil,
+		w.list,
+	))
+}
+
+// reload fetches the history entries matching the filter
+func (w *HistoryWindow) reload() {
+	entries := w.settings.SearchHistory(w.filter.Text, historyWindowLimit)
+	w.rows = historyRows(entries, time.Now())
+	if len(entries) == 1 {
+		w.status.SetText("1 search")
+	} else {
+		w.status.SetText(fmt.Sprintf("%d searches", len(entries)))
+	}
+	w.list.Refresh()
+}
+
+// Show displays the window, refreshing the list
+func (w *HistoryWindow) Show() {
+	w.reload()
+	w.window.Show()
+	w.window.RequestFocus()
+}
+
+// IsClosed returns true if the window was closed
+func (w *HistoryWindow) IsClosed() bool {
+	return w.closed
+}
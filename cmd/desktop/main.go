@@ -4,6 +4,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"image/color"
@@ -20,6 +21,7 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
@@ -33,12 +35,17 @@ import (
 
 var testDownload = flag.Bool("test-download", false, "Simulate download flow for testing")
 var showVersion = flag.Bool("version", false, "Print version and exit")
+var manifestURL = flag.String("manifest-url", download.ManifestURL(), "URL of the dictionary data manifest")
+var profileFlag = flag.String("profile", "", "User profile to open (default: ask when there are several)")
+var installFlag = flag.String("install", "", "Install dictionary data from a local .db, .db.gz or .db.zst file, or a folder holding one, instead of downloading it")
+var allowUnsignedFlag = flag.Bool("allow-unsigned", false, "Install dictionary data without a valid signature, e.g. data built locally")
 
 // Version is set at build time via ldflags
 var Version = "dev"
 
 func main() {
 	flag.Parse()
+	download.AllowUnsigned = *allowUnsignedFlag
 
 	if *showVersion {
 		fmt.Println(Version)
@@ -47,17 +54,34 @@ func main() {
 
 	log.Printf("Starting Sanskrit Dictionary (version: %s)...", Version)
 
-	// Open state/settings store
-	settings, err := state.Open()
+	// Profile to open: the one asked for, or the one chosen last
+	profile := *profileFlag
+	if profile == "" {
+		profile = state.LastProfile()
+	}
+
+	// Create app first (needed for profile picker and download dialog)
+	a := app.New()
+
+	// With several profiles, ask which one to open
+	if *profileFlag == "" {
+		if profiles, err := state.Profiles(); err == nil && len(profiles) > 1 {
+			showProfilePicker(a, profiles, profile)
+			return
+		}
+	}
+
+	// Open state/settings store of the chosen profile
+	settings, err := state.OpenProfile(profile)
 	if err != nil {
 		log.Printf("Warning: Could not open settings: %v", err)
 	} else {
 		defer settings.Close()
+		// Sync stars and notes with other devices while running
+		stopSync := startSync(settings)
+		defer stopSync()
 	}
 
-	// Create app first (needed for download dialog)
-	a := app.New()
-
 	// Check if database exists, download if not
 	dbPath, err := download.GetDatabasePath()
 	if err != nil {
@@ -68,6 +92,32 @@ func main() {
 	// Database pointer - may be set after download
 	var db *search.DB
 
+	// Install dictionary data from a local copy, e.g. on a USB drive
+	if *installFlag != "" {
+		log.Printf("Installing dictionary data from %s...", *installFlag)
+		lastPercent := -1
+		err := installLocal(context.Background(), *installFlag, func(n, total int64) {
+			if total <= 0 {
+				return
+			}
+			if percent := int(n * 100 / total); percent/10 != lastPercent/10 {
+				lastPercent = percent
+				log.Printf("Installing... %d%%", percent)
+			}
+		})
+		if err != nil {
+			log.Fatalf("Could not install from %s: %v", *installFlag, err)
+		}
+	}
+
+	// Install dictionary data downloaded in the last session, or from a
+	// local copy
+	if installed, err := installPending(settings); err != nil {
+		log.Printf("Warning: Could not install downloaded database: %v", err)
+	} else if installed {
+		log.Println("Installed updated dictionary database")
+	}
+
 	// Check database status
 	dbStatus := download.CheckDatabase()
 	if *testDownload {
@@ -102,7 +152,11 @@ func main() {
 	}
 	applyZoom(zoomPercent)
 
-	w := a.NewWindow("Sanskrit Upāya")
+	title := "Sanskrit Upāya"
+	if profile != state.DefaultProfile {
+		title += " — " + profile
+	}
+	w := a.NewWindow(title)
 	w.Resize(fyne.NewSize(1100, 700))
 
 	// If database doesn't exist or needs update, download it first
@@ -120,23 +174,95 @@ func main() {
 			subtitleLabel = widget.NewLabel("This only happens once.")
 		}
 
+		// Channel to receive download result
+		type downloadResult struct {
+			db  *search.DB
+			err error
+		}
+		done := make(chan downloadResult, 2)
+
+		// Quitting stops the download; it resumes at the next start
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// Installing from a local file stops it as well
+		downloadCtx, stopDownload := context.WithCancel(ctx)
+
+		progressFunc := func(verb string) download.ProgressFunc {
+			return func(n, total int64) {
+				if total > 0 {
+					percent := float64(n) / float64(total)
+					fyne.Do(func() {
+						progressBar.SetValue(percent)
+						statusLabel.SetText(fmt.Sprintf("%s... %d / %d MB", verb, n/(1024*1024), total/(1024*1024)))
+					})
+				}
+			}
+		}
+
+		// Without internet access the data can be installed from a copy,
+		// e.g. on a USB drive
+		var installFileBtn, installFolderBtn *widget.Button
+		installFrom := func(path string) {
+			stopDownload()
+			installFileBtn.Disable()
+			installFolderBtn.Disable()
+			statusLabel.SetText("Installing from " + path + "...")
+			go func() {
+				err := installLocal(ctx, path, progressFunc("Installing"))
+				if err == nil {
+					_, err = installPending(settings)
+				}
+				if err != nil {
+					log.Printf("Could not install from %s: %v", path, err)
+					fyne.Do(func() {
+						dialog.ShowError(fmt.Errorf("Could not install from %s: %v", path, err), w)
+						statusLabel.SetText("Choose another file, or restart the app to download the dictionaries.")
+						installFileBtn.Enable()
+						installFolderBtn.Enable()
+					})
+					return
+				}
+				fyne.Do(func() {
+					statusLabel.SetText("Installed! Loading database...")
+					progressBar.SetValue(1.0)
+				})
+				newDB, openErr := search.Open(dbPath)
+				done <- downloadResult{newDB, openErr}
+			}()
+		}
+		installFileBtn = widget.NewButton("Install from File...", func() {
+			fileDialog := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+				if err != nil || r == nil {
+					return
+				}
+				r.Close()
+				installFrom(r.URI().Path())
+			}, w)
+			fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".db", ".gz", ".zst"}))
+			fileDialog.Show()
+		})
+		installFolderBtn = widget.NewButton("Install from Folder...", func() {
+			dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+				if err != nil || dir == nil {
+					return
+				}
+				installFrom(dir.Path())
+			}, w)
+		})
+
 		downloadContent := container.NewVBox(
 			titleLabel,
 			subtitleLabel,
 			widget.NewLabel(""),
 			statusLabel,
 			progressBar,
+			widget.NewLabel(""),
+			widget.NewLabel("Without internet access, install a copy of the dictionary data (e.g. from a USB drive):"),
+			container.NewHBox(installFileBtn, installFolderBtn),
 		)
 
 		w.SetContent(container.NewCenter(downloadContent))
 
-		// Channel to receive download result
-		type downloadResult struct {
-			db  *search.DB
-			err error
-		}
-		done := make(chan downloadResult, 1)
-
 		// Start download in background
 		go func() {
 			var downloadErr error
@@ -157,19 +283,27 @@ func main() {
 					time.Sleep(200 * time.Millisecond)
 				}
 			} else {
-				// Real download
-				downloadErr = download.Download(func(downloaded, total int64) {
-					if total > 0 {
-						percent := float64(downloaded) / float64(total)
-						fyne.Do(func() {
-							progressBar.SetValue(percent)
-							statusLabel.SetText(fmt.Sprintf("Downloading... %d / %d MB", downloaded/(1024*1024), total/(1024*1024)))
-						})
+				// Real download of the data in the manifest, all of it or
+				// the dictionaries chosen when they are published singly
+				var m *download.Manifest
+				m, downloadErr = latestManifest(downloadCtx)
+				if downloadErr == nil {
+					var dictCodes []string
+					if len(m.Shards()) > 0 {
+						dictCodes = chooseDictionaries(w, m)
 					}
-				})
+					downloadErr = downloadDatabase(downloadCtx, m, dictCodes, progressFunc("Downloading"))
+				}
+				if downloadErr == nil {
+					_, downloadErr = installPending(settings)
+				}
 			}
 
 			if downloadErr != nil {
+				if downloadCtx.Err() != nil {
+					// Stopped to install from a local file
+					return
+				}
 				done <- downloadResult{nil, downloadErr}
 				return
 			}
@@ -190,7 +324,7 @@ func main() {
 			fyne.Do(func() {
 				if result.err != nil {
 					dialog.ShowError(fmt.Errorf("Failed: %v", result.err), w)
-					statusLabel.SetText("Failed. Please restart the app to try again.")
+					statusLabel.SetText("Failed. Please restart the app to resume the download.")
 					return
 				}
 
@@ -202,6 +336,7 @@ func main() {
 		}()
 
 		w.ShowAndRun()
+		cancel()
 		if db != nil {
 			db.Close()
 		}
@@ -458,11 +593,11 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			pillsContainer := box.Objects[1].(*fyne.Container)
 
 			// Update word label
-			wordText := r.Word
+			wordText := displayWord(r.Word, r.Homonym)
 			if r.Word != "" && !transliterate.IsDevanagari(r.Word) {
 				deva := transliterate.IASTToDevanagari(r.Word)
 				if deva != "" {
-					wordText = r.Word + " " + deva
+					wordText += " " + deva
 				}
 			}
 			wordLabel.SetText(wordText)
@@ -566,6 +701,77 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		emptyState.Show()
 	}
 
+	// Compare mode: chosen dictionaries in side-by-side columns
+	compareMode := settings != nil && settings.GetBool("compare_mode", false)
+	var compareDicts []string
+	if settings != nil {
+		compareDicts = parseCompareDicts(settings.Get("compare_dicts"))
+	}
+	compareBtn := widget.NewButtonWithIcon("Compare", theme.GridIcon(), nil)
+	compareBtn.Importance = widget.LowImportance
+	compareColumnsBtn := widget.NewButton("Columns...", nil)
+	compareColumnsBtn.Importance = widget.LowImportance
+
+	// Root family panel for the word being read
+	rootBtn := widget.NewButtonWithIcon("Root family", theme.ListIcon(), nil)
+	rootBtn.Importance = widget.LowImportance
+
+	// Back/forward navigation over viewed searches, kept across restarts
+	var navEntries []state.NavEntry
+	navCurrent := -1
+	if settings != nil {
+		navEntries, navCurrent = settings.LoadNavigation()
+	}
+	nav := newNavHistory(navEntries, navCurrent)
+	navSaver := newDebouncer(time.Second)
+	backBtn := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), nil)
+	backBtn.Importance = widget.LowImportance
+	forwardBtn := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), nil)
+	forwardBtn.Importance = widget.LowImportance
+	navChanged := func() {
+		if nav.CanGoBack() {
+			backBtn.Enable()
+		} else {
+			backBtn.Disable()
+		}
+		if nav.CanGoForward() {
+			forwardBtn.Enable()
+		} else {
+			forwardBtn.Disable()
+		}
+		if settings != nil {
+			entries, current := nav.Snapshot()
+			navSaver.Do(func() {
+				settings.SaveNavigation(entries, current)
+			})
+		}
+	}
+	navChanged()
+
+	// Article to select when a restored search completes
+	var pendingArticleID int64
+
+	// Cross-reference handler, assigned once the search row exists
+	var followLink func(word string)
+	openLink := func(word string) {
+		if followLink != nil {
+			followLink(word)
+		}
+	}
+
+	// Scans of the printed pages, from the folder chosen in settings
+	openScan := func(dictCode, page string) {
+		dir := ""
+		if settings != nil {
+			dir = settings.Get("scan_dir")
+		}
+		openPageScan(a, w, dir, dictCode, page)
+	}
+
+	// historyQuery is the query of the shown results when it was saved to
+	// history, so the article opened from them can be recorded with it
+	var historyQuery string
+
 	// Navigate to grouped result by index
 	navigateTo := func(idx int) {
 		if idx >= 0 && idx < len(groupedResults) {
@@ -576,16 +782,17 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			gr := &groupedResults[idx]
 
 			// Header: word in IAST and Devanagari
-			headerText := gr.Word
+			headerText := displayWord(gr.Word, gr.Homonym)
 			if gr.Word != "" && !transliterate.IsDevanagari(gr.Word) {
 				deva := transliterate.IASTToDevanagari(gr.Word)
 				if deva != "" {
-					headerText = gr.Word + "   " + deva
+					headerText += "   " + deva
 				}
 			}
 			contentHeaderLabel.SetText(headerText)
 
 			// Clear and rebuild content
+			tooltips.Hide()
 			contentContainer.RemoveAll()
 			contentHolder.RemoveAll()
 
@@ -594,6 +801,11 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			if len(gr.Entries) > 0 && len(gr.Entries[0].Articles) > 0 {
 				firstArticleID = gr.Entries[0].Articles[0].ArticleID
 			}
+			nav.SetArticle(firstArticleID)
+			navChanged()
+			if settings != nil && historyQuery != "" && firstArticleID > 0 {
+				settings.SetHistoryArticle(historyQuery, firstArticleID, gr.Word)
+			}
 
 			// Star button in header row (next to word)
 			isStarred := settings != nil && firstArticleID > 0 && settings.IsStarred(firstArticleID)
@@ -631,14 +843,75 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			})
 			copyBtn.Importance = widget.LowImportance
 
+			rootBtn.OnTapped = func() {
+				showRootFamily(w, db, currentWord, openLink)
+			}
+
+			// Columns of compare mode, none with a single dictionary
+			var compareCodes []string
+			if compareMode {
+				compareCodes = compareColumns(compareDicts, gr, dictOrder)
+			}
+
 			// Update header row with label, copy button, and star button
 			contentHeaderRow.RemoveAll()
 			contentHeaderRow.Add(contentHeaderLabel)
 			contentHeaderRow.Add(copyBtn)
 			contentHeaderRow.Add(headerStarBtn)
+			contentHeaderRow.Add(rootBtn)
+			contentHeaderRow.Add(compareBtn)
+			if compareCodes != nil {
+				compareBtn.Importance = widget.MediumImportance
+				contentHeaderRow.Add(compareColumnsBtn)
+			} else {
+				compareBtn.Importance = widget.LowImportance
+			}
+			if len(dictOrder) < minCompareColumns {
+				compareBtn.Disable()
+			} else {
+				compareBtn.Enable()
+			}
+			compareBtn.Refresh()
 			contentHeaderRow.Refresh()
 
-			if len(gr.Entries) == 1 {
+			if compareCodes != nil {
+				// One column per dictionary inside a single scroll, so the
+				// columns move together while reading
+				codes := compareCodes
+				columns := container.NewGridWithColumns(len(codes))
+				var articleTexts []string
+				for _, code := range codes {
+					name := dictByCode[code].Name
+					column := container.NewVBox(container.NewHBox(newPillLabel(code), widget.NewLabel(name)))
+					entry := findEntry(gr, code)
+					if entry == nil {
+						empty := widget.NewLabel("No entry")
+						empty.Importance = widget.LowImportance
+						column.Add(empty)
+					} else {
+						for i, article := range entry.Articles {
+							if !isContentCached(article.ArticleID) {
+								setStatus("Loading...")
+							}
+							articleContent, err := getContent(article.ArticleID)
+							if err == nil {
+								articleTexts = append(articleTexts, "["+code+"]\n"+cleanHTML(articleContent))
+								view := createArticleContent(articleContent, code, openLink)
+								showPageReference(view, article, openScan)
+								column.Add(view)
+								column.Add(newNoteEditor(settings, article))
+								if i < len(entry.Articles)-1 {
+									column.Add(widget.NewSeparator())
+								}
+							}
+							restoreStatus()
+						}
+					}
+					columns.Add(column)
+				}
+				currentArticleContent = strings.Join(articleTexts, "\n\n---\n\n")
+				contentHolder.Add(container.NewVScroll(columns))
+			} else if len(gr.Entries) == 1 {
 				// Single dictionary - show first article only
 				entry := gr.Entries[0]
 				dictHeader := container.NewHBox(
@@ -657,8 +930,10 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 					articleContent, err := getContent(articleID)
 					if err == nil {
 						articleTexts = append(articleTexts, cleanHTML(articleContent))
-						content := createSelectableArticleContent(articleContent)
+						content := createArticleContent(articleContent, entry.DictCode, openLink)
+						showPageReference(content, article, openScan)
 						contentContainer.Add(content)
+						contentContainer.Add(newNoteEditor(settings, article))
 						// Add separator between articles (but not after the last one)
 						if i < len(entry.Articles)-1 {
 							contentContainer.Add(widget.NewSeparator())
@@ -720,8 +995,10 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 							articleContent, err := getContent(articleID)
 							if err == nil {
 								allArticleTexts = append(allArticleTexts, cleanHTML(articleContent))
-								content := createSelectableArticleContent(articleContent)
+								content := createArticleContent(articleContent, e.DictCode, openLink)
+								showPageReference(content, article, openScan)
 								allContent.Add(content)
+								allContent.Add(newNoteEditor(settings, article))
 								// Add separator between articles
 								if i < len(e.Articles)-1 {
 									allContent.Add(widget.NewSeparator())
@@ -765,8 +1042,10 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 						articleContent, err := getContent(articleID)
 						if err == nil {
 							articleTexts = append(articleTexts, cleanHTML(articleContent))
-							content := createSelectableArticleContent(articleContent)
+							content := createArticleContent(articleContent, e.DictCode, openLink)
+							showPageReference(content, article, openScan)
 							vbox.Add(content)
+							vbox.Add(newNoteEditor(settings, article))
 							// Add separator between articles
 							if i < len(e.Articles)-1 {
 								vbox.Add(widget.NewSeparator())
@@ -808,6 +1087,65 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		}
 	}
 
+	compareBtn.OnTapped = func() {
+		compareMode = !compareMode
+		if settings != nil {
+			settings.SetBool("compare_mode", compareMode)
+		}
+		if currentSelection >= 0 {
+			navigateTo(currentSelection)
+		}
+	}
+
+	// Column chooser for compare mode
+	compareColumnsBtn.OnTapped = func() {
+		current := compareDicts
+		if len(current) == 0 && currentSelection >= 0 && currentSelection < len(groupedResults) {
+			current = compareColumns(nil, &groupedResults[currentSelection], dictOrder)
+		}
+		chosen := make(map[string]bool)
+		for _, code := range current {
+			chosen[code] = true
+		}
+
+		checks := container.NewVBox()
+		for _, code := range dictOrder {
+			check := widget.NewCheck(code+" - "+dictByCode[code].Name, func(on bool) {
+				chosen[code] = on
+			})
+			check.SetChecked(chosen[code])
+			checks.Add(check)
+		}
+		scroll := container.NewVScroll(checks)
+		scroll.SetMinSize(fyne.NewSize(450, 400))
+		hint := widget.NewLabel(fmt.Sprintf("Choose %d to %d dictionaries to read side by side.", minCompareColumns, maxCompareColumns))
+
+		dlg := dialog.NewCustomConfirm("Compare Columns", "Apply", "Cancel", container.NewBorder(hint, nil, nil, nil, scroll), func(ok bool) {
+			if !ok {
+				return
+			}
+			var codes []string
+			for _, code := range dictOrder {
+				if chosen[code] {
+					codes = append(codes, code)
+				}
+			}
+			if len(codes) < minCompareColumns || len(codes) > maxCompareColumns {
+				dialog.ShowInformation("Compare Columns", fmt.Sprintf("Please choose %d to %d dictionaries.", minCompareColumns, maxCompareColumns), w)
+				return
+			}
+			compareDicts = codes
+			if settings != nil {
+				settings.Set("compare_dicts", strings.Join(codes, ","))
+			}
+			if currentSelection >= 0 {
+				navigateTo(currentSelection)
+			}
+		}, w)
+		dlg.Resize(fyne.NewSize(500, 520))
+		dlg.Show()
+	}
+
 	// Clear content display
 	clearContent := func() {
 		currentSelection = -1
@@ -836,18 +1174,23 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		if !groupResultsSetting {
 			// Ungrouped: each word+dict combo is separate
 			grouped := make([]GroupedResult, 0, len(results))
-			// Group by word+dict
-			type key struct{ word, dict string }
+			// Group by word+homonym+dict
+			type key struct {
+				word    string
+				homonym int
+				dict    string
+			}
 			seen := make(map[key]*GroupedResult)
 			var order []key
 
 			for _, r := range results {
-				k := key{r.Word, r.DictCode}
+				k := key{r.Word, r.Homonym, r.DictCode}
 				if gr, ok := seen[k]; ok {
 					gr.Entries[0].Articles = append(gr.Entries[0].Articles, r)
 				} else {
 					gr := &GroupedResult{
-						Word: r.Word,
+						Word:    r.Word,
+						Homonym: r.Homonym,
 						Entries: []DictEntry{{
 							DictCode: r.DictCode,
 							DictName: r.DictName,
@@ -864,12 +1207,18 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			return grouped
 		}
 
-		// Grouped: all dictionaries for same word together
-		wordMap := make(map[string]*GroupedResult)
-		var wordOrder []string
+		// Grouped: all dictionaries for same word together, keeping numbered
+		// homonyms ("1. kara", "2. kara") apart
+		type key struct {
+			word    string
+			homonym int
+		}
+		wordMap := make(map[key]*GroupedResult)
+		var wordOrder []key
 
 		for _, r := range results {
-			if gr, ok := wordMap[r.Word]; ok {
+			k := key{r.Word, r.Homonym}
+			if gr, ok := wordMap[k]; ok {
 				// Find or create dict entry
 				found := false
 				for i := range gr.Entries {
@@ -887,22 +1236,23 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 					})
 				}
 			} else {
-				wordMap[r.Word] = &GroupedResult{
-					Word: r.Word,
+				wordMap[k] = &GroupedResult{
+					Word:    r.Word,
+					Homonym: r.Homonym,
 					Entries: []DictEntry{{
 						DictCode: r.DictCode,
 						DictName: r.DictName,
 						Articles: []search.Result{r},
 					}},
 				}
-				wordOrder = append(wordOrder, r.Word)
+				wordOrder = append(wordOrder, k)
 			}
 		}
 
 		// Convert to slice preserving order and sort entries by dict order
 		grouped := make([]GroupedResult, 0, len(wordOrder))
-		for _, word := range wordOrder {
-			gr := *wordMap[word]
+		for _, k := range wordOrder {
+			gr := *wordMap[k]
 			sortByDictOrder(gr.Entries)
 			grouped = append(grouped, gr)
 		}
@@ -988,21 +1338,38 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 
 		// Capture current mode and start timing
 		mode := currentMode
+		restoreArticle := pendingArticleID
+		pendingArticleID = 0
 		startTime := time.Now()
 
 		// Run search in background
 		go func() {
 			// Get search terms (including Devanagari transliteration)
 			searchTerms := transliterate.ToSearchTerms(query)
+			if mode == search.ModeCitation {
+				// Citations ("RV. x, 129") are matched as typed
+				searchTerms = searchTerms[:1]
+			}
 
 			// Get selected dictionaries for filtering
 			dictCodes := getSelectedDictCodes()
 
+			// Notes mode searches the user's notes in the state database
+			runSearch := func(term string) ([]search.Result, error) {
+				if mode == modeNotes {
+					return searchNotes(settings, term, dictCodes, dictByCode)
+				}
+				return db.Search(term, mode, dictCodes)
+			}
+
 			// Search with primary term
-			searchResults, err := db.Search(searchTerms[0], mode, dictCodes)
+			searchResults, err := runSearch(searchTerms[0])
 			if err != nil {
 				fyne.Do(func() {
 					setStatus("Error: " + err.Error())
+					if errors.Is(err, search.ErrNoCitations) {
+						showEmpty("This database has no citation index")
+					}
 				})
 				return
 			}
@@ -1010,7 +1377,7 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			// Also search with Devanagari if we have it
 			if len(searchTerms) > 1 {
 				for _, term := range searchTerms[1:] {
-					moreResults, err := db.Search(term, mode, dictCodes)
+					moreResults, err := runSearch(term)
 					if err == nil {
 						searchResults = append(searchResults, moreResults...)
 					}
@@ -1049,15 +1416,24 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 				displayLimit = initialDisplayLimit // Reset lazy loading for new search
 
 				// Update status with raw count (not grouped), time in seconds, and dictionary count
+				historyQuery = ""
 				if len(dedupedResults) == 0 {
 					setStatus(fmt.Sprintf("No results found (%.2fs)", duration))
 					showEmpty("No results found")
 				} else {
 					setStatus(fmt.Sprintf("%d entries in %.2fs across %d dicts", len(dedupedResults), duration, dictCount))
-					// Save to history (only if results found)
+					// Save to history with the search context (only if results found)
 					if settings != nil {
-						settings.AddHistory(query)
+						settings.RecordSearch(state.HistoryEntry{
+							Query:     query,
+							Mode:      modeLabel(mode),
+							DictCodes: dictCodes,
+							Results:   len(dedupedResults),
+						})
+						historyQuery = query
 					}
+					nav.Refine(state.NavEntry{Query: query, Mode: modeLabel(mode)})
+					navChanged()
 				}
 
 				// Prefetch content for first 50 visible results in background
@@ -1075,10 +1451,16 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			// Yield again
 			time.Sleep(5 * time.Millisecond)
 
-			// Navigate to first result
+			// Navigate to first result, or to the article a restored entry was showing
 			fyne.Do(func() {
 				if len(groupedResults) > 0 {
-					navigateTo(0)
+					idx := 0
+					if restoreArticle != 0 {
+						if i := findArticle(groupedResults, restoreArticle); i >= 0 && i < displayLimit {
+							idx = i
+						}
+					}
+					navigateTo(idx)
 				} else {
 					clearContent()
 				}
@@ -1087,7 +1469,7 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 	}
 
 	// Search entry
-	searchEntry := widget.NewEntry()
+	searchEntry := newSearchField()
 	searchEntry.SetPlaceHolder("Search in Devanagari or IAST...")
 	searchEntry.OnChanged = func(text string) {
 		searchDebouncer.Do(func() {
@@ -1100,46 +1482,8 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		doSearch(text) // Immediate search on Enter
 	}
 
-	// History button
-	historyBtn := widget.NewButtonWithIcon("", theme.HistoryIcon(), func() {
-		if settings == nil {
-			return
-		}
-		history := settings.GetRecentHistory(20)
-		if len(history) == 0 {
-			dialog.ShowInformation("History", "No search history yet", w)
-			return
-		}
-
-		// Create list of history items
-		historyList := widget.NewList(
-			func() int { return len(history) },
-			func() fyne.CanvasObject {
-				return widget.NewLabel("history item")
-			},
-			func(id widget.ListItemID, obj fyne.CanvasObject) {
-				if id < len(history) {
-					obj.(*widget.Label).SetText(history[id])
-				}
-			},
-		)
-
-		var historyDialog dialog.Dialog
-		historyList.OnSelected = func(id widget.ListItemID) {
-			if id < len(history) {
-				searchEntry.SetText(history[id])
-				historyDialog.Hide()
-				doSearch(history[id])
-			}
-		}
-
-		listScroll := container.NewVScroll(historyList)
-		listScroll.SetMinSize(fyne.NewSize(300, 300))
-
-		historyDialog = dialog.NewCustom("Search History", "Close", listScroll, w)
-		historyDialog.Resize(fyne.NewSize(350, 400))
-		historyDialog.Show()
-	})
+	// History button (opens the recent searches once showEntry exists)
+	historyBtn := widget.NewButtonWithIcon("", theme.HistoryIcon(), nil)
 
 	// Starred button - opens starred articles view
 	starredBtn := widget.NewButtonWithIcon("", theme.NewThemedResource(resourceStarSvg), func() {
@@ -1152,13 +1496,16 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			return
 		}
 
-		// Filter state
+		// Filter state: word filter, view (all, one tag or one collection)
+		// and grouping by tag
 		filterText := ""
-		filteredStarred := starred
+		view := starredViewAll
+		groupByTag := settings.GetBool("starred_group_by_tag", false)
+		var rows []starredRow
 
-		// Word list for starred items
+		// Word list for starred items, with tag headings when grouped
 		starredWordList := widget.NewList(
-			func() int { return len(filteredStarred) },
+			func() int { return len(rows) },
 			func() fyne.CanvasObject {
 				wordLabel := widget.NewLabel("word")
 				wordLabel.TextStyle = fyne.TextStyle{Bold: true}
@@ -1166,14 +1513,23 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 				return container.NewBorder(nil, nil, nil, dictPill, wordLabel)
 			},
 			func(id widget.ListItemID, obj fyne.CanvasObject) {
-				if id >= len(filteredStarred) {
+				if id >= len(rows) {
 					return
 				}
-				sa := filteredStarred[id]
+				row := rows[id]
 				box := obj.(*fyne.Container)
 				wordLabel := box.Objects[0].(*widget.Label)
 				pillWidget := box.Objects[1].(*pillLabel)
 
+				if row.Heading != "" {
+					wordLabel.TextStyle = fyne.TextStyle{Italic: true}
+					wordLabel.Importance = widget.LowImportance
+					wordLabel.SetText(row.Heading)
+					pillWidget.Hide()
+					return
+				}
+
+				sa := row.Article
 				wordText := sa.Word
 				if !transliterate.IsDevanagari(sa.Word) {
 					deva := transliterate.IASTToDevanagari(sa.Word)
@@ -1181,12 +1537,20 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 						wordText = sa.Word + " " + deva
 					}
 				}
+				wordLabel.TextStyle = fyne.TextStyle{Bold: true}
+				wordLabel.Importance = widget.MediumImportance
 				wordLabel.SetText(wordText)
 				pillWidget.text = sa.DictCode
+				pillWidget.Show()
 				pillWidget.Refresh()
 			},
 		)
 
+		// View selector: all starred, a tag or a collection
+		viewSelect := widget.NewSelect(nil, nil)
+		renameViewBtn := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), nil)
+		renameViewBtn.Importance = widget.LowImportance
+
 		// Content area for articles
 		starredContent := container.NewVBox()
 		starredContentScroll := container.NewVScroll(starredContent)
@@ -1195,13 +1559,67 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		starredContentHeader := widget.NewLabel("")
 		starredContentHeader.TextStyle = fyne.TextStyle{Bold: true}
 
+		// firstArticleRow skips a leading tag heading
+		firstArticleRow := func() int {
+			for i, row := range rows {
+				if row.Heading == "" {
+					return i
+				}
+			}
+			return -1
+		}
+
 		// Navigate to starred article (declared as variable for recursive reference)
 		var showStarredArticle func(idx int)
+
+		// reload re-reads starred articles, tags and collections and rebuilds the list
+		reload := func() {
+			starred = settings.GetStarredArticles()
+			tags := settings.Tags()
+			collections := settings.Collections()
+			views := starredViews(tags, collections)
+			if !containsString(views, view) {
+				view = starredViewAll
+			}
+			viewSelect.Options = views
+			viewSelect.Selected = view
+			viewSelect.Refresh()
+			if view == starredViewAll {
+				renameViewBtn.Hide()
+			} else {
+				renameViewBtn.Show()
+			}
+
+			articles := starred
+			switch {
+			case strings.HasPrefix(view, tagViewPrefix):
+				articles = settings.ArticlesByTag(strings.TrimPrefix(view, tagViewPrefix))
+			case strings.HasPrefix(view, collectionViewPrefix):
+				articles = settings.CollectionArticles(strings.TrimPrefix(view, collectionViewPrefix))
+			}
+			rows = starredRows(articles, filterText, groupByTag, settings.TaggedArticles())
+			starredWordList.UnselectAll()
+			starredWordList.Refresh()
+		}
+
+		// showFirst shows the first article of the list, if any
+		showFirst := func(emptyText string) {
+			if idx := firstArticleRow(); idx >= 0 {
+				showStarredArticle(idx)
+				starredWordList.Select(idx)
+			} else {
+				starredContent.RemoveAll()
+				starredContent.Add(widget.NewLabel(emptyText))
+				starredContentHeader.SetText("")
+				starredContent.Refresh()
+			}
+		}
+
 		showStarredArticle = func(idx int) {
-			if idx < 0 || idx >= len(filteredStarred) {
+			if idx < 0 || idx >= len(rows) || rows[idx].Heading != "" {
 				return
 			}
-			sa := filteredStarred[idx]
+			sa := rows[idx].Article
 
 			// Load article from database
 			results, err := db.GetArticle(sa.ArticleID)
@@ -1228,40 +1646,90 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			// Unstar button
 			unstarBtn := widget.NewButtonWithIcon("Unstar", theme.DeleteIcon(), func() {
 				settings.UnstarArticle(sa.ArticleID)
-				// Refresh starred list
-				starred = settings.GetStarredArticles()
-				// Re-apply filter
-				if filterText == "" {
-					filteredStarred = starred
-				} else {
-					filteredStarred = nil
-					for _, s := range starred {
-						if strings.Contains(strings.ToLower(s.Word), strings.ToLower(filterText)) {
-							filteredStarred = append(filteredStarred, s)
-						}
-					}
-				}
-				starredWordList.Refresh()
-				if len(filteredStarred) == 0 {
-					starredContent.RemoveAll()
-					starredContent.Add(widget.NewLabel("No starred articles"))
-					starredContentHeader.SetText("")
-				} else if idx >= len(filteredStarred) {
-					showStarredArticle(len(filteredStarred) - 1)
+				reload()
+				showFirst("No starred articles")
+			})
+
+			// Add to a new or existing collection
+			collectBtn := widget.NewButtonWithIcon("Add to Collection", theme.ContentAddIcon(), func() {
+				var names []string
+				for _, c := range settings.Collections() {
+					names = append(names, c.Name)
 				}
-				starredContent.Refresh()
+				nameEntry := widget.NewSelectEntry(names)
+				nameEntry.SetPlaceHolder("Collection name")
+				dialog.ShowForm("Add to Collection", "Add", "Cancel",
+					[]*widget.FormItem{widget.NewFormItem("Collection", nameEntry)},
+					func(ok bool) {
+						if !ok {
+							return
+						}
+						if err := settings.AddToCollection(nameEntry.Text, sa.ArticleID); err != nil {
+							dialog.ShowError(err, w)
+							return
+						}
+						reload()
+					}, w)
 			})
+			collectBtn.Importance = widget.LowImportance
 
 			dictHeader := container.NewHBox(
 				newPillLabel(sa.DictCode),
 				widget.NewLabel(results[0].DictName),
 				unstarBtn,
+				collectBtn,
 			)
+			if collection, ok := strings.CutPrefix(view, collectionViewPrefix); ok {
+				removeBtn := widget.NewButton("Remove from Collection", func() {
+					settings.RemoveFromCollection(collection, sa.ArticleID)
+					reload()
+					showFirst("This collection is empty")
+				})
+				removeBtn.Importance = widget.LowImportance
+				dictHeader.Add(removeBtn)
+			}
 			starredContent.Add(dictHeader)
 
+			// Tags: one removable chip per tag and an entry to add more
+			tagRow := container.NewHBox()
+			for _, tag := range settings.ArticleTags(sa.ArticleID) {
+				chip := widget.NewButtonWithIcon("#"+tag, theme.CancelIcon(), func() {
+					settings.UntagArticle(sa.ArticleID, tag)
+					reload()
+					showFirst("No starred articles")
+				})
+				chip.Importance = widget.LowImportance
+				chip.IconPlacement = widget.ButtonIconTrailingText
+				tagRow.Add(chip)
+			}
+			tagEntry := widget.NewEntry()
+			tagEntry.SetPlaceHolder("Add tag...")
+			tagEntry.OnSubmitted = func(tag string) {
+				if strings.TrimSpace(tag) == "" {
+					return
+				}
+				if err := settings.TagArticle(sa.ArticleID, tag); err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				reload()
+				// Keep the tagged article in view
+				for i, row := range rows {
+					if row.Heading == "" && row.Article.ArticleID == sa.ArticleID {
+						showStarredArticle(i)
+						starredWordList.Select(i)
+						return
+					}
+				}
+				showFirst("No starred articles")
+			}
+			starredContent.Add(container.NewBorder(nil, nil, tagRow, nil, tagEntry))
+			starredContent.Add(widget.NewSeparator())
+
 			for _, article := range results {
-				content := createSelectableArticleContent(article.Content)
+				content := createArticleContent(article.Content, "", nil)
 				starredContent.Add(content)
+				starredContent.Add(newNoteEditor(settings, article))
 				starredContent.Add(widget.NewSeparator())
 			}
 			starredContent.Refresh()
@@ -1269,6 +1737,10 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		}
 
 		starredWordList.OnSelected = func(id widget.ListItemID) {
+			if id < len(rows) && rows[id].Heading != "" {
+				starredWordList.Unselect(id)
+				return
+			}
 			showStarredArticle(int(id))
 		}
 
@@ -1277,32 +1749,90 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		filterEntry.SetPlaceHolder("Filter starred articles...")
 		filterEntry.OnChanged = func(text string) {
 			filterText = text
-			if text == "" {
-				filteredStarred = starred
-			} else {
-				filteredStarred = nil
-				for _, s := range starred {
-					if strings.Contains(strings.ToLower(s.Word), strings.ToLower(text)) {
-						filteredStarred = append(filteredStarred, s)
-					}
-				}
+			reload()
+			showFirst("No matching starred articles")
+		}
+
+		viewSelect.OnChanged = func(selected string) {
+			if selected == "" || selected == view {
+				return
 			}
-			starredWordList.Refresh()
-			if len(filteredStarred) > 0 {
-				showStarredArticle(0)
-				starredWordList.Select(0)
-			} else {
-				starredContent.RemoveAll()
-				starredContent.Add(widget.NewLabel("No matching starred articles"))
-				starredContentHeader.SetText("")
-				starredContent.Refresh()
+			view = selected
+			reload()
+			showFirst("No matching starred articles")
+		}
+
+		// Rename the selected tag or collection; renaming a tag onto an
+		// existing one merges them
+		renameViewBtn.OnTapped = func() {
+			var name, what string
+			switch {
+			case strings.HasPrefix(view, tagViewPrefix):
+				name, what = strings.TrimPrefix(view, tagViewPrefix), "Tag"
+			case strings.HasPrefix(view, collectionViewPrefix):
+				name, what = strings.TrimPrefix(view, collectionViewPrefix), "Collection"
+			default:
+				return
 			}
+			nameEntry := widget.NewEntry()
+			nameEntry.SetText(name)
+			items := []*widget.FormItem{widget.NewFormItem("New name", nameEntry)}
+			if what == "Tag" {
+				items[0].HintText = "Use an existing tag's name to merge the two"
+			}
+			dialog.ShowForm("Rename "+what, "Rename", "Cancel", items, func(ok bool) {
+				if !ok {
+					return
+				}
+				newName := strings.TrimSpace(nameEntry.Text)
+				var err error
+				if what == "Tag" {
+					err = settings.RenameTag(name, newName)
+					view = tagViewPrefix + newName
+				} else {
+					err = settings.RenameCollection(name, newName)
+					view = collectionViewPrefix + newName
+				}
+				if err != nil {
+					dialog.ShowError(err, w)
+					view = starredViewAll
+				}
+				// Merging keeps the existing tag's spelling
+				for _, v := range starredViews(settings.Tags(), settings.Collections()) {
+					if strings.EqualFold(v, view) {
+						view = v
+					}
+				}
+				reload()
+				showFirst("No matching starred articles")
+			}, w)
 		}
 
-		// Layout: sidebar with filter + word list | content area
+		groupByTagCheck := widget.NewCheck("Group by tag", func(checked bool) {
+			groupByTag = checked
+			settings.SetBool("starred_group_by_tag", checked)
+			reload()
+			showFirst("No matching starred articles")
+		})
+		groupByTagCheck.Checked = groupByTag
+
+		// Export the starred articles, or those of the selected tag
+		exportBtn := widget.NewButtonWithIcon("Export to Anki...", theme.DownloadIcon(), func() {
+			tag := ""
+			if strings.HasPrefix(view, tagViewPrefix) {
+				tag = strings.TrimPrefix(view, tagViewPrefix)
+			}
+			exportAnki(w, settings, db, tag)
+		})
+
+		// Layout: sidebar with filter, view and word list | content area
 		starredSidebar := container.NewBorder(
-			filterEntry,
-			nil, nil, nil,
+			container.NewVBox(
+				filterEntry,
+				container.NewBorder(nil, nil, nil, renameViewBtn, viewSelect),
+				groupByTagCheck,
+			),
+			exportBtn, nil, nil,
 			starredWordList,
 		)
 
@@ -1316,10 +1846,8 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		starredSplit.SetOffset(0.28)
 
 		// Show first article
-		if len(filteredStarred) > 0 {
-			showStarredArticle(0)
-			starredWordList.Select(0)
-		}
+		reload()
+		showFirst("No starred articles")
 
 		starredDialog := dialog.NewCustom("Starred Articles", "Close", starredSplit, w)
 		starredDialog.Resize(fyne.NewSize(900, 600))
@@ -1343,7 +1871,7 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		container.NewBorder(nil, nil, nil, container.NewPadded(shortcutPill), nil),
 	)
 	searchButtons := container.NewHBox(starredBtn, historyBtn)
-	searchRow := container.NewBorder(nil, nil, nil, searchButtons, searchWithHint)
+	searchRow := container.NewBorder(nil, nil, container.NewHBox(backBtn, forwardBtn), searchButtons, searchWithHint)
 
 	// Search mode radio buttons
 	modeGroup := widget.NewRadioGroup([]string{
@@ -1351,6 +1879,8 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		"Prefix",
 		"Contains",
 		"Full-text",
+		"Citation",
+		"Notes",
 	}, func(selected string) {
 		switch selected {
 		case "Exact":
@@ -1361,6 +1891,18 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			currentMode = search.ModeFuzzy
 		case "Full-text":
 			currentMode = search.ModeReverse
+		case "Citation":
+			currentMode = search.ModeCitation
+		case "Notes":
+			currentMode = modeNotes
+		}
+		switch currentMode {
+		case search.ModeCitation:
+			searchEntry.SetPlaceHolder("Cited passage, e.g. RV. x, 129 or Bg 2.47...")
+		case modeNotes:
+			searchEntry.SetPlaceHolder("Search your notes...")
+		default:
+			searchEntry.SetPlaceHolder("Search in Devanagari or IAST...")
 		}
 		// Re-search with new mode
 		if searchEntry.Text != "" {
@@ -1370,6 +1912,164 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 	modeGroup.SetSelected("Exact")
 	modeGroup.Horizontal = true
 
+	// showEntry puts a navigation entry's query and mode back and searches
+	// right away instead of waiting for search-as-you-type
+	showEntry := func(e state.NavEntry) {
+		pendingArticleID = e.ArticleID
+		searchEntry.SetText(e.Query)
+		searchDebouncer.Cancel()
+		if e.Mode != "" && modeGroup.Selected != e.Mode {
+			modeGroup.SetSelected(e.Mode) // Re-searches with the new text
+			return
+		}
+		doSearch(e.Query)
+	}
+
+	// Following a cross-reference looks the word up as a headword
+	followLink = func(word string) {
+		e := state.NavEntry{Query: word, Mode: "Exact"}
+		nav.Visit(e)
+		navChanged()
+		showEntry(e)
+	}
+
+	goBack := func() {
+		if e, ok := nav.Back(); ok {
+			navChanged()
+			showEntry(e)
+		}
+	}
+	goForward := func() {
+		if e, ok := nav.Forward(); ok {
+			navChanged()
+			showEntry(e)
+		}
+	}
+	backBtn.OnTapped = goBack
+	forwardBtn.OnTapped = goForward
+
+	// openHistory repeats a search from history in its mode and dictionaries,
+	// showing the article that was opened from it
+	openHistory := func(e state.HistoryEntry) {
+		var codes []string
+		for _, code := range e.DictCodes {
+			if dictByCode[code].Code != "" {
+				codes = append(codes, code)
+			}
+		}
+		if len(codes) > 0 && strings.Join(codes, ",") != strings.Join(dictOrder, ",") {
+			dictOrder = codes
+			saveDictOrder()
+		}
+		showEntry(state.NavEntry{Query: e.Query, Mode: e.Mode, ArticleID: e.ArticleID})
+	}
+
+	var historyWindow *HistoryWindow
+	showHistoryWindow := func() {
+		if settings == nil {
+			return
+		}
+		if historyWindow == nil || historyWindow.IsClosed() {
+			historyWindow = NewHistoryWindow(a, settings, func(e state.HistoryEntry) {
+				openHistory(e)
+				w.RequestFocus()
+			})
+		}
+		historyWindow.Show()
+	}
+
+	// reloadDicts picks up dictionaries added or removed in the dictionary
+	// manager: new ones become active, removed ones leave the order
+	reloadDicts := func() {
+		dicts, err := db.GetDicts()
+		if err != nil {
+			log.Printf("Warning: Could not load dictionaries: %v", err)
+			return
+		}
+		var added []string
+		for _, d := range dicts {
+			if dictByCode[d.Code].Code == "" {
+				added = append(added, d.Code)
+			}
+		}
+		allDicts = dicts
+		clear(dictByCode)
+		for _, d := range allDicts {
+			dictByCode[d.Code] = d
+		}
+		var order []string
+		for _, code := range dictOrder {
+			if dictByCode[code].Code != "" {
+				order = append(order, code)
+			}
+		}
+		dictOrder = append(order, added...)
+		saveDictOrder()
+		if searchEntry.Text != "" {
+			doSearch(searchEntry.Text)
+		}
+	}
+
+	var dictManagerWindow *DictManagerWindow
+	showDictManager := func() {
+		if db == nil {
+			return
+		}
+		if dictManagerWindow == nil || dictManagerWindow.IsClosed() {
+			dictManagerWindow = NewDictManagerWindow(a, db, settings, reloadDicts)
+		}
+		dictManagerWindow.Show()
+	}
+
+	historyBtn.OnTapped = func() {
+		if settings == nil {
+			return
+		}
+		history := settings.SearchHistory("", 20)
+		if len(history) == 0 {
+			dialog.ShowInformation("History", "No search history yet", w)
+			return
+		}
+
+		// Create list of history items with their context
+		historyList := widget.NewList(
+			func() int { return len(history) },
+			func() fyne.CanvasObject {
+				context := widget.NewLabel("context")
+				context.Importance = widget.LowImportance
+				return container.NewHBox(widget.NewLabel("history item"), context)
+			},
+			func(id widget.ListItemID, obj fyne.CanvasObject) {
+				if id < len(history) {
+					row := obj.(*fyne.Container)
+					row.Objects[0].(*widget.Label).SetText(history[id].Query)
+					row.Objects[1].(*widget.Label).SetText(historyContext(history[id]))
+				}
+			},
+		)
+
+		var historyDialog dialog.Dialog
+		historyList.OnSelected = func(id widget.ListItemID) {
+			if id < len(history) {
+				historyDialog.Hide()
+				openHistory(history[id])
+			}
+		}
+
+		listScroll := container.NewVScroll(historyList)
+		listScroll.SetMinSize(fyne.NewSize(400, 300))
+		allBtn := widget.NewButtonWithIcon("All History...", theme.HistoryIcon(), func() {
+			historyDialog.Hide()
+			showHistoryWindow()
+		})
+
+		historyDialog = dialog.NewCustom("Search History", "Close", container.NewBorder(nil, allBtn, nil, nil, listScroll), w)
+		historyDialog.Resize(fyne.NewSize(450, 450))
+		historyDialog.Show()
+	}
+	searchEntry.onBack = goBack
+	searchEntry.onForward = goForward
+
 	// Group results checkbox
 	groupCheck := widget.NewCheck("Group results", func(checked bool) {
 		groupResultsSetting = checked
@@ -1578,6 +2278,37 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		dataLink := widget.NewHyperlink("www.sanskrit-lexicon.uni-koeln.de", nil)
 		dataLink.SetURLFromString("https://www.sanskrit-lexicon.uni-koeln.de/")
 
+		// Folder with scans of the printed dictionaries, one subfolder per dictionary
+		scanDirRow := newFolderSettingRow(w, settings, "Page scans:", "scan_dir", nil)
+
+		// Shared folder (Syncthing, Dropbox) for syncing stars and notes
+		syncDirRow := newFolderSettingRow(w, settings, "Sync folder:", "sync_dir", func(dir string) {
+			if dir != "" {
+				go syncNow(settings)
+			}
+		})
+
+		// Move settings, history, stars and notes between computers
+		exportDataBtn := widget.NewButton("Export...", func() {
+			exportUserData(w, settings)
+		})
+		importDataBtn := widget.NewButton("Import...", func() {
+			importUserData(w, settings)
+		})
+		if settings == nil {
+			exportDataBtn.Disable()
+			importDataBtn.Disable()
+		}
+		userDataRow := container.NewBorder(nil, nil, widget.NewLabel("User data:"),
+			container.NewHBox(exportDataBtn, importDataBtn))
+
+		// Separate settings, history, stars and notes for each user
+		currentProfile := state.DefaultProfile
+		if settings != nil {
+			currentProfile = settings.Profile()
+		}
+		profileRow := newProfileRow(w, a, currentProfile)
+
 		dialogContent := container.NewVBox(
 			widget.NewLabel(""), // spacer
 			aboutTitle,
@@ -1587,10 +2318,16 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			aboutText,
 			container.NewCenter(dataLink),
 			widget.NewLabel(""), // spacer
+			widget.NewSeparator(),
+			profileRow,
+			newDataRow(w, showDictManager),
+			scanDirRow,
+			syncDirRow,
+			userDataRow,
 		)
 
 		dlg := dialog.NewCustom("About", "Close", dialogContent, w)
-		dlg.Resize(fyne.NewSize(400, 250))
+		dlg.Resize(fyne.NewSize(500, 400))
 		dlg.Show()
 	})
 
@@ -1608,7 +2345,7 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 			// Show setup wizard
 			ShowOCRSetupDialog(w, a, func() {
 				// After setup completes, open OCR window
-				ocrWindow = NewOCRWindow(a, w, searchEntry, doSearch)
+				ocrWindow = NewOCRWindow(a, w, &searchEntry.Entry, doSearch)
 				ocrWindow.Show()
 			})
 			return
@@ -1616,7 +2353,7 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 
 		// Credentials OK - open or focus OCR window
 		if ocrWindow == nil || ocrWindow.IsClosed() {
-			ocrWindow = NewOCRWindow(a, w, searchEntry, doSearch)
+			ocrWindow = NewOCRWindow(a, w, &searchEntry.Entry, doSearch)
 		}
 		ocrWindow.Show()
 	})
@@ -1630,8 +2367,21 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		editorWindow.Show()
 	})
 
-	// Toolbar: mode + group checkbox on left, zoom + ocr + editor + settings on right
-	toolbarRight := container.NewHBox(zoomControl, widget.NewSeparator(), ocrBtn, editorBtn, settingsBtn)
+	// Review button - opens flashcard review of starred articles
+	var reviewWindow *ReviewWindow
+	reviewBtn := widget.NewButtonWithIcon("Review", theme.MediaReplayIcon(), func() {
+		if settings == nil || db == nil {
+			dialog.ShowInformation("Review", "Review needs the dictionary and settings databases.", w)
+			return
+		}
+		if reviewWindow == nil || reviewWindow.IsClosed() {
+			reviewWindow = NewReviewWindow(a, settings, db, allDicts)
+		}
+		reviewWindow.Show()
+	})
+
+	// Toolbar: mode + group checkbox on left, zoom + ocr + editor + review + settings on right
+	toolbarRight := container.NewHBox(zoomControl, widget.NewSeparator(), ocrBtn, editorBtn, reviewBtn, settingsBtn)
 	toolbar := container.NewBorder(nil, nil, nil, toolbarRight,
 		container.NewHBox(modeGroup, widget.NewSeparator(), groupCheck, widget.NewSeparator(), dictsBtn),
 	)
@@ -1650,8 +2400,11 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 	updateLabel := widget.NewLabel("")
 	updateLabel.Hide()
 
-	// Version container: version label + update indicator
-	versionContainer := container.NewHBox(updateLabel, versionLabel)
+	// Newer dictionary data, downloaded on request
+	dataUpdateBtn := newDataUpdateButton(w, db, setStatus, reloadDicts)
+
+	// Version container: version label + update indicators
+	versionContainer := container.NewHBox(dataUpdateBtn, updateLabel, versionLabel)
 
 	// Check for updates in background
 	go func() {
@@ -1679,7 +2432,8 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 	// Add padding
 	padded := container.NewPadded(content)
 
-	w.SetContent(padded)
+	// Tooltips are drawn above everything else in the window
+	w.SetContent(container.NewStack(padded, tooltips.layer))
 
 	// Keyboard shortcuts
 	w.Canvas().AddShortcut(&desktop.CustomShortcut{
@@ -1696,6 +2450,22 @@ func buildMainUI(w fyne.Window, a fyne.App, db *search.DB, settings *state.Store
 		w.Canvas().Focus(searchEntry)
 	})
 
+	// Alt+Left/Alt+Right go back and forward (the search field handles them
+	// itself while focused). Mouse back/forward buttons are not bound: Fyne
+	// reports every extra mouse button as button 0.
+	w.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName:  fyne.KeyLeft,
+		Modifier: fyne.KeyModifierAlt,
+	}, func(shortcut fyne.Shortcut) {
+		goBack()
+	})
+	w.Canvas().AddShortcut(&desktop.CustomShortcut{
+		KeyName:  fyne.KeyRight,
+		Modifier: fyne.KeyModifierAlt,
+	}, func(shortcut fyne.Shortcut) {
+		goForward()
+	})
+
 	// Zoom helper function
 	changeZoom := func(delta int) {
 		// Find current index in zoomOptions
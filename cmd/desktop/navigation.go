@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// maxNavEntries caps the back/forward list
+const maxNavEntries = 100
+
+// navHistory is a browser-style back/forward list of what the user viewed:
+// the query, the search mode and the selected article
+type navHistory struct {
+	entries []state.NavEntry
+	pos     int // Index of the current entry, -1 when empty
+}
+
+// newNavHistory restores a history saved by a previous session
+func newNavHistory(entries []state.NavEntry, current int) *navHistory {
+	if current < 0 || current >= len(entries) {
+		current = len(entries) - 1
+	}
+	return &navHistory{entries: entries, pos: current}
+}
+
+// sameView reports whether two entries show the same search
+func sameView(a, b state.NavEntry) bool {
+	return a.Query == b.Query && a.Mode == b.Mode
+}
+
+// Visit records e as the current entry, dropping any forward entries
+func (h *navHistory) Visit(e state.NavEntry) {
+	if e.Query == "" {
+		return
+	}
+	if h.pos >= 0 && sameView(h.entries[h.pos], e) {
+		return
+	}
+	h.entries = append(h.entries[:h.pos+1], e)
+	if len(h.entries) > maxNavEntries {
+		h.entries = h.entries[len(h.entries)-maxNavEntries:]
+	}
+	h.pos = len(h.entries) - 1
+}
+
+// Refine records a search typed by the user. Extending or shortening the
+// current query replaces the current entry, so search-as-you-type does not
+// leave one entry per keystroke.
+func (h *navHistory) Refine(e state.NavEntry) {
+	if h.pos >= 0 {
+		cur := h.entries[h.pos]
+		if sameView(cur, e) {
+			return
+		}
+		if cur.Mode == e.Mode && (strings.HasPrefix(e.Query, cur.Query) || strings.HasPrefix(cur.Query, e.Query)) {
+			h.entries = append(h.entries[:h.pos], e)
+			return
+		}
+	}
+	h.Visit(e)
+}
+
+// SetArticle remembers the article selected in the current entry
+func (h *navHistory) SetArticle(articleID int64) {
+	if h.pos >= 0 {
+		h.entries[h.pos].ArticleID = articleID
+	}
+}
+
+// Snapshot returns a copy of the entries and the current index for saving
+func (h *navHistory) Snapshot() ([]state.NavEntry, int) {
+	return append([]state.NavEntry(nil), h.entries...), h.pos
+}
+
+// CanGoBack reports whether there is an entry before the current one
+func (h *navHistory) CanGoBack() bool {
+	return h.pos > 0
+}
+
+// CanGoForward reports whether there is an entry after the current one
+func (h *navHistory) CanGoForward() bool {
+	return h.pos < len(h.entries)-1
+}
+
+// Back moves to the previous entry and returns it
+func (h *navHistory) Back() (state.NavEntry, bool) {
+	if !h.CanGoBack() {
+		return state.NavEntry{}, false
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+// Forward moves to the next entry and returns it
+func (h *navHistory) Forward() (state.NavEntry, bool) {
+	if !h.CanGoForward() {
+		return state.NavEntry{}, false
+	}
+	h.pos++
+	return h.entries[h.pos], true
+}
+
+// modeLabel returns the search mode's label in the mode selector
+func modeLabel(mode search.SearchMode) string {
+	switch mode {
+	case search.ModePrefix:
+		return "Prefix"
+	case search.ModeFuzzy:
+		return "Contains"
+	case search.ModeReverse:
+		return "Full-text"
+	case search.ModeCitation:
+		return "Citation"
+	case modeNotes:
+		return "Notes"
+	default:
+		return "Exact"
+	}
+}
+
+// findArticle returns the index of the group containing articleID, or -1
+func findArticle(groups []GroupedResult, articleID int64) int {
+	for i, gr := range groups {
+		for _, e := range gr.Entries {
+			for _, r := range e.Articles {
+				if r.ArticleID == articleID {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// modeNotes searches the user's notes instead of the dictionaries
+const modeNotes search.SearchMode = -1
+
+// searchNotes finds notes matching query and returns their articles as
+// search results, keeping only the given dictionaries if any are given
+func searchNotes(settings *state.Store, query string, dictCodes []string, dicts map[string]search.Dict) ([]search.Result, error) {
+	if settings == nil {
+		return nil, nil
+	}
+	notes, err := settings.SearchNotes(query)
+	if err != nil {
+		return nil, err
+	}
+	var results []search.Result
+	for _, n := range notes {
+		if len(dictCodes) > 0 && !containsString(dictCodes, n.DictCode) {
+			continue
+		}
+		results = append(results, search.Result{
+			DictCode:  n.DictCode,
+			DictName:  dicts[n.DictCode].Name,
+			ArticleID: n.ArticleID,
+			Word:      n.Word,
+		})
+	}
+	return results, nil
+}
+
+// newNoteEditor shows the user's note on an article under it: the note
+// rendered from markdown with an Edit button, or an Add Note button.
+// Edits are saved as the user types; saving a note stars the article.
+func newNoteEditor(settings *state.Store, r search.Result) fyne.CanvasObject {
+	holder := container.NewVBox()
+	if settings == nil {
+		return holder
+	}
+
+	text := ""
+	if n, ok := settings.GetNote(r.ArticleID); ok {
+		text = n.Text
+	}
+	saver := newDebouncer(time.Second)
+	save := func(t string) {
+		settings.SaveNote(r.ArticleID, r.Word, r.DictCode, t)
+	}
+
+	var showNote, editNote func()
+	showNote = func() {
+		holder.RemoveAll()
+		if strings.TrimSpace(text) == "" {
+			addBtn := widget.NewButtonWithIcon("Add Note", theme.DocumentCreateIcon(), editNote)
+			addBtn.Importance = widget.LowImportance
+			holder.Add(container.NewHBox(layout.NewSpacer(), addBtn))
+		} else {
+			title := widget.NewLabel("Note")
+			title.TextStyle = fyne.TextStyle{Bold: true}
+			editBtn := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), editNote)
+			editBtn.Importance = widget.LowImportance
+			note := widget.NewRichTextFromMarkdown(text)
+			note.Wrapping = fyne.TextWrapWord
+			holder.Add(container.NewBorder(nil, nil, title, editBtn))
+			holder.Add(note)
+		}
+		holder.Refresh()
+	}
+	editNote = func() {
+		holder.RemoveAll()
+		entry := widget.NewMultiLineEntry()
+		entry.Wrapping = fyne.TextWrapWord
+		entry.SetMinRowsVisible(4)
+		entry.SetPlaceHolder("Your note (markdown)...")
+		entry.SetText(text)
+		entry.OnChanged = func(t string) {
+			text = t
+			saver.Do(func() { save(t) })
+		}
+		doneBtn := widget.NewButtonWithIcon("Done", theme.ConfirmIcon(), func() {
+			saver.Cancel()
+			save(text)
+			showNote()
+		})
+		doneBtn.Importance = widget.LowImportance
+		title := widget.NewLabel("Note")
+		title.TextStyle = fyne.TextStyle{Bold: true}
+		holder.Add(container.NewBorder(nil, nil, title, doneBtn))
+		holder.Add(entry)
+		holder.Refresh()
+		if c := fyne.CurrentApp().Driver().CanvasForObject(holder); c != nil {
+			c.Focus(entry)
+		}
+	}
+	showNote()
+	return holder
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// switchProfile remembers a profile for the next start and restarts the
+// app with it
+func switchProfile(a fyne.App, profile string) error {
+	if err := state.SetLastProfile(profile); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := exec.Command(exe, "-profile", profile).Start(); err != nil {
+		return err
+	}
+	a.Quit()
+	return nil
+}
+
+// hasProfile reports whether name is one of profiles. Names are compared
+// ignoring case, as profiles differing only in case would share a folder
+// on case-insensitive file systems.
+func hasProfile(profiles []string, name string) bool {
+	return slices.ContainsFunc(profiles, func(p string) bool { return strings.EqualFold(p, name) })
+}
+
+// showProfilePicker shows a window to choose which of several profiles to
+// open, and restarts the app with the chosen one
+func showProfilePicker(a fyne.App, profiles []string, last string) {
+	w := a.NewWindow("Sanskrit Upāya")
+	profileSelect := widget.NewSelect(profiles, nil)
+	profileSelect.SetSelected(last)
+	openBtn := widget.NewButton("Open", func() {
+		if err := switchProfile(a, profileSelect.Selected); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to open profile: %v", err), w)
+		}
+	})
+	openBtn.Importance = widget.HighImportance
+	w.SetContent(container.NewPadded(container.NewVBox(
+		widget.NewLabel("Choose a profile:"),
+		profileSelect,
+		openBtn,
+	)))
+	w.Resize(fyne.NewSize(360, 0))
+	w.CenterOnScreen()
+	w.ShowAndRun()
+}
+
+// newProfileRow is the settings row to switch between, create and delete
+// profiles. The open profile can't be deleted.
+func newProfileRow(w fyne.Window, a fyne.App, current string) fyne.CanvasObject {
+	profiles, err := state.Profiles()
+	if err != nil {
+		profiles = []string{current}
+	}
+	if !hasProfile(profiles, current) {
+		profiles = append(profiles, current)
+	}
+
+	var switchBtn, deleteBtn *widget.Button
+	profileSelect := widget.NewSelect(profiles, func(selected string) {
+		if selected == current {
+			switchBtn.Disable()
+			deleteBtn.Disable()
+			return
+		}
+		switchBtn.Enable()
+		if selected == state.DefaultProfile {
+			deleteBtn.Disable()
+		} else {
+			deleteBtn.Enable()
+		}
+	})
+
+	switchTo := func(profile string) {
+		dialog.ShowConfirm("Switch Profile",
+			fmt.Sprintf("Restart with profile %q?", profile),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := switchProfile(a, profile); err != nil {
+					dialog.ShowError(fmt.Errorf("Failed to switch profile: %v", err), w)
+				}
+			}, w)
+	}
+
+	switchBtn = widget.NewButton("Switch", func() {
+		switchTo(profileSelect.Selected)
+	})
+	newBtn := widget.NewButton("New...", func() {
+		nameEntry := widget.NewEntry()
+		nameEntry.SetPlaceHolder("Name")
+		nameEntry.Validator = state.ValidateProfile
+		dialog.ShowForm("New Profile", "Create", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("Profile", nameEntry)},
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				name := nameEntry.Text
+				if hasProfile(profiles, name) {
+					dialog.ShowInformation("New Profile", fmt.Sprintf("Profile %q already exists", name), w)
+					return
+				}
+				store, err := state.OpenProfile(name)
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("Failed to create profile: %v", err), w)
+					return
+				}
+				store.Close()
+				profiles = append(profiles, name)
+				profileSelect.SetOptions(profiles)
+				profileSelect.SetSelected(name)
+				switchTo(name)
+			}, w)
+	})
+	deleteBtn = widget.NewButton("Delete", func() {
+		profile := profileSelect.Selected
+		dialog.ShowConfirm("Delete Profile",
+			fmt.Sprintf("Delete profile %q with its settings, history, starred articles and notes?", profile),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := state.DeleteProfile(profile); err != nil {
+					dialog.ShowError(fmt.Errorf("Failed to delete profile: %v", err), w)
+					return
+				}
+				profiles = slices.DeleteFunc(profiles, func(p string) bool { return p == profile })
+				profileSelect.SetOptions(profiles)
+				profileSelect.SetSelected(current)
+			}, w)
+	})
+	profileSelect.SetSelected(current)
+
+	return container.NewBorder(nil, nil, widget.NewLabel("Profile:"),
+		container.NewHBox(switchBtn, newBtn, deleteBtn), profileSelect)
+}
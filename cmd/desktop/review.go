@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+	"github.com/licht1stein/sanskrit-upaya/pkg/transliterate"
+)
+
+const (
+	// New starred articles introduced into review per day
+	reviewNewPerDay = 20
+
+	scriptIAST       = "IAST"
+	scriptDevanagari = "Devanagari"
+	// Back of the card from the dictionary the article was starred in
+	starredDictOption = "Starred dictionary"
+)
+
+// reviewGrades are the grading buttons, bound to keys 1-4
+var reviewGrades = []struct {
+	label string
+	grade state.Grade
+}{
+	{"Again", state.GradeAgain},
+	{"Hard", state.GradeHard},
+	{"Good", state.GradeGood},
+	{"Easy", state.GradeEasy},
+}
+
+// ReviewWindow runs flashcard reviews of starred articles: the headword on
+// the front, a dictionary definition on the back.
+type ReviewWindow struct {
+	window   fyne.Window
+	settings *state.Store
+	db       *search.DB
+	dicts    []search.Dict
+
+	queue    []state.Card
+	revealed bool
+
+	status    *widget.Label
+	front     *canvas.Text
+	back      *fyne.Container
+	revealBtn *widget.Button
+	gradeBar  *fyne.Container
+
+	closed bool
+}
+
+// NewReviewWindow creates a review window for the cards due now
+func NewReviewWindow(app fyne.App, settings *state.Store, db *search.DB, dicts []search.Dict) *ReviewWindow {
+	w := &ReviewWindow{
+		settings: settings,
+		db:       db,
+		dicts:    dicts,
+	}
+
+	w.window = app.NewWindow("Review")
+	w.window.Resize(fyne.NewSize(600, 500))
+	w.window.SetOnClosed(func() {
+		w.closed = true
+	})
+
+	w.buildUI()
+	w.reload()
+	return w
+}
+
+func (w *ReviewWindow) buildUI() {
+	scriptSelect := widget.NewSelect([]string{scriptIAST, scriptDevanagari}, func(string) {})
+	scriptSelect.SetSelected(scriptIAST)
+	if saved := w.settings.Get("review_script"); saved != "" {
+		scriptSelect.SetSelected(saved)
+	}
+	scriptSelect.OnChanged = func(selected string) {
+		w.settings.Set("review_script", selected)
+		w.showCard()
+	}
+
+	dictOptions := []string{starredDictOption}
+	for _, d := range w.dicts {
+		dictOptions = append(dictOptions, d.Name)
+	}
+	dictSelect := widget.NewSelect(dictOptions, func(string) {})
+	dictSelect.SetSelected(starredDictOption)
+	if saved := w.settings.Get("review_dict"); saved != "" {
+		for _, d := range w.dicts {
+			if d.Code == saved {
+				dictSelect.SetSelected(d.Name)
+			}
+		}
+	}
+	dictSelect.OnChanged = func(selected string) {
+		code := ""
+		for _, d := range w.dicts {
+			if d.Name == selected {
+				code = d.Code
+			}
+		}
+		w.settings.Set("review_dict", code)
+		w.showCard()
+	}
+
+	w.status = widget.NewLabel("")
+	options := container.NewHBox(
+		widget.NewLabel("Front:"), scriptSelect,
+		widget.NewLabel("Back:"), dictSelect,
+		layout.NewSpacer(), w.status,
+	)
+
+	w.front = canvas.NewText("", theme.Color(theme.ColorNameForeground))
+	w.front.TextSize = 32
+	w.front.TextStyle = fyne.TextStyle{Bold: true}
+	w.front.Alignment = fyne.TextAlignCenter
+
+	w.back = container.NewStack()
+
+	w.revealBtn = widget.NewButtonWithIcon("Show Answer (Space)", theme.VisibilityIcon(), w.reveal)
+	w.revealBtn.Importance = widget.HighImportance
+
+	w.gradeBar = container.NewGridWithColumns(len(reviewGrades))
+	for i, g := range reviewGrades {
+		grade := g.grade
+		w.gradeBar.Add(widget.NewButton(fmt.Sprintf("%s (%d)", g.label, i+1), func() {
+			w.grade(grade)
+		}))
+	}
+
+	w.window.SetContent(container.NewBorder(
+		container.NewVBox(options, widget.NewSeparator(), container.NewPadded(w.front), widget.NewSeparator()),
+		container.NewStack(w.revealBtn, w.gradeBar),
+		nil, nil,
+		container.NewVScroll(w.back),
+	))
+
+	// Space or Enter shows the answer, 1-4 grade it
+	w.window.Canvas().SetOnTypedKey(func(ev *fyne.KeyEvent) {
+		switch ev.Name {
+		case fyne.KeySpace, fyne.KeyReturn, fyne.KeyEnter:
+			w.reveal()
+		case fyne.Key1, fyne.Key2, fyne.Key3, fyne.Key4:
+			if w.revealed {
+				w.grade(reviewGrades[ev.Name[0]-'1'].grade)
+			}
+		}
+	})
+}
+
+// reload fetches the cards due now
+func (w *ReviewWindow) reload() {
+	w.queue = w.settings.DueCards(time.Now(), reviewNewPerDay)
+	w.showCard()
+}
+
+// showCard shows the front of the first card in the queue
+func (w *ReviewWindow) showCard() {
+	w.revealed = false
+	w.back.Objects = nil
+	w.back.Refresh()
+	w.gradeBar.Hide()
+
+	due, unseen := w.settings.ReviewCounts(time.Now())
+	w.status.SetText(fmt.Sprintf("%d due · %d new", due, unseen))
+
+	if len(w.queue) == 0 {
+		w.front.Text = "No cards to review"
+		w.front.Refresh()
+		w.back.Objects = []fyne.CanvasObject{widget.NewLabelWithStyle(
+			"Star articles to add them to your review.", fyne.TextAlignCenter, fyne.TextStyle{Italic: true})}
+		w.back.Refresh()
+		w.revealBtn.Hide()
+		return
+	}
+
+	word := w.queue[0].Word
+	if w.settings.Get("review_script") == scriptDevanagari {
+		word = transliterate.IASTToDevanagari(word)
+	}
+	w.front.Text = word
+	w.front.Refresh()
+	w.revealBtn.Show()
+}
+
+// reveal shows the back of the current card
+func (w *ReviewWindow) reveal() {
+	if w.revealed || len(w.queue) == 0 {
+		return
+	}
+	w.revealed = true
+
+	content, dictCode := w.definition(w.queue[0])
+	w.back.Objects = []fyne.CanvasObject{createArticleContent(content, dictCode, nil)}
+	w.back.Refresh()
+	w.revealBtn.Hide()
+	w.gradeBar.Show()
+}
+
+// definition returns the card's article in the chosen back dictionary,
+// falling back to the starred article when that dictionary lacks the word
+func (w *ReviewWindow) definition(c state.Card) (content, dictCode string) {
+	if code := w.settings.Get("review_dict"); code != "" && code != c.DictCode {
+		results, err := w.db.Search(c.Word, search.ModeExact, []string{code})
+		if err == nil && len(results) > 0 {
+			return results[0].Content, code
+		}
+	}
+	content, err := w.db.GetArticleContent(c.ArticleID)
+	if err != nil {
+		return err.Error(), ""
+	}
+	return content, c.DictCode
+}
+
+// grade records the review of the current card and moves on. Forgotten
+// cards go back to the end of the session queue.
+func (w *ReviewWindow) grade(g state.Grade) {
+	if !w.revealed || len(w.queue) == 0 {
+		return
+	}
+	card, err := w.settings.GradeCard(w.queue[0].ArticleID, g, time.Now())
+	w.queue = w.queue[1:]
+	if err == nil && card.Interval == 0 {
+		w.queue = append(w.queue, card)
+	}
+	if len(w.queue) == 0 {
+		w.reload()
+		return
+	}
+	w.showCard()
+}
+
+// Show displays the window, refreshing the queue
+func (w *ReviewWindow) Show() {
+	if !w.revealed {
+		w.reload()
+	}
+	w.window.Show()
+	w.window.RequestFocus()
+}
+
+// IsClosed returns true if the window was closed
+func (w *ReviewWindow) IsClosed() bool {
+	return w.closed
+}
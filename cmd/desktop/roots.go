@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// familyWord is a word of a root family with the dictionaries listing it
+type familyWord struct {
+	Word  string
+	Dicts []string
+}
+
+// groupFamily merges a root family's articles into one row per word for
+// each kind of derivation, keeping the database order
+func groupFamily(family []search.Derivative) map[article.DerivationKind][]familyWord {
+	groups := make(map[article.DerivationKind][]familyWord)
+	index := make(map[string]int)
+	for _, d := range family {
+		key := string(d.Kind) + "\x00" + d.Word
+		if i, ok := index[key]; ok {
+			fw := &groups[d.Kind][i]
+			if !containsString(fw.Dicts, d.DictCode) {
+				fw.Dicts = append(fw.Dicts, d.DictCode)
+			}
+			continue
+		}
+		index[key] = len(groups[d.Kind])
+		groups[d.Kind] = append(groups[d.Kind], familyWord{Word: d.Word, Dicts: []string{d.DictCode}})
+	}
+	return groups
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// showRootFamily opens the "Root family" panel for a word: the roots it
+// belongs to and, for the chosen root, its own entries and its primary and
+// secondary derivatives. Choosing a word looks it up via onSelect.
+func showRootFamily(w fyne.Window, db *search.DB, word string, onSelect func(word string)) {
+	roots, err := db.RootOf(word)
+	if errors.Is(err, search.ErrNoRoots) {
+		dialog.ShowInformation("Root Family", "This database has no root index.\nDownload the latest database to browse root families.", w)
+		return
+	}
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	if len(roots) == 0 {
+		// A root without an entry of its own may still have derivatives
+		roots = []string{article.NormalizeRoot(word)}
+	}
+
+	var dlg dialog.Dialog
+	body := container.NewStack()
+	showRoot := func(root string) {
+		family, err := db.WordsFromRoot(root)
+		if err != nil {
+			body.Objects = []fyne.CanvasObject{widget.NewLabel(err.Error())}
+			body.Refresh()
+			return
+		}
+		groups := groupFamily(family)
+
+		tabs := container.NewAppTabs()
+		for _, g := range []struct {
+			kind  article.DerivationKind
+			title string
+		}{
+			{article.Primary, "Primary"},
+			{article.Secondary, "Secondary"},
+			{article.RootEntry, "Root entries"},
+		} {
+			words := groups[g.kind]
+			if len(words) == 0 {
+				continue
+			}
+			list := widget.NewList(
+				func() int { return len(words) },
+				func() fyne.CanvasObject {
+					dicts := widget.NewLabel("dicts")
+					dicts.Importance = widget.LowImportance
+					return container.NewBorder(nil, nil, nil, dicts, widget.NewLabel("word"))
+				},
+				func(id widget.ListItemID, obj fyne.CanvasObject) {
+					row := obj.(*fyne.Container)
+					row.Objects[0].(*widget.Label).SetText(words[id].Word)
+					row.Objects[1].(*widget.Label).SetText(strings.Join(words[id].Dicts, ", "))
+				},
+			)
+			list.OnSelected = func(id widget.ListItemID) {
+				dlg.Hide()
+				onSelect(words[id].Word)
+			}
+			tabs.Append(container.NewTabItem(fmt.Sprintf("%s (%d)", g.title, len(words)), list))
+		}
+
+		if len(tabs.Items) == 0 {
+			body.Objects = []fyne.CanvasObject{widget.NewLabel(fmt.Sprintf("No words from √%s were found.", root))}
+		} else {
+			body.Objects = []fyne.CanvasObject{tabs}
+		}
+		body.Refresh()
+	}
+
+	var top fyne.CanvasObject
+	var rootSelect *widget.Select
+	if len(roots) == 1 {
+		label := widget.NewLabel("√" + roots[0])
+		label.TextStyle = fyne.TextStyle{Bold: true}
+		top = label
+	} else {
+		options := make([]string, len(roots))
+		for i, r := range roots {
+			options[i] = "√" + r
+		}
+		rootSelect = widget.NewSelect(options, func(selected string) {
+			showRoot(strings.TrimPrefix(selected, "√"))
+		})
+		top = container.NewBorder(nil, nil, widget.NewLabel("Root:"), nil, rootSelect)
+	}
+
+	dlg = dialog.NewCustom("Root Family of "+word, "Close", container.NewBorder(top, nil, nil, nil, body), w)
+	if rootSelect != nil {
+		rootSelect.SetSelectedIndex(0)
+	} else {
+		showRoot(roots[0])
+	}
+	dlg.Resize(fyne.NewSize(450, 500))
+	dlg.Show()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/scan"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// showPageReference puts an article's printed page under it, opening the
+// scan of that page when tapped. Articles without a page are left alone.
+func showPageReference(view *articleView, r search.Result, openScan func(dictCode, page string)) {
+	if ref := article.PageReference("", r.Page); ref != "" {
+		view.SetPage(ref, func() { openScan(r.DictCode, r.Page) })
+	}
+}
+
+// openPageScan opens the scan of a printed page from the scan folder with
+// the system viewer.
+func openPageScan(a fyne.App, w fyne.Window, dir, dictCode, page string) {
+	number, _, ok := article.ParsePage(page)
+	if !ok {
+		return
+	}
+	if dir == "" {
+		dialog.ShowInformation("Page Scans", "Choose the folder with your page scans in Settings first.", w)
+		return
+	}
+	path, err := scan.Find(dir, dictCode, number)
+	if err != nil {
+		dialog.ShowInformation("Page Scans", fmt.Sprintf("No scan of %s was found in\n%s", article.PageReference(dictCode, page), dir), w)
+		return
+	}
+	u, err := url.Parse(storage.NewFileURI(path).String())
+	if err == nil {
+		err = a.OpenURL(u)
+	}
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("open page scan: %w", err), w)
+	}
+}
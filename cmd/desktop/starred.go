@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// Views of the starred dialog besides all starred articles
+const (
+	starredViewAll       = "All starred"
+	tagViewPrefix        = "Tag: "
+	collectionViewPrefix = "Collection: "
+	untaggedHeading      = "Untagged"
+)
+
+// starredRow is a row of the starred list: a tag heading or an article
+type starredRow struct {
+	Heading string // Set for headings only
+	Article state.StarredArticle
+}
+
+// starredViews lists the choices of the starred dialog's view selector
+func starredViews(tags []state.Tag, collections []state.Collection) []string {
+	views := []string{starredViewAll}
+	for _, t := range tags {
+		views = append(views, tagViewPrefix+t.Name)
+	}
+	for _, c := range collections {
+		views = append(views, collectionViewPrefix+c.Name)
+	}
+	return views
+}
+
+// starredRows keeps the articles whose word contains filter and, when
+// groupByTag is set, lists them under a heading per tag (an article with
+// several tags appears under each) followed by the untagged ones.
+func starredRows(articles []state.StarredArticle, filter string, groupByTag bool, tags map[int64][]string) []starredRow {
+	filter = strings.ToLower(filter)
+	var matches []state.StarredArticle
+	for _, sa := range articles {
+		if filter == "" || strings.Contains(strings.ToLower(sa.Word), filter) {
+			matches = append(matches, sa)
+		}
+	}
+
+	var rows []starredRow
+	if !groupByTag {
+		for _, sa := range matches {
+			rows = append(rows, starredRow{Article: sa})
+		}
+		return rows
+	}
+
+	byTag := make(map[string][]state.StarredArticle)
+	var untagged []state.StarredArticle
+	for _, sa := range matches {
+		if len(tags[sa.ArticleID]) == 0 {
+			untagged = append(untagged, sa)
+		}
+		for _, tag := range tags[sa.ArticleID] {
+			byTag[tag] = append(byTag[tag], sa)
+		}
+	}
+	names := make([]string, 0, len(byTag))
+	for name := range byTag {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+
+	add := func(heading string, articles []state.StarredArticle) {
+		if len(articles) == 0 {
+			return
+		}
+		rows = append(rows, starredRow{Heading: heading})
+		for _, sa := range articles {
+			rows = append(rows, starredRow{Article: sa})
+		}
+	}
+	for _, name := range names {
+		add("#"+name, byTag[name])
+	}
+	add(untaggedHeading, untagged)
+	return rows
+}
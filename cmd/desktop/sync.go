@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// syncInterval is how often stars and notes are synced with the sync folder
+const syncInterval = time.Minute
+
+// syncMu keeps syncs from overlapping
+var syncMu sync.Mutex
+
+// syncNow syncs stars and notes with the folder set as "sync_dir", if any
+func syncNow(settings *state.Store) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	dir := settings.Get("sync_dir")
+	if dir == "" {
+		return
+	}
+	result, err := settings.Sync(dir)
+	if err != nil {
+		log.Printf("Warning: Sync with %s failed: %v", dir, err)
+		return
+	}
+	if result.Sent > 0 || result.Received > 0 {
+		log.Printf("Synced with %s: %d sent, %d received", dir, result.Sent, result.Received)
+	}
+}
+
+// startSync syncs now and every syncInterval. The returned function stops
+// syncing after a last sync, so changes made just before quitting are not
+// held back.
+func startSync(settings *state.Store) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		syncNow(settings)
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				syncNow(settings)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		syncNow(settings)
+	}
+}
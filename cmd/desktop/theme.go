@@ -6,6 +6,12 @@ import (
 	"fyne.io/fyne/v2"
 )
 
+// Custom colour names used by the article view
+const (
+	colorNameEmphasis fyne.ThemeColorName = "upaya-emphasis" // Headwords, bold text, sense numbers
+	colorNameSanskrit fyne.ThemeColorName = "upaya-sanskrit" // Italic and Sanskrit spans
+)
+
 // scaledTheme wraps a base theme and scales all sizes
 type scaledTheme struct {
 	base  fyne.Theme
@@ -17,6 +23,12 @@ func newScaledTheme(base fyne.Theme, scale float32) *scaledTheme {
 }
 
 func (t *scaledTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	switch name {
+	case colorNameEmphasis:
+		return color.RGBA{R: 20, G: 24, B: 40, A: 255}
+	case colorNameSanskrit:
+		return color.RGBA{R: 140, G: 70, B: 20, A: 255}
+	}
 	return t.base.Color(name, variant)
 }
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// tooltipLayer draws tooltips above the window content.
+// Fyne has no tooltips, and pop-ups are overlays that take hover away from
+// the widget underneath. The layer only holds plain canvas objects, so hover
+// events still reach the widgets below it.
+type tooltipLayer struct {
+	layer *fyne.Container
+}
+
+// tooltips is the main window's tooltip layer
+var tooltips = &tooltipLayer{layer: container.NewWithoutLayout()}
+
+// Show displays text just below obj
+func (t *tooltipLayer) Show(text string, obj fyne.CanvasObject) {
+	driver := fyne.CurrentApp().Driver()
+	pos := driver.AbsolutePositionForObject(obj).Subtract(driver.AbsolutePositionForObject(t.layer))
+
+	label := canvas.NewText(text, theme.Color(theme.ColorNameForeground))
+	label.TextSize = theme.CaptionTextSize()
+	bg := canvas.NewRectangle(theme.Color(theme.ColorNameOverlayBackground))
+	bg.StrokeColor = theme.Color(theme.ColorNameSeparator)
+	bg.StrokeWidth = 1
+	bg.CornerRadius = theme.InputRadiusSize()
+
+	pad := theme.Padding()
+	size := label.MinSize().AddWidthHeight(pad*2, pad)
+	pos.Y += obj.Size().Height + 2
+	// Keep the tooltip inside the window
+	if max := t.layer.Size().Width - size.Width; pos.X > max {
+		pos.X = max
+	}
+	if pos.X < 0 {
+		pos.X = 0
+	}
+
+	bg.Resize(size)
+	bg.Move(pos)
+	label.Move(pos.AddXY(pad, pad/2))
+	t.layer.Objects = []fyne.CanvasObject{bg, label}
+	t.layer.Refresh()
+}
+
+// Hide removes the current tooltip
+func (t *tooltipLayer) Hide() {
+	t.layer.Objects = nil
+	t.layer.Refresh()
+}
+
+// abbrSegment is an inline rich text segment for an abbreviation that shows
+// its meaning as a tooltip while hovered
+type abbrSegment struct {
+	widget.TextSegment
+	Meaning string
+}
+
+// Visual wraps the text in a hoverable widget
+func (s *abbrSegment) Visual() fyne.CanvasObject {
+	a := &abbrText{text: s.TextSegment.Visual().(*canvas.Text), meaning: s.Meaning}
+	a.ExtendBaseWidget(a)
+	return a
+}
+
+// Update applies the segment to an existing visual
+func (s *abbrSegment) Update(o fyne.CanvasObject) {
+	a := o.(*abbrText)
+	a.meaning = s.Meaning
+	s.TextSegment.Update(a.text)
+	a.Refresh()
+}
+
+// abbrText shows an abbreviation and its meaning on hover
+type abbrText struct {
+	widget.BaseWidget
+	text    *canvas.Text
+	meaning string
+}
+
+var _ desktop.Hoverable = (*abbrText)(nil)
+
+func (a *abbrText) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(a.text)
+}
+
+// MouseIn shows the tooltip
+func (a *abbrText) MouseIn(*desktop.MouseEvent) {
+	tooltips.Show(a.meaning, a)
+}
+
+// MouseMoved is required by desktop.Hoverable
+func (a *abbrText) MouseMoved(*desktop.MouseEvent) {}
+
+// MouseOut hides the tooltip
+func (a *abbrText) MouseOut() {
+	tooltips.Hide()
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,9 +19,24 @@ type DictEntry struct {
 // GroupedResult represents all articles for a word across all dictionaries
 type GroupedResult struct {
 	Word    string
+	Homonym int         // Homonym number, 0 for words without numbered homonyms
 	Entries []DictEntry // All dictionaries that have this word
 }
 
+// superscriptDigits renders homonym numbers
+var superscriptDigits = strings.NewReplacer(
+	"0", "⁰", "1", "¹", "2", "²", "3", "³", "4", "⁴",
+	"5", "⁵", "6", "⁶", "7", "⁷", "8", "⁸", "9", "⁹",
+)
+
+// displayWord adds the homonym number as a superscript: "kara²"
+func displayWord(word string, homonym int) string {
+	if homonym <= 0 {
+		return word
+	}
+	return word + superscriptDigits.Replace(strconv.Itoa(homonym))
+}
+
 // debouncer for search-as-you-type
 type debouncer struct {
 	mu       sync.Mutex
@@ -39,3 +56,13 @@ func (d *debouncer) Do(f func()) {
 	}
 	d.timer = time.AfterFunc(d.duration, f)
 }
+
+// Cancel drops a pending call, if any
+func (d *debouncer) Cancel() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// Merge choices offered when importing user data
+const (
+	mergeKeepLocal  = "Keep this computer's settings and notes"
+	mergeKeepNewest = "Keep whichever was changed last"
+)
+
+// exportUserData asks for a file and writes all user data to it as JSON
+func exportUserData(w fyne.Window, settings *state.Store) {
+	fd := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		if writer == nil {
+			return // User cancelled
+		}
+		defer writer.Close()
+
+		if err := settings.Export(writer); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to export: %v", err), w)
+			return
+		}
+		dialog.ShowInformation("Export User Data", "Settings, history, starred articles, notes and review progress were exported.", w)
+	}, w)
+
+	fd.SetFileName("sanskrit-upaya-" + time.Now().Format("2006-01-02") + ".json")
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	fd.Show()
+}
+
+// importUserData asks for an export file and how to merge it, then merges
+// it into the user data
+func importUserData(w fyne.Window, settings *state.Store) {
+	fd := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		if reader == nil {
+			return // User cancelled
+		}
+
+		policy := widget.NewRadioGroup([]string{mergeKeepLocal, mergeKeepNewest}, nil)
+		policy.SetSelected(mergeKeepNewest)
+		items := []*widget.FormItem{widget.NewFormItem("On conflict", policy)}
+		items[0].HintText = "Stars, tags, collections and history are always combined"
+		dialog.ShowForm("Import User Data", "Import", "Cancel", items, func(ok bool) {
+			defer reader.Close()
+			if !ok {
+				return
+			}
+			merge := state.MergeUnion
+			if policy.Selected == mergeKeepNewest {
+				merge = state.MergeKeepNewest
+			}
+			if err := settings.Import(reader, merge); err != nil {
+				dialog.ShowError(fmt.Errorf("Failed to import: %v", err), w)
+				return
+			}
+			dialog.ShowInformation("Import User Data", "User data was imported.\nRestart the app to apply imported settings.", w)
+		}, w)
+	}, w)
+
+	fd.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+	fd.Show()
+}
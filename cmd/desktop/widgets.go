@@ -6,7 +6,12 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
 )
 
 // pillLabel is a custom widget that displays text with a pill/tag background
@@ -63,3 +68,78 @@ func (r *pillRenderer) Objects() []fyne.CanvasObject {
 }
 
 func (r *pillRenderer) Destroy() {}
+
+// searchField is the main search Entry. Fyne hands shortcuts only to the
+// focused widget, so the field passes the navigation shortcuts on itself.
+type searchField struct {
+	widget.Entry
+	onBack    func()
+	onForward func()
+}
+
+func newSearchField() *searchField {
+	e := &searchField{}
+	e.Wrapping = fyne.TextWrap(fyne.TextTruncateClip)
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+// TypedShortcut handles Alt+Left/Alt+Right and leaves the rest to the Entry
+func (e *searchField) TypedShortcut(s fyne.Shortcut) {
+	if cs, ok := s.(*desktop.CustomShortcut); ok && cs.Modifier == fyne.KeyModifierAlt {
+		switch {
+		case cs.KeyName == fyne.KeyLeft && e.onBack != nil:
+			e.onBack()
+			return
+		case cs.KeyName == fyne.KeyRight && e.onForward != nil:
+			e.onForward()
+			return
+		}
+	}
+	e.Entry.TypedShortcut(s)
+}
+
+// newFolderSettingRow builds a settings row showing the folder stored under
+// key, with buttons to choose and clear it. onChange, if set, is called
+// with the new folder ("" when cleared).
+func newFolderSettingRow(w fyne.Window, settings *state.Store, label, key string, onChange func(dir string)) fyne.CanvasObject {
+	dir := ""
+	if settings != nil {
+		dir = settings.Get(key)
+	}
+	dirLabel := widget.NewLabel("Not set")
+	if dir != "" {
+		dirLabel.SetText(dir)
+	}
+	dirLabel.Truncation = fyne.TextTruncateEllipsis
+
+	var clearBtn *widget.Button
+	chooseBtn := widget.NewButton("Choose...", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil || uri == nil || settings == nil {
+				return
+			}
+			settings.Set(key, uri.Path())
+			dirLabel.SetText(uri.Path())
+			clearBtn.Enable()
+			if onChange != nil {
+				onChange(uri.Path())
+			}
+		}, w)
+	})
+	clearBtn = widget.NewButton("Clear", func() {
+		if settings != nil {
+			settings.Set(key, "")
+		}
+		dirLabel.SetText("Not set")
+		clearBtn.Disable()
+		if onChange != nil {
+			onChange("")
+		}
+	})
+	if dir == "" {
+		clearBtn.Disable()
+	}
+	return container.NewBorder(nil, nil, widget.NewLabel(label),
+		container.NewHBox(chooseBtn, clearBtn), dirLabel)
+}
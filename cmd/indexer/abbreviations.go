@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runAbbreviations writes the abbreviation tables of pkg/dictdata from the
+// front matter lists in the source files. The tables of dictionaries whose
+// source has no list, and those shared by a language ("_en"), are kept.
+//
+//	indexer abbreviations -input json/ -output pkg/dictdata/abbreviations.json
+func runAbbreviations(args []string) {
+	fs := flag.NewFlagSet("abbreviations", flag.ExitOnError)
+	inputDir := fs.String("input", "", "Directory containing JSON dictionary files")
+	outPath := fs.String("output", "pkg/dictdata/abbreviations.json", "Abbreviation tables to update")
+	fs.Parse(args)
+
+	if *inputDir == "" {
+		log.Fatal("Please specify -input directory")
+	}
+	tables := make(map[string]map[string]string)
+	if data, err := os.ReadFile(*outPath); err == nil {
+		if err := json.Unmarshal(data, &tables); err != nil {
+			log.Fatalf("Failed to read %s: %v", *outPath, err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(*inputDir, "*.json"))
+	if err != nil {
+		log.Fatalf("Failed to find JSON files: %v", err)
+	}
+	for _, file := range files {
+		dictCode := strings.TrimSuffix(filepath.Base(file), ".json")
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+		var dict DictJSON
+		if err := json.Unmarshal(data, &dict); err != nil {
+			log.Fatalf("Failed to parse %s: %v", file, err)
+		}
+		if len(dict.Abbreviations) == 0 {
+			continue
+		}
+		table := make(map[string]string)
+		for abbr, meaning := range dict.Abbreviations {
+			abbr, meaning = strings.TrimSpace(abbr), strings.TrimSpace(meaning)
+			// Articles are matched on abbreviations ending with a period
+			if !strings.HasSuffix(abbr, ".") || meaning == "" {
+				continue
+			}
+			table[abbr] = meaning
+		}
+		tables[dictCode] = table
+		log.Printf("%s: %d abbreviations", dictCode, len(table))
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(tables); err != nil {
+		log.Fatalf("Failed to encode abbreviations: %v", err)
+	}
+	if err := os.WriteFile(*outPath, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *outPath, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/anki"
+	"github.com/licht1stein/sanskrit-upaya/pkg/download"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+)
+
+// runAnki writes the starred articles of a profile of the app as an Anki
+// import file, with the installed dictionary data and without the app.
+//
+//	indexer anki -out starred.txt -tag verbs -profile default
+func runAnki(args []string) {
+	fs := flag.NewFlagSet("anki", flag.ExitOnError)
+	outPath := fs.String("out", "-", "File to write the Anki import file to (- for standard output)")
+	tag := fs.String("tag", "", "Export only starred articles with this tag")
+	profile := fs.String("profile", "", "Profile to export (default: the one opened last)")
+	fs.Parse(args)
+
+	if *profile == "" {
+		*profile = state.LastProfile()
+	}
+	if err := exportAnki(*profile, *outPath, *tag); err != nil {
+		log.Fatalf("Could not export to Anki: %v", err)
+	}
+}
+
+// exportAnki writes the starred articles of a profile to path ("-" for
+// standard output), only those tagged tag when it is not empty
+func exportAnki(profile, path, tag string) error {
+	if download.CheckDatabase() != download.DatabaseValid {
+		return errors.New("the dictionary data is not installed; start the app once to download it")
+	}
+	dbPath, err := download.GetDatabasePath()
+	if err != nil {
+		return err
+	}
+	db, err := search.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	settings, err := state.OpenProfile(profile)
+	if err != nil {
+		return fmt.Errorf("open settings: %w", err)
+	}
+	defer settings.Close()
+
+	notes, err := anki.Collect(settings, db, tag)
+	if err != nil {
+		return fmt.Errorf("read starred articles: %w", err)
+	}
+	if len(notes) == 0 {
+		if tag != "" {
+			return fmt.Errorf("no starred articles tagged %q", tag)
+		}
+		return errors.New("no starred articles")
+	}
+
+	if path == "-" {
+		return anki.WriteTSV(os.Stdout, notes)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = anki.WriteTSV(f, notes)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Exported %d notes to %s", len(notes), path)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/download"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// runDiff writes the delta between two builds of the database, and adds it
+// to a manifest so that installed data of the old version can be patched.
+//
+//	indexer diff -old old.db -new sanskrit.db -out publish/delta-2025.06.01.json \
+//		-manifest publish/manifest.json -from 2025.06.01
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	oldPath := fs.String("old", "", "Database of the previous version")
+	newPath := fs.String("new", "sanskrit.db", "Database of the new version")
+	outPath := fs.String("out", "", "Delta file to write")
+	manifestPath := fs.String("manifest", "", "Manifest to add the delta to (optional)")
+	from := fs.String("from", "", "Data version of the old database (with -manifest)")
+	compression := fs.String("compress", "", "Publish the delta compressed: zstd or gzip (with -manifest)")
+	signKey := fs.String("sign-key", "", "Private key to sign the manifest with, from indexer keygen (with -manifest)")
+	fs.Parse(args)
+
+	if *oldPath == "" || *outPath == "" {
+		log.Fatal("Please specify -old and -out")
+	}
+	if *manifestPath != "" && *from == "" {
+		log.Fatal("Please specify -from for the manifest")
+	}
+	key, err := readSigningKey(*signKey)
+	if err != nil {
+		log.Fatalf("Failed to read the signing key: %v", err)
+	}
+
+	oldDB, err := search.Open(*oldPath)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *oldPath, err)
+	}
+	defer oldDB.Close()
+	newDB, err := search.Open(*newPath)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *newPath, err)
+	}
+	defer newDB.Close()
+
+	delta, err := search.Diff(oldDB, newDB)
+	if err != nil {
+		log.Fatalf("Failed to compare databases: %v", err)
+	}
+	for _, dd := range delta.Dicts {
+		if dd.Removed {
+			log.Printf("%s: removed", dd.Code)
+			continue
+		}
+		log.Printf("%s: %d added or changed, %d removed articles", dd.Code, len(dd.Articles), len(dd.RemovedArticles))
+	}
+
+	data, err := json.Marshal(delta)
+	if err != nil {
+		log.Fatalf("Failed to encode delta: %v", err)
+	}
+	if err := os.WriteFile(*outPath, data, 0644); err != nil {
+		log.Fatalf("Failed to write delta: %v", err)
+	}
+	log.Printf("Delta size: %.2f MB", float64(len(data))/(1024*1024))
+
+	if *manifestPath != "" {
+		if err := addDelta(*manifestPath, *outPath, *from, *compression, key); err != nil {
+			log.Fatalf("Failed to add the delta to the manifest: %v", err)
+		}
+	}
+}
+
+// addDelta lists a delta file in a manifest, replacing an earlier delta
+// from the same version. The file must be next to the manifest.
+func addDelta(manifestPath, deltaPath, from, compression string, key ed25519.PrivateKey) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	var m download.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	f, err := publishFile(deltaPath, compression)
+	if err != nil {
+		return err
+	}
+	f.From = from
+	if filepath.Dir(deltaPath) != filepath.Dir(manifestPath) {
+		log.Printf("Warning: %s is not next to the manifest; its URL is %s", deltaPath, f.URL)
+	}
+
+	files := m.Files[:0]
+	for _, existing := range m.Files {
+		if existing.From != from {
+			files = append(files, existing)
+		}
+	}
+	m.Files = append(files, f)
+
+	return saveManifest(manifestPath, m, key)
+}
@@ -1,16 +1,25 @@
 // Command indexer builds the SQLite FTS5 database from JSON dictionary files.
+// "indexer diff" writes the delta between two builds (see runDiff).
+// "indexer abbreviations" updates the abbreviation tables of pkg/dictdata
+// (see runAbbreviations). "indexer anki" exports the starred articles of
+// the app as an Anki import file (see runAnki).
 package main
 
 import (
+	"cmp"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
 	"github.com/licht1stein/sanskrit-upaya/pkg/search"
 	"github.com/licht1stein/sanskrit-upaya/pkg/transliterate"
 )
@@ -22,7 +31,19 @@ type DictJSON struct {
 	Data   struct {
 		Words map[string]json.RawMessage `json:"words"` // word -> indices (can be string or array)
 		Text  map[string]string          `json:"text"`  // index -> article content
+		Meta  map[string]RecordMeta      `json:"meta"`  // index -> record metadata, if the source has it
 	} `json:"data"`
+	// Abbreviations lists the abbreviations of the front matter, if the
+	// source has them: abbreviation -> meaning
+	Abbreviations map[string]string `json:"abbreviations"`
+}
+
+// RecordMeta is the metadata of a Cologne record: its homonym number,
+// record number (L) and printed page-column (pc).
+type RecordMeta struct {
+	Hom json.Number `json:"hom"`
+	L   json.Number `json:"L"`
+	PC  string      `json:"pc"`
 }
 
 // DictMeta contains dictionary metadata.
@@ -71,12 +92,49 @@ var dictMeta = map[string]struct {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		runKeygen(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "abbreviations" {
+		runAbbreviations(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "anki" {
+		runAnki(os.Args[2:])
+		return
+	}
+
 	inputDir := flag.String("input", "", "Directory containing JSON dictionary files")
 	outputDB := flag.String("output", "sanskrit.db", "Output SQLite database path")
+	shardsDir := flag.String("shards", "", "Directory to write the database, per-dictionary shards and manifest.json to")
+	dataVersion := flag.String("version", "", "Data version for the manifest (with -shards)")
+	minAppVersion := flag.String("min-app-version", "", "Oldest app version that can read the data (with -shards)")
+	compression := flag.String("compress", "", "Publish the files compressed: zstd or gzip (with -shards)")
+	compressArticles := flag.Bool("compress-articles", false, "Store articles compressed with zstd, to cut the database size")
+	signKey := flag.String("sign-key", "", "Private key to sign the manifest with, from indexer keygen (with -shards)")
 	flag.Parse()
 
+	if *shardsDir != "" && *dataVersion == "" {
+		log.Fatal("Please specify -version for the manifest")
+	}
+	key, err := readSigningKey(*signKey)
+	if err != nil {
+		log.Fatalf("Failed to read the signing key: %v", err)
+	}
 	if *inputDir == "" {
-		log.Fatal("Please specify -input directory")
+		if *shardsDir == "" {
+			log.Fatal("Please specify -input directory")
+		}
+		// Publish an existing database
+		if err := writeShards(*outputDB, *shardsDir, *dataVersion, *minAppVersion, *compression, key); err != nil {
+			log.Fatalf("Failed to write shards: %v", err)
+		}
+		return
 	}
 
 	start := time.Now()
@@ -133,11 +191,23 @@ func main() {
 		log.Fatalf("Failed to commit: %v", err)
 	}
 
+	log.Println("Resolving root families...")
+	if err := db.ResolveRoots(); err != nil {
+		log.Fatalf("Failed to resolve roots: %v", err)
+	}
+
 	log.Println("Building FTS indexes...")
 	if err := db.RebuildFTS(); err != nil {
 		log.Fatalf("Failed to build FTS: %v", err)
 	}
 
+	if *compressArticles {
+		log.Println("Compressing articles...")
+		if err := db.CompressArticles(); err != nil {
+			log.Fatalf("Failed to compress articles: %v", err)
+		}
+	}
+
 	log.Println("Optimizing database...")
 	if err := db.Optimize(); err != nil {
 		log.Printf("Warning: optimization failed: %v", err)
@@ -150,6 +220,14 @@ func main() {
 	if info, err := os.Stat(*outputDB); err == nil {
 		log.Printf("Database size: %.2f MB", float64(info.Size())/(1024*1024))
 	}
+
+	if *shardsDir != "" {
+		db.Close()
+		if err := writeShards(*outputDB, *shardsDir, *dataVersion, *minAppVersion, *compression, key); err != nil {
+			log.Fatalf("Failed to write shards: %v", err)
+		}
+		log.Printf("Wrote shards and manifest to %s", *shardsDir)
+	}
 }
 
 func indexDict(bulk *search.BulkInserter, file, dictCode string) (int, int, error) {
@@ -178,20 +256,51 @@ func indexDict(bulk *search.BulkInserter, file, dictCode string) (int, int, erro
 
 	// Build article ID mapping (JSON uses string keys)
 	articleIDs := make(map[string]int64)
+	rootArticles := make(map[int64]bool)
 	articleCount := 0
 
-	for idxStr, content := range dict.Data.Text {
-		articleID, err := bulk.InsertArticle(dictCode, content)
+	// In record order, so that hash collisions of article IDs resolve the
+	// same way in every build
+	for _, idxStr := range recordKeys(dict.Data.Text) {
+		content := dict.Data.Text[idxStr]
+		meta := dict.Data.Meta[idxStr]
+		rec := article.Record{Key: idxStr, Hom: meta.Hom.String(), L: meta.L.String(), PC: meta.PC}
+		articleID, err := bulk.InsertSourceArticle(dictCode, idxStr, content, article.SourceOfRecord(rec, content))
 		if err != nil {
 			return 0, 0, fmt.Errorf("insert article: %w", err)
 		}
 		articleIDs[idxStr] = articleID
 		articleCount++
+
+		// Index literary citations ("RV. x, 129") for citation search
+		for _, c := range article.Citations(content, dictCode) {
+			if err := bulk.InsertCitation(c.Work, c.Book, c.Verse, articleID); err != nil {
+				return 0, 0, fmt.Errorf("insert citation: %w", err)
+			}
+		}
+
+		// Index root families. Every Kṛdantarūpamālā entry is a root; the
+		// roots themselves are recorded with the headwords below.
+		if dictCode == "krm" || article.IsRootEntry(content) {
+			rootArticles[articleID] = true
+		}
+		for _, d := range article.Derivations(content) {
+			var err error
+			if d.Kind == article.Primary {
+				err = bulk.InsertRoot(d.Source, article.Primary, articleID)
+			} else {
+				err = bulk.InsertStem(d.Source, articleID)
+			}
+			if err != nil {
+				return 0, 0, fmt.Errorf("insert derivation: %w", err)
+			}
+		}
 	}
 
 	// Index words
 	wordCount := 0
-	for word, indicesRaw := range dict.Data.Words {
+	for _, word := range slices.Sorted(maps.Keys(dict.Data.Words)) {
+		indicesRaw := dict.Data.Words[word]
 		// Parse indices (can be array of ints or single int)
 		var indices []int
 		if err := json.Unmarshal(indicesRaw, &indices); err != nil {
@@ -234,9 +343,34 @@ func indexDict(bulk *search.BulkInserter, file, dictCode string) (int, int, erro
 			if err := bulk.InsertWord(word, wordDeva, articleID, dictCode); err != nil {
 				return 0, 0, fmt.Errorf("insert word: %w", err)
 			}
+			if rootArticles[articleID] {
+				if err := bulk.InsertRoot(article.NormalizeRoot(word), article.RootEntry, articleID); err != nil {
+					return 0, 0, fmt.Errorf("insert root: %w", err)
+				}
+			}
 			wordCount++
 		}
 	}
 
 	return wordCount, articleCount, nil
 }
+
+// recordKeys returns the keys of a dictionary's records in order: numbers
+// by value, before other keys
+func recordKeys(text map[string]string) []string {
+	keys := slices.Collect(maps.Keys(text))
+	slices.SortFunc(keys, func(a, b string) int {
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		switch {
+		case errA == nil && errB == nil:
+			return cmp.Compare(na, nb)
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return keys
+}
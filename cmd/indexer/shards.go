@@ -0,0 +1,166 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/download"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// compressedExt is the file name extension of each compression
+var compressedExt = map[string]string{
+	download.CompressionZstd: ".zst",
+	download.CompressionGzip: ".gz",
+}
+
+// writeShards copies the database to dir together with a shard of each
+// dictionary and the manifest listing them, signed with key, ready to be
+// published. With a compression, the files are published compressed.
+func writeShards(dbPath, dir, dataVersion, minAppVersion, compression string, key ed25519.PrivateKey) error {
+	if _, ok := compressedExt[compression]; compression != "" && !ok {
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	db, err := search.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dicts, err := db.GetDicts()
+	if err != nil {
+		return fmt.Errorf("read dictionaries: %w", err)
+	}
+
+	m := download.Manifest{Version: dataVersion, MinAppVersion: minAppVersion}
+	dbCopy := filepath.Join(dir, download.DatabaseFile)
+	if err := copyFile(dbPath, dbCopy); err != nil {
+		return fmt.Errorf("copy database: %w", err)
+	}
+	f, err := publishFile(dbCopy, compression)
+	if err != nil {
+		return err
+	}
+	m.Files = append(m.Files, f)
+
+	for _, d := range dicts {
+		log.Printf("Writing shard %s...", d.Code)
+		path := filepath.Join(dir, d.Code+".db")
+		if err := db.ExportShard(d.Code, path); err != nil {
+			return fmt.Errorf("export %s: %w", d.Code, err)
+		}
+		f, err := publishFile(path, compression)
+		if err != nil {
+			return err
+		}
+		f.Dict = d.Code
+		f.Title = d.Name
+		m.Files = append(m.Files, f)
+	}
+
+	return saveManifest(filepath.Join(dir, "manifest.json"), m, key)
+}
+
+// manifestFile describes a file to publish, with a URL relative to the
+// manifest
+func manifestFile(path string) (download.ManifestFile, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return download.ManifestFile{}, err
+	}
+	defer in.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, in)
+	if err != nil {
+		return download.ManifestFile{}, err
+	}
+	name := filepath.Base(path)
+	return download.ManifestFile{Name: name, URL: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// publishFile describes a file to publish, and replaces it with its
+// compressed copy if a compression is given
+func publishFile(path, compression string) (download.ManifestFile, error) {
+	f, err := manifestFile(path)
+	if err != nil || compression == "" {
+		return f, err
+	}
+	ext, ok := compressedExt[compression]
+	if !ok {
+		return f, fmt.Errorf("unknown compression %q", compression)
+	}
+	compressed := path + ext
+	if err := compressFile(path, compressed, compression); err != nil {
+		return f, fmt.Errorf("compress %s: %w", path, err)
+	}
+	c, err := manifestFile(compressed)
+	if err != nil {
+		return f, err
+	}
+	f.URL = c.URL
+	f.Compression = compression
+	f.DownloadSize = c.Size
+	f.DownloadSHA256 = c.SHA256
+	return f, os.Remove(path)
+}
+
+// compressFile writes a compressed copy of a file, replacing dst
+func compressFile(src, dst, compression string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var w io.WriteCloser
+	if compression == download.CompressionGzip {
+		w, err = gzip.NewWriterLevel(out, gzip.BestCompression)
+	} else {
+		w, err = zstd.NewWriter(out, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// copyFile copies a file, replacing dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
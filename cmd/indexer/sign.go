@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/download"
+)
+
+// runKeygen writes a new key to sign published data with, and prints the
+// public key the app is built with.
+//
+//	indexer keygen -out signing.key
+func runKeygen(args []string) {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	outPath := fs.String("out", "signing.key", "File to write the private key to")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*outPath); err == nil {
+		log.Fatalf("%s exists; remove it first to replace the key", *outPath)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	seed := base64.StdEncoding.EncodeToString(priv.Seed()) + "\n"
+	if err := os.WriteFile(*outPath, []byte(seed), 0600); err != nil {
+		log.Fatalf("Failed to write key: %v", err)
+	}
+	log.Printf("Wrote private key to %s; keep it secret", *outPath)
+	fmt.Println(base64.StdEncoding.EncodeToString(pub))
+}
+
+// readSigningKey reads a private key written by keygen, or returns nil
+// for no path
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a key written by indexer keygen", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// saveManifest writes a manifest to publish, signed with key. Without a
+// key the app refuses the data unless unsigned data is allowed.
+func saveManifest(path string, m download.Manifest, key ed25519.PrivateKey) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	sigPath := path + download.SignatureSuffix
+	if key == nil {
+		log.Printf("Warning: %s is not signed (see -sign-key)", path)
+		if err := os.Remove(sigPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(sigPath, download.SignManifest(data, key), 0644)
+}
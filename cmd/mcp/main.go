@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
 	"github.com/licht1stein/sanskrit-upaya/pkg/dictdata"
 	"github.com/licht1stein/sanskrit-upaya/pkg/gcloud"
 	"github.com/licht1stein/sanskrit-upaya/pkg/ocr"
@@ -92,9 +93,11 @@ type SearchArgs struct {
 // SearchResult represents a single search result.
 type SearchResult struct {
 	Word      string `json:"word"`
+	Homonym   int    `json:"homonym,omitempty"`
 	DictCode  string `json:"dict_code"`
 	DictName  string `json:"dict_name"`
 	ArticleID int64  `json:"article_id"`
+	Page      string `json:"page,omitempty"`
 }
 
 // SearchOutput is the output of sanskrit_search tool.
@@ -155,11 +158,14 @@ func handleSearch(ctx context.Context, req *mcp.CallToolRequest, args SearchArgs
 		}
 	}
 
-	results := allResults
+	return nil, buildSearchOutput(allResults, args.Limit), nil
+}
+
+// buildSearchOutput applies the result limit (default 50, max 1000) and
+// converts results for output.
+func buildSearchOutput(results []search.Result, limit int) SearchOutput {
 	total := len(results)
 
-	// Apply limit (default 50, max 1000)
-	limit := args.Limit
 	if limit <= 0 {
 		limit = 50
 	}
@@ -182,12 +188,135 @@ func handleSearch(ctx context.Context, req *mcp.CallToolRequest, args SearchArgs
 	for i, r := range results {
 		output.Results[i] = SearchResult{
 			Word:      r.Word,
+			Homonym:   r.Homonym,
 			DictCode:  r.DictCode,
 			DictName:  r.DictName,
 			ArticleID: r.ArticleID,
+			Page:      r.Page,
 		}
 	}
 
+	return output
+}
+
+// CitationArgs defines the input for sanskrit_search_citation tool.
+type CitationArgs struct {
+	Work      string   `json:"work" jsonschema:"abbreviated title of the cited work as used in the dictionaries, e.g. RV., AV., MBh., Mn., Bhag. (Apte's Bg., Mb., Ms. are also accepted)"`
+	Locus     string   `json:"locus,omitempty" jsonschema:"passage within the work, e.g. x, 129 or 10.129.1 (book, then hymn/chapter and verse). A locus also matches everything below it. If empty, finds all citations of the work"`
+	DictCodes []string `json:"dict_codes,omitempty" jsonschema:"optional list of dictionary codes to search (e.g. mw, ap90). If empty, searches all dictionaries"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"max results to return (default 50, max 1000)"`
+}
+
+func handleSearchCitation(ctx context.Context, req *mcp.CallToolRequest, args CitationArgs) (*mcp.CallToolResult, SearchOutput, error) {
+	database, err := getDB()
+	if err != nil {
+		return nil, SearchOutput{}, err
+	}
+
+	work := strings.TrimSpace(args.Work)
+	if work == "" || strings.Contains(work, " ") {
+		return nil, SearchOutput{}, errors.New("work must be a single abbreviated title such as RV. or MBh.")
+	}
+
+	results, err := database.Search(work+" "+args.Locus, search.ModeCitation, args.DictCodes)
+	if err != nil {
+		return nil, SearchOutput{}, fmt.Errorf("citation search failed: %w", err)
+	}
+
+	return nil, buildSearchOutput(results, args.Limit), nil
+}
+
+// RootFamilyArgs defines the input for sanskrit_root_family tool.
+type RootFamilyArgs struct {
+	Word  string `json:"word" jsonschema:"a verbal root (kṛ, √gam) or a word derived from one (kāraka), in IAST or Devanagari"`
+	Limit int    `json:"limit,omitempty" jsonschema:"max derivatives to return per group (default 50, max 1000)"`
+}
+
+// RootFamily lists the words belonging to one verbal root.
+type RootFamily struct {
+	Root        string         `json:"root"`
+	RootEntries []SearchResult `json:"root_entries"`
+	Primary     []SearchResult `json:"primary"`
+	Secondary   []SearchResult `json:"secondary"`
+	Truncated   bool           `json:"truncated"`
+}
+
+// RootFamilyOutput is the output of sanskrit_root_family tool.
+type RootFamilyOutput struct {
+	Roots []RootFamily `json:"roots"`
+}
+
+func handleRootFamily(ctx context.Context, req *mcp.CallToolRequest, args RootFamilyArgs) (*mcp.CallToolResult, RootFamilyOutput, error) {
+	database, err := getDB()
+	if err != nil {
+		return nil, RootFamilyOutput{}, err
+	}
+
+	word := article.NormalizeRoot(args.Word)
+	if word == "" {
+		return nil, RootFamilyOutput{}, errors.New("word cannot be empty")
+	}
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	var roots []string
+	seen := make(map[string]bool)
+	for _, term := range transliterate.ToSearchTerms(word) {
+		found, err := database.RootOf(term)
+		if err != nil {
+			return nil, RootFamilyOutput{}, fmt.Errorf("root lookup failed: %w", err)
+		}
+		for _, r := range found {
+			if !seen[r] {
+				seen[r] = true
+				roots = append(roots, r)
+			}
+		}
+	}
+	if len(roots) == 0 {
+		// A root without an entry of its own may still have derivatives
+		roots = []string{word}
+	}
+
+	output := RootFamilyOutput{Roots: []RootFamily{}}
+	for _, root := range roots {
+		family, err := database.WordsFromRoot(root)
+		if err != nil {
+			return nil, RootFamilyOutput{}, fmt.Errorf("root family lookup failed: %w", err)
+		}
+		if len(family) == 0 {
+			continue
+		}
+
+		rf := RootFamily{Root: root, RootEntries: []SearchResult{}, Primary: []SearchResult{}, Secondary: []SearchResult{}}
+		for _, d := range family {
+			group := &rf.Secondary
+			switch d.Kind {
+			case article.RootEntry:
+				group = &rf.RootEntries
+			case article.Primary:
+				group = &rf.Primary
+			}
+			if len(*group) >= limit {
+				rf.Truncated = true
+				continue
+			}
+			*group = append(*group, SearchResult{
+				Word:      d.Word,
+				DictCode:  d.DictCode,
+				DictName:  d.DictName,
+				ArticleID: d.ArticleID,
+			})
+		}
+		output.Roots = append(output.Roots, rf)
+	}
+
 	return nil, output, nil
 }
 
@@ -238,15 +367,25 @@ func handleListDictionaries(ctx context.Context, req *mcp.CallToolRequest, args
 
 // GetArticleArgs defines the input for sanskrit_get_article tool.
 type GetArticleArgs struct {
-	ArticleID int64 `json:"article_id" jsonschema:"the article ID from search results"`
+	ArticleID           int64 `json:"article_id" jsonschema:"the article ID from search results"`
+	ExpandAbbreviations bool  `json:"expand_abbreviations,omitempty" jsonschema:"if true, also return the meanings of abbreviations used in the article (e.g. L., MBh., ifc.)"`
+}
+
+// Abbreviation is an abbreviation used in an article and its meaning.
+type Abbreviation struct {
+	Abbr    string `json:"abbr"`
+	Meaning string `json:"meaning"`
 }
 
 // GetArticleOutput is the output of sanskrit_get_article tool.
 type GetArticleOutput struct {
-	Word     string `json:"word"`
-	DictCode string `json:"dict_code"`
-	DictName string `json:"dict_name"`
-	Content  string `json:"content"`
+	Word          string         `json:"word"`
+	DictCode      string         `json:"dict_code"`
+	DictName      string         `json:"dict_name"`
+	Content       string         `json:"content"`
+	Page          string         `json:"page,omitempty"`           // Printed page and column, "510,2"
+	PageReference string         `json:"page_reference,omitempty"` // Citable form, "MW p. 510, col. 2"
+	Abbreviations []Abbreviation `json:"abbreviations,omitempty"`
 }
 
 func handleGetArticle(ctx context.Context, req *mcp.CallToolRequest, args GetArticleArgs) (*mcp.CallToolResult, GetArticleOutput, error) {
@@ -265,12 +404,24 @@ func handleGetArticle(ctx context.Context, req *mcp.CallToolRequest, args GetArt
 	}
 
 	r := results[0]
-	return nil, GetArticleOutput{
-		Word:     r.Word,
-		DictCode: r.DictCode,
-		DictName: r.DictName,
-		Content:  r.Content,
-	}, nil
+	output := GetArticleOutput{
+		Word:          r.Word,
+		DictCode:      r.DictCode,
+		DictName:      r.DictName,
+		Content:       r.Content,
+		Page:          r.Page,
+		PageReference: article.PageReference(r.DictCode, r.Page),
+	}
+
+	if args.ExpandAbbreviations {
+		blocks := article.Parse(r.Content)
+		article.MarkAbbreviations(blocks, dictdata.Abbreviations(r.DictCode))
+		for _, a := range article.Abbreviations(blocks) {
+			output.Abbreviations = append(output.Abbreviations, Abbreviation{Abbr: a.Abbr, Meaning: a.Meaning})
+		}
+	}
+
+	return nil, output, nil
 }
 
 // TransliterateArgs defines the input for sanskrit_transliterate tool.
@@ -524,6 +675,20 @@ IMPORTANT:
 - TRANSLATE vs ANALYZE: When user asks to "translate" a word, provide ONLY dictionary definitions without commentary. When user asks to "analyze" or "explain", spend ~40% on dictionary data and ~60% on your own thinking: grammatical analysis, etymology, contextual usage, philosophical implications, and scholarly insights. Stay rigorous and scientific—distinguish established facts from interpretation, cite sources for claims, and avoid speculation presented as fact.`,
 	}, handleSearch)
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name: "sanskrit_search_citation",
+		Description: `Find dictionary articles that cite a passage of a Sanskrit text, e.g. every entry quoting Rigveda 10.129 (RV. x, 129) or Bhagavad-gītā 2.47 (Bhag. ii, 47). Works are given by their dictionary abbreviations (RV., AV., MBh., R., Mn., Bhag., Pāṇ., ...).
+
+Use sanskrit_get_article with the returned article IDs to read the entries.`,
+	}, handleSearchCitation)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name: "sanskrit_root_family",
+		Description: `List the family of a verbal root (dhātu): the root's own entries, its primary derivatives (kṛt suffixes added to the root, e.g. kāraka from √kṛ) and its secondary derivatives (taddhita suffixes added to a derived stem, e.g. kārakatva from kāraka). Accepts the root itself or any word derived from it.
+
+Use sanskrit_get_article with the returned article IDs to read the entries.`,
+	}, handleRootFamily)
+
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "sanskrit_list_dictionaries",
 		Description: "List all available Sanskrit dictionaries with their codes, names, language pairs, and descriptions. Use dictionary codes to filter searches.",
@@ -534,7 +699,8 @@ IMPORTANT:
 		Description: `Retrieve the full content of a dictionary article by its ID. Use article IDs from search results.
 
 IMPORTANT:
-- ALWAYS cite the dictionary source (dict_name) with year when available
+- ALWAYS cite the dictionary source (dict_name) with year when available, and the printed page (page_reference, e.g. "MW p. 510, col. 2") when given
+- Set expand_abbreviations to get the meanings of abbreviations (L., MBh., ifc., ...) used in the article
 - When translating article content to user's language, include original terms in brackets for scholarly reference. Example: "запряжённый (yoked), соединённый (joined)"`,
 	}, handleGetArticle)
 
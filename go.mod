@@ -7,6 +7,7 @@ toolchain go1.24.2
 require (
 	cloud.google.com/go/vision/v2 v2.9.6
 	fyne.io/fyne/v2 v2.7.1
+	github.com/klauspost/compress v1.18.0
 	github.com/modelcontextprotocol/go-sdk v1.1.0
 	modernc.org/sqlite v1.40.1
 )
@@ -0,0 +1,116 @@
+// Package anki exports starred articles as an Anki import file: a
+// tab-separated text file with IAST, Devanagari, definition and dictionary
+// fields, tagged with the articles' tags.
+package anki
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+	"github.com/licht1stein/sanskrit-upaya/pkg/state"
+	"github.com/licht1stein/sanskrit-upaya/pkg/transliterate"
+)
+
+// Fields are the note fields of the export, in column order. The tags
+// follow them in the last column.
+var Fields = []string{"IAST", "Devanagari", "Definition", "Dictionary"}
+
+// Note is one Anki note.
+type Note struct {
+	IAST       string
+	Devanagari string
+	Definition string // HTML: plain article text, one paragraph per sense
+	Dictionary string
+	Tags       []string
+}
+
+// NewNote builds the note for a starred word from its raw article content.
+func NewNote(word, dictCode, content string, tags []string) Note {
+	n := Note{IAST: word, Dictionary: dictCode, Tags: tags}
+	if transliterate.IsDevanagari(word) {
+		n.Devanagari, n.IAST = word, transliterate.DevanagariToIAST(word)
+	} else {
+		n.Devanagari = transliterate.IASTToDevanagari(word)
+	}
+	n.Definition = Definition(content)
+	return n
+}
+
+// Definition cleans raw article content for a card: the markup is dropped
+// and senses become paragraphs separated by <br>.
+func Definition(content string) string {
+	var paragraphs []string
+	for _, b := range article.Parse(content) {
+		if text := strings.TrimSpace(b.Text()); text != "" {
+			paragraphs = append(paragraphs, html.EscapeString(text))
+		}
+	}
+	return strings.Join(paragraphs, "<br>")
+}
+
+// Collect builds the notes for all starred articles, or only for those
+// tagged tag when it is not empty, in starred order.
+func Collect(store *state.Store, db *search.DB, tag string) ([]Note, error) {
+	starred := store.GetStarredArticles()
+	if tag != "" {
+		starred = store.ArticlesByTag(tag)
+	}
+	if len(starred) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, len(starred))
+	for i, sa := range starred {
+		ids[i] = sa.ArticleID
+	}
+	contents, err := db.GetArticleContents(ids)
+	if err != nil {
+		return nil, err
+	}
+	tags := store.TaggedArticles()
+
+	notes := make([]Note, 0, len(starred))
+	for _, sa := range starred {
+		content, ok := contents[sa.ArticleID]
+		if !ok {
+			continue // Article no longer in the database
+		}
+		notes = append(notes, NewNote(sa.Word, sa.DictCode, content, tags[sa.ArticleID]))
+	}
+	return notes, nil
+}
+
+// WriteTSV writes notes as an Anki text import file. Header lines tell
+// Anki the separator, that fields hold HTML and which column has the tags,
+// so the file imports without adjusting any options.
+func WriteTSV(w io.Writer, notes []Note) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#separator:tab")
+	fmt.Fprintln(bw, "#html:true")
+	fmt.Fprintf(bw, "#columns:%s\tTags\n", strings.Join(Fields, "\t"))
+	fmt.Fprintf(bw, "#tags column:%d\n", len(Fields)+1)
+
+	for _, n := range notes {
+		tags := make([]string, len(n.Tags))
+		for i, t := range n.Tags {
+			// Anki tags are separated by spaces
+			tags[i] = strings.Join(strings.Fields(t), "_")
+		}
+		fields := []string{n.IAST, n.Devanagari, n.Definition, n.Dictionary, strings.Join(tags, " ")}
+		for i, f := range fields {
+			fields[i] = cleanField(f)
+		}
+		fmt.Fprintln(bw, strings.Join(fields, "\t"))
+	}
+	return bw.Flush()
+}
+
+// cleanField keeps a field on its line: tabs and line breaks become spaces
+func cleanField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,54 @@
+package anki
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewNote(t *testing.T) {
+	n := NewNote("agni", "mw", "<b>agni</b> m. fire, sacrificial fire; <i>agnim</i> &c.", []string{"rv"})
+	if n.IAST != "agni" || n.Devanagari != "अग्नि" || n.Dictionary != "mw" {
+		t.Errorf("NewNote() = %+v", n)
+	}
+	if strings.Contains(n.Definition, "<b>") || strings.Contains(n.Definition, "<i>") {
+		t.Errorf("Definition kept markup: %q", n.Definition)
+	}
+	if !strings.Contains(n.Definition, "fire, sacrificial fire") || !strings.Contains(n.Definition, "&amp;c.") {
+		t.Errorf("Definition = %q", n.Definition)
+	}
+
+	// Devanagari headwords get an IAST field too
+	if n := NewNote("अग्नि", "mw", "", nil); n.IAST != "agni" || n.Devanagari != "अग्नि" {
+		t.Errorf("NewNote(Devanagari) = %+v", n)
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	notes := []Note{
+		{IAST: "agni", Devanagari: "अग्नि", Definition: "fire<br>the god\tof fire", Dictionary: "mw", Tags: []string{"rv", "vedic gods"}},
+		{IAST: "soma", Devanagari: "सोम", Definition: "juice\nof the soma plant", Dictionary: "ap90"},
+	}
+	var buf bytes.Buffer
+	if err := WriteTSV(&buf, notes); err != nil {
+		t.Fatalf("WriteTSV() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{
+		"#separator:tab",
+		"#html:true",
+		"#columns:IAST\tDevanagari\tDefinition\tDictionary\tTags",
+		"#tags column:5",
+		"agni\tअग्नि\tfire<br>the god of fire\tmw\trv vedic_gods",
+		"soma\tसोम\tjuice of the soma plant\tap90\t",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("WriteTSV() wrote %d lines, want %d:\n%s", len(lines), len(want), buf.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,544 @@
+// Package article parses dictionary article markup into structured blocks.
+// Articles from the Cologne dictionaries carry a small HTML-like markup
+// (<b>, <i>, <s>, <br>, <p>) plus conventions such as numbered senses.
+// Parse turns that into paragraphs, senses and sub-senses made of styled spans
+// so that front-ends can render them without knowing the source format.
+package article
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Span is a run of text sharing one style.
+type Span struct {
+	Text     string
+	Bold     bool
+	Italic   bool
+	Sanskrit bool   // Tagged with <s> or written in Devanagari
+	Headword bool   // The bold lemma opening the article
+	Link     string // Headword this span refers to, if it is a cross-reference
+	Abbr     string // Meaning of the abbreviation, if the span is one
+}
+
+// BlockKind describes the role of a block within an article.
+type BlockKind int
+
+const (
+	// BlockParagraph is ordinary running text.
+	BlockParagraph BlockKind = iota
+	// BlockSense is a numbered sense ("1.", "2.").
+	BlockSense
+	// BlockSubSense is a lettered sub-sense ("(a)", "(b)").
+	BlockSubSense
+)
+
+// Block is a paragraph-level unit of an article.
+type Block struct {
+	Kind   BlockKind
+	Number string // Sense number or sub-sense letter, without punctuation
+	Indent int    // Nesting depth: 0 for top level, 1 for senses, 2 for sub-senses
+	Spans  []Span
+}
+
+// Label returns the sense marker as it should be displayed ("1.", "(a)"),
+// or an empty string for plain paragraphs.
+func (b Block) Label() string {
+	switch b.Kind {
+	case BlockSense:
+		return b.Number + "."
+	case BlockSubSense:
+		return "(" + b.Number + ")"
+	}
+	return ""
+}
+
+// Text returns the block content as plain text, including its sense marker.
+func (b Block) Text() string {
+	var sb strings.Builder
+	if label := b.Label(); label != "" {
+		sb.WriteString(label)
+		sb.WriteString(" ")
+	}
+	for _, s := range b.Spans {
+		sb.WriteString(s.Text)
+	}
+	return sb.String()
+}
+
+// PlainText renders blocks as plain text, one paragraph per block,
+// with senses indented by two spaces per level.
+func PlainText(blocks []Block) string {
+	lines := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		lines = append(lines, strings.Repeat("  ", b.Indent)+b.Text())
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// Parse converts raw article content into blocks.
+func Parse(content string) []Block {
+	var blocks []Block
+	indent := 0
+	for _, para := range tokenize(content) {
+		for _, b := range splitSenses(para) {
+			switch b.Kind {
+			case BlockSense:
+				b.Indent = 1
+			case BlockSubSense:
+				b.Indent = 2
+			default:
+				// Unnumbered text continues the sense it follows
+				b.Indent = indent
+			}
+			indent = b.Indent
+			blocks = append(blocks, b)
+		}
+	}
+
+	// The article conventionally opens with the bold headword
+	if len(blocks) > 0 && len(blocks[0].Spans) > 0 && blocks[0].Spans[0].Bold {
+		blocks[0].Spans[0].Headword = true
+	}
+	for i := range blocks {
+		blocks[i].Spans = markLinks(blocks[i].Spans)
+	}
+	return blocks
+}
+
+// style tracks open formatting tags while tokenizing.
+type style struct {
+	bold, italic, sanskrit int
+}
+
+// tokenize splits content into paragraphs of styled spans.
+func tokenize(content string) [][]Span {
+	var paras [][]Span
+	var current []Span
+	var st style
+
+	flush := func() {
+		current = trimSpans(current)
+		if len(current) > 0 {
+			paras = append(paras, current)
+		}
+		current = nil
+	}
+
+	addText := func(text string) {
+		text = html.UnescapeString(text)
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				flush()
+			}
+			for _, run := range scriptRuns(collapseSpace(line)) {
+				current = appendSpan(current, Span{
+					Text:     run.text,
+					Bold:     st.bold > 0,
+					Italic:   st.italic > 0,
+					Sanskrit: st.sanskrit > 0 || run.deva,
+				})
+			}
+		}
+	}
+
+	for len(content) > 0 {
+		lt := strings.IndexByte(content, '<')
+		if lt < 0 {
+			addText(content)
+			break
+		}
+		gt := strings.IndexByte(content[lt:], '>')
+		if gt < 0 {
+			addText(content)
+			break
+		}
+		addText(content[:lt])
+		tag := content[lt+1 : lt+gt]
+		content = content[lt+gt+1:]
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.ToLower(strings.Trim(tag, "/ "))
+		if i := strings.IndexAny(name, " \t"); i >= 0 {
+			name = name[:i]
+		}
+		delta := 1
+		if closing {
+			delta = -1
+		}
+		switch name {
+		case "br", "p", "div":
+			flush()
+		case "b", "strong":
+			st.bold = max(0, st.bold+delta)
+		case "i", "em":
+			st.italic = max(0, st.italic+delta)
+		case "s":
+			st.sanskrit = max(0, st.sanskrit+delta)
+		}
+	}
+	flush()
+	return paras
+}
+
+// scriptRun is a stretch of text in a single script.
+type scriptRun struct {
+	text string
+	deva bool
+}
+
+// scriptRuns splits text into Devanagari and non-Devanagari runs.
+// Spaces and punctuation stay with the run they follow.
+func scriptRuns(text string) []scriptRun {
+	var runs []scriptRun
+	var sb strings.Builder
+	deva := false
+	started := false
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) {
+			isDeva := unicode.Is(unicode.Devanagari, r)
+			if started && isDeva != deva && sb.Len() > 0 {
+				runs = append(runs, scriptRun{sb.String(), deva})
+				sb.Reset()
+			}
+			deva = isDeva
+			started = true
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() > 0 {
+		runs = append(runs, scriptRun{sb.String(), deva})
+	}
+	return runs
+}
+
+var spaceRe = regexp.MustCompile(`\s+`)
+
+func collapseSpace(s string) string {
+	return spaceRe.ReplaceAllString(s, " ")
+}
+
+// sameStyle reports whether two spans can be merged.
+func sameStyle(a, b Span) bool {
+	return a.Bold == b.Bold && a.Italic == b.Italic && a.Sanskrit == b.Sanskrit &&
+		a.Headword == b.Headword && a.Link == "" && b.Link == "" && a.Abbr == "" && b.Abbr == ""
+}
+
+// appendSpan appends s, merging it into the previous span when styles match.
+func appendSpan(spans []Span, s Span) []Span {
+	if s.Text == "" {
+		return spans
+	}
+	if n := len(spans); n > 0 && sameStyle(spans[n-1], s) {
+		spans[n-1].Text += s.Text
+		return spans
+	}
+	return append(spans, s)
+}
+
+// trimSpans removes leading and trailing whitespace from a paragraph.
+func trimSpans(spans []Span) []Span {
+	for len(spans) > 0 {
+		spans[0].Text = strings.TrimLeft(spans[0].Text, " ")
+		if spans[0].Text != "" {
+			break
+		}
+		spans = spans[1:]
+	}
+	for len(spans) > 0 {
+		n := len(spans) - 1
+		spans[n].Text = strings.TrimRight(spans[n].Text, " ")
+		if spans[n].Text != "" {
+			break
+		}
+		spans = spans[:n]
+	}
+	return spans
+}
+
+// Sense markers: "1." for senses, "(a)" for sub-senses. Inside a paragraph
+// they must follow a clause boundary; at its start they may stand alone.
+var (
+	senseRe    = regexp.MustCompile(`(?:^|[;:.]\s+|—\s*|--\s*)(\d{1,2})\.\s`)
+	subSenseRe = regexp.MustCompile(`(?:^|[;:.]\s+)\(([a-z])\)\s`)
+)
+
+// marker is a sense boundary found in a paragraph's plain text.
+type marker struct {
+	start, end int // Byte range of the marker including its preceding separator
+	kind       BlockKind
+	number     string
+}
+
+// splitSenses splits one paragraph into blocks at sense markers.
+// Only markers continuing a sequence (1, 2, 3 or a, b, c) are honoured,
+// which keeps numbers inside citations and quotations intact.
+func splitSenses(spans []Span) []Block {
+	var sb strings.Builder
+	for _, s := range spans {
+		sb.WriteString(s.Text)
+	}
+	text := sb.String()
+
+	var markers []marker
+	nextSense := 1
+	for _, m := range senseRe.FindAllStringSubmatchIndex(text, -1) {
+		n := atoi(text[m[2]:m[3]])
+		if m[0] == 0 || n == nextSense || n == 1 {
+			markers = append(markers, marker{m[0], m[1], BlockSense, text[m[2]:m[3]]})
+			nextSense = n + 1
+		}
+	}
+	nextSub := 'a'
+	for _, m := range subSenseRe.FindAllStringSubmatchIndex(text, -1) {
+		letter := rune(text[m[2]])
+		if m[0] == 0 || letter == nextSub || letter == 'a' {
+			markers = insertMarker(markers, marker{m[0], m[1], BlockSubSense, text[m[2]:m[3]]})
+			nextSub = letter + 1
+		}
+	}
+
+	if len(markers) == 0 {
+		return []Block{{Kind: BlockParagraph, Spans: spans}}
+	}
+
+	var blocks []Block
+	if markers[0].start > 0 {
+		head := trimSpans(sliceSpans(spans, 0, markers[0].start))
+		head = appendSeparator(head, text[markers[0].start:markers[0].end])
+		blocks = append(blocks, Block{Kind: BlockParagraph, Spans: head})
+	}
+	for i, m := range markers {
+		end := len(text)
+		if i+1 < len(markers) {
+			end = markers[i+1].start
+		}
+		body := trimSpans(sliceSpans(spans, m.end, end))
+		if i+1 < len(markers) {
+			body = appendSeparator(body, text[markers[i+1].start:markers[i+1].end])
+		}
+		blocks = append(blocks, Block{Kind: m.kind, Number: m.number, Spans: body})
+	}
+	return blocks
+}
+
+// appendSeparator re-attaches the punctuation that ended a sense before the next marker.
+func appendSeparator(spans []Span, markerText string) []Span {
+	trimmed := strings.TrimSpace(markerText)
+	if trimmed == "" {
+		return spans
+	}
+	if c := trimmed[0]; c == ';' || c == ':' || c == '.' {
+		return appendSpan(spans, Span{Text: string(c)})
+	}
+	return spans
+}
+
+// insertMarker inserts m keeping markers ordered by position.
+// A marker overlapping its predecessor ("1. (a) ...") starts where the predecessor ends.
+func insertMarker(markers []marker, m marker) []marker {
+	i := len(markers)
+	for i > 0 && markers[i-1].start > m.start {
+		i--
+	}
+	if i > 0 && markers[i-1].end > m.start {
+		m.start = markers[i-1].end
+	}
+	if m.start >= m.end || (i < len(markers) && markers[i].start < m.end) {
+		return markers
+	}
+	markers = append(markers, marker{})
+	copy(markers[i+1:], markers[i:])
+	markers[i] = m
+	return markers
+}
+
+// sliceSpans returns the spans covering the byte range [from, to) of their concatenated text.
+func sliceSpans(spans []Span, from, to int) []Span {
+	var out []Span
+	pos := 0
+	for _, s := range spans {
+		start, end := pos, pos+len(s.Text)
+		pos = end
+		if end <= from || start >= to {
+			continue
+		}
+		lo := max(from, start) - start
+		hi := min(to, end) - start
+		part := s
+		part.Text = s.Text[lo:hi]
+		out = appendSpan(out, part)
+	}
+	return out
+}
+
+func atoi(s string) int {
+	n := 0
+	for _, c := range s {
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
+
+// Cross-reference detection. A reference is either a Sanskrit word (tagged or
+// Devanagari) or a word introduced by "see", "cf.", "s.v." or "vide".
+var (
+	wordRe    = regexp.MustCompile(`[\p{L}\p{M}]+(?:-[\p{L}\p{M}]+)*`)
+	refRe     = regexp.MustCompile(`(?i)(?:\bsee|\bcf\.|\bs\.\s?v\.|\bvide)\s+([\p{L}\p{M}]+(?:-[\p{L}\p{M}]+)*)`)
+	refTailRe = regexp.MustCompile(`(?i)(?:\bsee|\bcf\.|\bs\.\s?v\.|\bvide)\s*$`)
+)
+
+// textRange is a byte range of a span's text.
+type textRange struct {
+	start, end int
+}
+
+// markLinks splits spans so that every cross-reference is a span of its own
+// with Link set to the referenced headword.
+func markLinks(spans []Span) []Span {
+	var out []Span
+	refPending := false
+	for _, s := range spans {
+		var ranges []textRange
+		switch {
+		case s.Headword:
+			// The article's own headword is not a reference
+		case s.Sanskrit:
+			for _, m := range wordRe.FindAllStringIndex(s.Text, -1) {
+				ranges = append(ranges, textRange{m[0], m[1]})
+			}
+		case refPending && s.Italic:
+			// "see <i>agni</i>": the reference is the first italic word
+			if m := wordRe.FindStringIndex(s.Text); m != nil {
+				ranges = append(ranges, textRange{m[0], m[1]})
+			}
+		default:
+			for _, m := range refRe.FindAllStringSubmatchIndex(s.Text, -1) {
+				ranges = append(ranges, textRange{m[2], m[3]})
+			}
+		}
+		refPending = !s.Sanskrit && refTailRe.MatchString(s.Text)
+		out = append(out, splitSpan(s, ranges, func(part *Span) {
+			part.Link = part.Text
+		})...)
+	}
+	return out
+}
+
+// splitSpan cuts a span at the given ranges, applying mark to the parts
+// inside them.
+func splitSpan(s Span, ranges []textRange, mark func(part *Span)) []Span {
+	if len(ranges) == 0 {
+		return []Span{s}
+	}
+	var out []Span
+	pos := 0
+	plain := func(text string) {
+		part := s
+		part.Text = text
+		// Spaces and punctuation between Sanskrit words are not Sanskrit
+		if !wordRe.MatchString(text) {
+			part.Sanskrit = false
+		}
+		out = append(out, part)
+	}
+	for _, r := range ranges {
+		if r.start > pos {
+			plain(s.Text[pos:r.start])
+		}
+		part := s
+		part.Text = s.Text[r.start:r.end]
+		mark(&part)
+		out = append(out, part)
+		pos = r.end
+	}
+	if pos < len(s.Text) {
+		plain(s.Text[pos:])
+	}
+	return out
+}
+
+// Links returns the distinct headwords referenced by the blocks, in order of appearance.
+func Links(blocks []Block) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, b := range blocks {
+		for _, s := range b.Spans {
+			if s.Link != "" && !seen[s.Link] {
+				seen[s.Link] = true
+				links = append(links, s.Link)
+			}
+		}
+	}
+	return links
+}
+
+// abbrRe matches abbreviation candidates such as "m.", "MBh." and "e.g.".
+var abbrRe = regexp.MustCompile(`[\p{L}\p{M}]+(?:\.[\p{L}\p{M}]+)*\.`)
+
+// MarkAbbreviations sets Abbr on the abbreviations found in the blocks,
+// splitting spans so that each abbreviation is a span of its own.
+// abbreviations maps an abbreviation, with its final period, to its meaning.
+// Headwords, Sanskrit text and cross-references are left alone.
+func MarkAbbreviations(blocks []Block, abbreviations map[string]string) {
+	if len(abbreviations) == 0 {
+		return
+	}
+	for i := range blocks {
+		var spans []Span
+		for _, s := range blocks[i].Spans {
+			if s.Headword || s.Sanskrit || s.Link != "" {
+				spans = append(spans, s)
+				continue
+			}
+			var ranges []textRange
+			var meanings []string
+			for _, m := range abbrRe.FindAllStringIndex(s.Text, -1) {
+				candidate := s.Text[m[0]:m[1]]
+				meaning, ok := abbreviations[candidate]
+				if !ok {
+					// "Pāṇ.iii" style: try the first segment alone
+					if dot := strings.IndexByte(candidate, '.'); dot < len(candidate)-1 {
+						candidate = candidate[:dot+1]
+						meaning, ok = abbreviations[candidate]
+					}
+				}
+				if ok {
+					ranges = append(ranges, textRange{m[0], m[0] + len(candidate)})
+					meanings = append(meanings, meaning)
+				}
+			}
+			n := 0
+			spans = append(spans, splitSpan(s, ranges, func(part *Span) {
+				part.Abbr = meanings[n]
+				n++
+			})...)
+		}
+		blocks[i].Spans = spans
+	}
+}
+
+// Abbreviation is an abbreviation found in an article.
+type Abbreviation struct {
+	Abbr    string
+	Meaning string
+}
+
+// Abbreviations returns the distinct abbreviations marked in the blocks,
+// in order of appearance.
+func Abbreviations(blocks []Block) []Abbreviation {
+	var abbrs []Abbreviation
+	seen := make(map[string]bool)
+	for _, b := range blocks {
+		for _, s := range b.Spans {
+			if s.Abbr != "" && !seen[s.Text] {
+				seen[s.Text] = true
+				abbrs = append(abbrs, Abbreviation{Abbr: s.Text, Meaning: s.Abbr})
+			}
+		}
+	}
+	return abbrs
+}
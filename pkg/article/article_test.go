@@ -0,0 +1,260 @@
+package article
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStyles(t *testing.T) {
+	blocks := Parse("<b>dharma</b> m. Religion, duty; <i>dharmaḥ</i> the god of justice")
+	if len(blocks) != 1 {
+		t.Fatalf("Parse() got %d blocks, want 1", len(blocks))
+	}
+
+	spans := blocks[0].Spans
+	if len(spans) != 4 {
+		t.Fatalf("Parse() got %d spans, want 4: %+v", len(spans), spans)
+	}
+	if spans[0].Text != "dharma" || !spans[0].Bold || !spans[0].Headword {
+		t.Errorf("spans[0] = %+v, want bold headword dharma", spans[0])
+	}
+	if spans[1].Text != " m. Religion, duty; " || spans[1].Bold {
+		t.Errorf("spans[1] = %+v, want plain text", spans[1])
+	}
+	if spans[2].Text != "dharmaḥ" || !spans[2].Italic {
+		t.Errorf("spans[2] = %+v, want italic dharmaḥ", spans[2])
+	}
+}
+
+func TestParseParagraphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"br", "first<br>second", 2},
+		{"uppercase BR", "first<BR>second", 2},
+		{"p", "first<P>second<p>third", 3},
+		{"newline", "first\nsecond", 2},
+		{"empty paragraphs dropped", "first<br><br> <br>second", 2},
+		{"no markup", "plain text", 1},
+		{"empty", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(Parse(tt.content)); got != tt.want {
+				t.Errorf("Parse(%q) got %d blocks, want %d", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDevanagari(t *testing.T) {
+	blocks := Parse("from धर्म law")
+	if len(blocks) != 1 {
+		t.Fatalf("Parse() got %d blocks, want 1", len(blocks))
+	}
+	var sanskrit []string
+	for _, s := range blocks[0].Spans {
+		if s.Sanskrit {
+			sanskrit = append(sanskrit, strings.TrimSpace(s.Text))
+		}
+	}
+	if len(sanskrit) != 1 || sanskrit[0] != "धर्म" {
+		t.Errorf("Sanskrit spans = %v, want [धर्म]", sanskrit)
+	}
+}
+
+func TestParseSanskritTag(t *testing.T) {
+	blocks := Parse("cf. <s>agni</s>")
+	spans := blocks[0].Spans
+	last := spans[len(spans)-1]
+	if last.Text != "agni" || !last.Sanskrit {
+		t.Errorf("last span = %+v, want Sanskrit agni", last)
+	}
+}
+
+func TestParseEntities(t *testing.T) {
+	got := PlainText(Parse("a &amp; b &lt;c&gt;"))
+	if got != "a & b <c>" {
+		t.Errorf("PlainText() = %q, want %q", got, "a & b <c>")
+	}
+}
+
+func TestParseSenses(t *testing.T) {
+	blocks := Parse("<b>kara</b> a. doing; 1. the hand; 2. a ray of light; 3. tribute")
+	if len(blocks) != 4 {
+		t.Fatalf("Parse() got %d blocks, want 4: %+v", len(blocks), blocks)
+	}
+
+	if blocks[0].Kind != BlockParagraph || blocks[0].Text() != "kara a. doing;" {
+		t.Errorf("blocks[0] = %q (kind %d), want headword paragraph", blocks[0].Text(), blocks[0].Kind)
+	}
+	wantSenses := []string{"1. the hand;", "2. a ray of light;", "3. tribute"}
+	for i, want := range wantSenses {
+		b := blocks[i+1]
+		if b.Kind != BlockSense {
+			t.Errorf("blocks[%d].Kind = %d, want BlockSense", i+1, b.Kind)
+		}
+		if b.Indent != 1 {
+			t.Errorf("blocks[%d].Indent = %d, want 1", i+1, b.Indent)
+		}
+		if got := b.Text(); got != want {
+			t.Errorf("blocks[%d].Text() = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestParseSensesOnSeparateLines(t *testing.T) {
+	blocks := Parse("<b>yoga</b> m.<br><b>1</b>. union<br><b>2</b>. a remedy")
+	if len(blocks) != 3 {
+		t.Fatalf("Parse() got %d blocks, want 3", len(blocks))
+	}
+	if blocks[1].Kind != BlockSense || blocks[1].Number != "1" {
+		t.Errorf("blocks[1] = %+v, want sense 1", blocks[1])
+	}
+	if blocks[2].Kind != BlockSense || blocks[2].Number != "2" {
+		t.Errorf("blocks[2] = %+v, want sense 2", blocks[2])
+	}
+}
+
+func TestParseSubSenses(t *testing.T) {
+	blocks := Parse("1. a hand; (a) the right hand; (b) the left hand")
+	if len(blocks) != 3 {
+		t.Fatalf("Parse() got %d blocks, want 3: %+v", len(blocks), blocks)
+	}
+	if blocks[1].Kind != BlockSubSense || blocks[1].Number != "a" || blocks[1].Indent != 2 {
+		t.Errorf("blocks[1] = %+v, want sub-sense a at indent 2", blocks[1])
+	}
+	if blocks[2].Label() != "(b)" {
+		t.Errorf("blocks[2].Label() = %q, want (b)", blocks[2].Label())
+	}
+}
+
+func TestParseIgnoresOutOfSequenceNumbers(t *testing.T) {
+	// "5." after "1." is a citation, not a sense
+	blocks := Parse("1. the hand, Mn. 5. 3")
+	if len(blocks) != 1 {
+		t.Errorf("Parse() got %d blocks, want 1: %+v", len(blocks), blocks)
+	}
+}
+
+func TestParseContinuationIndent(t *testing.T) {
+	blocks := Parse("1. first sense<br>continued")
+	if len(blocks) != 2 {
+		t.Fatalf("Parse() got %d blocks, want 2", len(blocks))
+	}
+	if blocks[1].Kind != BlockParagraph || blocks[1].Indent != 1 {
+		t.Errorf("blocks[1] = %+v, want paragraph continuing indent 1", blocks[1])
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	got := PlainText(Parse("<b>kara</b> m.<br>1. hand<br>(a) right"))
+	want := "kara m.\n\n  1. hand\n\n    (a) right"
+	if got != want {
+		t.Errorf("PlainText() = %q, want %q", got, want)
+	}
+}
+
+func TestParseUnknownTagsAndMalformed(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"<span class=\"x\">text</span>", "text"},
+		{"a < b", "a < b"},
+		{"<b>unclosed", "unclosed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			if got := PlainText(Parse(tt.content)); got != tt.want {
+				t.Errorf("PlainText(Parse(%q)) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"see", "<b>agnī</b> f. see agni", []string{"agni"}},
+		{"cf", "<b>kara</b> m. hand, cf. hasta and pāṇi", []string{"hasta"}},
+		{"sv", "s.v. dharma", []string{"dharma"}},
+		{"see italic", "see <i>yoga</i>, p. 3", []string{"yoga"}},
+		{"sanskrit tag", "<s>agni kratu</s>", []string{"agni", "kratu"}},
+		{"devanagari", "from धर्म and योग", []string{"धर्म", "योग"}},
+		{"headword not linked", "<b><s>dharma</s></b> law", nil},
+		{"plain italic not linked", "<i>the hand</i>", nil},
+		{"hyphenated", "see agni-hotra.", []string{"agni-hotra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Links(Parse(tt.content))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Links(Parse(%q)) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinksKeepText(t *testing.T) {
+	content := "m. hand, see agni; <s>soma</s> juice"
+	blocks := Parse(content)
+	if got := PlainText(blocks); got != "m. hand, see agni; soma juice" {
+		t.Errorf("PlainText() = %q, text changed by link splitting", got)
+	}
+	for _, s := range blocks[0].Spans {
+		if s.Link != "" && s.Link != s.Text {
+			t.Errorf("span %+v: Link should equal its text", s)
+		}
+	}
+}
+
+func TestMarkAbbreviations(t *testing.T) {
+	table := map[string]string{
+		"m.":   "masculine",
+		"L.":   "lexicographers",
+		"MBh.": "Mahābhārata",
+		"e.g.": "for example",
+		"Pāṇ.": "Pāṇini",
+	}
+	blocks := Parse("<b>kara</b> m. the hand, MBh.; L.; e.g. Pāṇ.iii, 2 <s>m.</s> see m. alone")
+	MarkAbbreviations(blocks, table)
+
+	var got []string
+	for _, a := range Abbreviations(blocks) {
+		got = append(got, a.Abbr+"="+a.Meaning)
+	}
+	want := []string{"m.=masculine", "MBh.=Mahābhārata", "L.=lexicographers", "e.g.=for example", "Pāṇ.=Pāṇini"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Abbreviations() = %v, want %v", got, want)
+	}
+
+	if got := PlainText(blocks); !strings.HasPrefix(got, "kara m. the hand, MBh.; L.; e.g. Pāṇ.iii, 2") {
+		t.Errorf("PlainText() = %q, text changed by marking", got)
+	}
+	for _, s := range blocks[0].Spans {
+		if s.Sanskrit && s.Abbr != "" {
+			t.Errorf("Sanskrit span %+v marked as abbreviation", s)
+		}
+		if s.Link != "" && s.Abbr != "" {
+			t.Errorf("link span %+v marked as abbreviation", s)
+		}
+	}
+}
+
+func TestMarkAbbreviationsIgnoresUnknown(t *testing.T) {
+	blocks := Parse("the end. Also xyz.")
+	MarkAbbreviations(blocks, map[string]string{"m.": "masculine"})
+	if len(Abbreviations(blocks)) != 0 {
+		t.Errorf("Abbreviations() = %v, want none", Abbreviations(blocks))
+	}
+	MarkAbbreviations(blocks, nil)
+}
@@ -0,0 +1,195 @@
+package article
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Citation is a reference to a passage of a literary work, such as
+// "RV. x, 129, 1" (Rigveda, book 10, hymn 129, verse 1).
+type Citation struct {
+	Work  string // Canonical abbreviated title with its final period ("RV.")
+	Book  int    // First component of the locus
+	Verse string // Remaining components joined by periods ("129.1"), may be empty
+}
+
+// Citation detection. A citation is a capitalised abbreviation followed by a
+// locus whose first component is a roman or arabic number and whose other
+// components are arabic: "MBh. iii, 1234", "Bg. 2. 47". Further loci of the
+// same work may follow after a semicolon: "MBh. iii, 1234; xii, 5".
+var (
+	citationRe  = regexp.MustCompile(`(?:^|[^\p{L}\p{M}])(\p{Lu}[\p{L}\p{M}]*\.)\s*([ivxlcIVXLC]+|\d+)((?:[,.]\s*\d+)*)(?:$|[^\p{L}\p{M}\d])`)
+	moreLocusRe = regexp.MustCompile(`^\s*;\s*([ivxlc]+),\s*(\d+(?:[,.]\s*\d+)*)(?:$|[^\p{L}\p{M}\d])`)
+	locusSepRe  = regexp.MustCompile(`[,.\s]+`)
+)
+
+// maxBook rejects roman-looking words that are too large to be book numbers
+const maxBook = 100
+
+// workAliases maps a dictionary's own abbreviations of works to the
+// Monier-Williams forms, so that citations from different dictionaries of
+// the same passage are stored under one name.
+var workAliases = map[string]map[string]string{
+	"ap90": {
+		"Rv.":  "RV.",
+		"Mb.":  "MBh.",
+		"Ms.":  "Mn.",
+		"Y.":   "Yājñ.",
+		"Bg.":  "Bhag.",
+		"Ku.":  "Kum.",
+		"Ś.":   "Śak.",
+		"Me.":  "Megh.",
+		"Pt.":  "Pañcat.",
+		"H.":   "Hit.",
+		"Sk.":  "Siddh.",
+		"Mal.": "Mālatīm.",
+	},
+}
+
+// CanonicalWork normalises a work abbreviation as used by a dictionary:
+// it adds the final period if missing and maps the dictionary's own
+// abbreviation to the Monier-Williams one where they differ.
+func CanonicalWork(work, dictCode string) string {
+	work = strings.TrimSpace(work)
+	if work == "" {
+		return ""
+	}
+	if !strings.HasSuffix(work, ".") {
+		work += "."
+	}
+	if canonical, ok := workAliases[dictCode][work]; ok {
+		return canonical
+	}
+	return work
+}
+
+// WorkVariants returns the canonical names a work abbreviation typed by a
+// user may stand for, trying every dictionary's conventions.
+func WorkVariants(work string) []string {
+	variants := []string{CanonicalWork(work, "")}
+	for dictCode := range workAliases {
+		v := CanonicalWork(work, dictCode)
+		if v != variants[0] {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// ParseLocus parses a locus such as "x, 129, 1", "10.129" or "iii" into a
+// book number and the remaining components joined by periods.
+func ParseLocus(locus string) (book int, verse string, ok bool) {
+	parts := locusSepRe.Split(strings.TrimSpace(locus), -1)
+	if len(parts) == 0 || parts[0] == "" {
+		return 0, "", false
+	}
+	book, ok = parseNumber(parts[0])
+	if !ok {
+		return 0, "", false
+	}
+	var rest []string
+	for _, p := range parts[1:] {
+		if p == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(p); err != nil {
+			return 0, "", false
+		}
+		rest = append(rest, strings.TrimLeft(p, "0"))
+	}
+	return book, strings.Join(rest, "."), true
+}
+
+// parseNumber reads an arabic or roman book number
+func parseNumber(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n > 0
+	}
+	n := romanToInt(s)
+	return n, n > 0 && n <= maxBook
+}
+
+// romanToInt converts a roman numeral, returning 0 if it is malformed
+func romanToInt(s string) int {
+	values := map[rune]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100}
+	total, prev := 0, 0
+	runes := []rune(strings.ToLower(s))
+	for i := len(runes) - 1; i >= 0; i-- {
+		v, ok := values[runes[i]]
+		if !ok {
+			return 0
+		}
+		if v < prev {
+			total -= v
+		} else {
+			total += v
+			prev = v
+		}
+	}
+	if intToRoman(total) != string(runes) {
+		return 0
+	}
+	return total
+}
+
+// intToRoman formats n as a lowercase roman numeral (n <= 399)
+func intToRoman(n int) string {
+	numerals := []struct {
+		value int
+		digit string
+	}{{100, "c"}, {90, "xc"}, {50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"}}
+	var sb strings.Builder
+	for _, num := range numerals {
+		for n >= num.value {
+			sb.WriteString(num.digit)
+			n -= num.value
+		}
+	}
+	return sb.String()
+}
+
+// Citations extracts the literary citations from raw article content.
+// dictCode selects the dictionary's abbreviation conventions.
+// Duplicate citations are reported once.
+func Citations(content, dictCode string) []Citation {
+	var citations []Citation
+	seen := make(map[Citation]bool)
+	add := func(work, locus string) {
+		book, verse, ok := ParseLocus(locus)
+		if !ok {
+			return
+		}
+		c := Citation{Work: CanonicalWork(work, dictCode), Book: book, Verse: verse}
+		if !seen[c] {
+			seen[c] = true
+			citations = append(citations, c)
+		}
+	}
+
+	for _, b := range Parse(content) {
+		text := b.Text()
+		pos := 0
+		for pos < len(text) {
+			m := citationRe.FindStringSubmatchIndex(text[pos:])
+			if m == nil {
+				break
+			}
+			work := text[pos+m[2] : pos+m[3]]
+			add(work, text[pos+m[4]:pos+m[7]])
+
+			// Continue after the locus; the next match may start at its terminator
+			end := pos + m[7]
+			for {
+				mm := moreLocusRe.FindStringSubmatchIndex(text[end:])
+				if mm == nil {
+					break
+				}
+				add(work, text[end+mm[2]:end+mm[3]]+","+text[end+mm[4]:end+mm[5]])
+				end += mm[5]
+			}
+			pos = end
+		}
+	}
+	return citations
+}
@@ -0,0 +1,98 @@
+package article
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCitations(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		dictCode string
+		want     []Citation
+	}{
+		{
+			"mw verse",
+			"<b>agni</b> m. fire, RV. x, 129, 1; AV. iv, 1",
+			"mw",
+			[]Citation{{"RV.", 10, "129.1"}, {"AV.", 4, "1"}},
+		},
+		{
+			"repeated work",
+			"MBh. iii, 1234; xii, 5 &amp;c.",
+			"mw",
+			[]Citation{{"MBh.", 3, "1234"}, {"MBh.", 12, "5"}},
+		},
+		{
+			"non-ascii work",
+			"ŚBr. xiv, 4, 2",
+			"mw",
+			[]Citation{{"ŚBr.", 14, "4.2"}},
+		},
+		{
+			"apte aliases",
+			"duty, Bg. 2. 47; Ms. 2. 18.",
+			"ap90",
+			[]Citation{{"Bhag.", 2, "47"}, {"Mn.", 2, "18"}},
+		},
+		{
+			"book only",
+			"Ragh. 3",
+			"mw",
+			[]Citation{{"Ragh.", 3, ""}},
+		},
+		{
+			"duplicates",
+			"RV. i, 1; see also RV. i, 1",
+			"mw",
+			[]Citation{{"RV.", 1, "1"}},
+		},
+		{"abbreviation without locus", "L.; m. the hand", "mw", nil},
+		{"roman inside word", "R. in the south", "mw", nil},
+		{"malformed roman", "Mn. iiii, 4", "mw", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Citations(tt.content, tt.dictCode)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Citations(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLocus(t *testing.T) {
+	tests := []struct {
+		locus string
+		book  int
+		verse string
+		ok    bool
+	}{
+		{"x, 129, 1", 10, "129.1", true},
+		{"10.129", 10, "129", true},
+		{"iii", 3, "", true},
+		{"2. 47", 2, "47", true},
+		{"", 0, "", false},
+		{"abc", 0, "", false},
+		{"x, abc", 0, "", false},
+	}
+
+	for _, tt := range tests {
+		book, verse, ok := ParseLocus(tt.locus)
+		if book != tt.book || verse != tt.verse || ok != tt.ok {
+			t.Errorf("ParseLocus(%q) = %d, %q, %v; want %d, %q, %v", tt.locus, book, verse, ok, tt.book, tt.verse, tt.ok)
+		}
+	}
+}
+
+func TestWorkVariants(t *testing.T) {
+	got := WorkVariants("Bg")
+	if len(got) != 2 || got[0] != "Bg." || got[1] != "Bhag." {
+		t.Errorf("WorkVariants(Bg) = %v, want [Bg. Bhag.]", got)
+	}
+	if got := WorkVariants("RV."); len(got) != 1 || got[0] != "RV." {
+		t.Errorf("WorkVariants(RV.) = %v, want [RV.]", got)
+	}
+}
@@ -0,0 +1,76 @@
+package article
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DerivationKind tells how an article's word relates to a verbal root.
+type DerivationKind string
+
+const (
+	// RootEntry is the article for the root itself.
+	RootEntry DerivationKind = "root"
+	// Primary derivatives take a kṛt suffix directly on the root ("fr. √ kṛ").
+	Primary DerivationKind = "primary"
+	// Secondary derivatives take a taddhita suffix on a derived stem ("fr. kāraka").
+	Secondary DerivationKind = "secondary"
+)
+
+// Derivation is an etymology note such as "(fr. √ 1. kṛ)".
+type Derivation struct {
+	Kind   DerivationKind // Primary or Secondary
+	Source string         // The root for Primary, the stem for Secondary
+}
+
+var (
+	// "fr. √ 1. kṛ", "fr. √kṛ", "fr. kāraka"
+	derivationRe = regexp.MustCompile(`(?:^|[^\p{L}\p{M}])fr\.\s*(√\s*(?:\d\.\s*)?)?([\p{Ll}\p{M}]+(?:-[\p{Ll}\p{M}]+)*)`)
+	// A root article announces its present class early on: "kṛ 1. cl. 8. P. Ā."
+	rootEntryRe = regexp.MustCompile(`^\S+(?:\s+\d\.)?\s+(?:[^\s]+\s+)?cl\.\s*\d`)
+	// Homonym numbers and the root sign in front of a root
+	rootPrefixRe = regexp.MustCompile(`^(?:√\s*)?(?:\d\.\s*)?`)
+)
+
+// NormalizeRoot strips the root sign and homonym number from a root as
+// written in articles or typed by a user: "√ 1. kṛ" becomes "kṛ".
+func NormalizeRoot(root string) string {
+	root = strings.TrimSpace(root)
+	root = rootPrefixRe.ReplaceAllString(root, "")
+	return strings.ToLower(strings.TrimSpace(root))
+}
+
+// Derivations extracts the derivation notes from raw article content.
+// Duplicates are reported once.
+func Derivations(content string) []Derivation {
+	var derivations []Derivation
+	seen := make(map[Derivation]bool)
+	for _, b := range Parse(content) {
+		for _, m := range derivationRe.FindAllStringSubmatch(b.Text(), -1) {
+			d := Derivation{Kind: Secondary, Source: m[2]}
+			if m[1] != "" {
+				d = Derivation{Kind: Primary, Source: NormalizeRoot(m[2])}
+			}
+			if !seen[d] {
+				seen[d] = true
+				derivations = append(derivations, d)
+			}
+		}
+	}
+	return derivations
+}
+
+// IsRootEntry reports whether raw article content describes a verbal root,
+// recognised by the present class given right after the headword.
+func IsRootEntry(content string) bool {
+	blocks := Parse(content)
+	if len(blocks) == 0 {
+		return false
+	}
+	// The class number may have been taken for a sense number: "gam cl." "1. P."
+	text := blocks[0].Text()
+	if len(blocks) > 1 {
+		text += " " + blocks[1].Text()
+	}
+	return rootEntryRe.MatchString(text)
+}
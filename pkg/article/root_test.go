@@ -0,0 +1,80 @@
+package article
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDerivations(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Derivation
+	}{
+		{
+			"primary with homonym number",
+			"<b>kāraka</b> mf(ikā)n. (fr. √ 1. kṛ) making, doing",
+			[]Derivation{{Primary, "kṛ"}},
+		},
+		{
+			"primary without space",
+			"<b>gati</b> f. (fr. √gam) going",
+			[]Derivation{{Primary, "gam"}},
+		},
+		{
+			"secondary",
+			"<b>kārakatva</b> n. (fr. kāraka) the state of an agent",
+			[]Derivation{{Secondary, "kāraka"}},
+		},
+		{
+			"duplicates",
+			"(fr. √gam) going; cf. also (fr. √gam)",
+			[]Derivation{{Primary, "gam"}},
+		},
+		{
+			"not a derivation",
+			"<b>agni</b> m. fire, free from smoke",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Derivations(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Derivations(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRootEntry(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"<b>kṛ</b> 1. cl. 8. P. Ā. karoti, kurute, to do", true},
+		{"<b>gam</b> cl. 1. P. gacchati, to go", true},
+		{"<b>kāraka</b> mfn. (fr. √ 1. kṛ) making", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRootEntry(tt.content); got != tt.want {
+			t.Errorf("IsRootEntry(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRoot(t *testing.T) {
+	tests := map[string]string{
+		"√ 1. kṛ": "kṛ",
+		"√gam":    "gam",
+		" Kṛ ":    "kṛ",
+		"2. as":   "as",
+	}
+	for in, want := range tests {
+		if got := NormalizeRoot(in); got != want {
+			t.Errorf("NormalizeRoot(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package article
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Source locates an article in its printed dictionary.
+type Source struct {
+	Homonym int     // Homonym number of the headword ("1. kara" is 1), 0 if none
+	LNumber float64 // Cologne record number, ordering the entries of a lemma, 0 if unknown
+	Page    string  // Printed page and column ("253,1"), empty if unknown
+}
+
+// Source markers. The Cologne data numbers homonyms before the headword
+// ("<b>1. kara</b>") or as a superscript after it, and may carry the record
+// number and printed page-column as "[L=52036]" / "<L>52036</L>" and
+// "<pc>253,1</pc>" / "[Page253-1]".
+var (
+	homonymBeforeRe = regexp.MustCompile(`^\s*(?:<b>\s*)?(\d{1,2})\.\s*(?:<b>\s*)?[^\s\d<]`)
+	homonymAfterRe  = regexp.MustCompile(`^\s*<b>[^<]*</b>\s*<sup>(\d{1,2})</sup>`)
+	lNumberRe       = regexp.MustCompile(`[\[<{]L(?:=|>)\s*(\d+(?:\.\d+)?)`)
+	pageRe          = regexp.MustCompile(`(?:<pc>|\[Page|\[p=)\s*(\d+)\s*[,-]\s*(\d+)`)
+)
+
+// Record is the metadata the source data keeps with an article: its key,
+// and the homonym number (hom), record number (L) and printed page-column
+// (pc) of the Cologne record, as given there.
+type Record struct {
+	Key string
+	Hom string
+	L   string
+	PC  string
+}
+
+// SourceOfRecord returns where an article stands in its printed dictionary
+// from its source record. The record number defaults to the key, which
+// numbers the records in the order of the printed dictionary. Markers in
+// the content are only read for what the record leaves out.
+func SourceOfRecord(rec Record, content string) Source {
+	src := SourceOf(content)
+	if n, err := strconv.Atoi(strings.TrimSpace(rec.Hom)); err == nil && n >= 0 {
+		src.Homonym = n
+	}
+	if l, err := strconv.ParseFloat(strings.TrimSpace(rec.L), 64); err == nil && l > 0 {
+		src.LNumber = l
+	} else if l, err := strconv.ParseFloat(strings.TrimSpace(rec.Key), 64); err == nil && l > 0 {
+		src.LNumber = l
+	}
+	if page := strings.Replace(strings.TrimSpace(rec.PC), "-", ",", 1); page != "" {
+		if _, _, ok := ParsePage(page); ok {
+			src.Page = page
+		}
+	}
+	return src
+}
+
+// SourceOf reads the homonym number, record number and page-column from
+// raw article content, for articles without a source record. Missing
+// markers leave the fields zero.
+func SourceOf(content string) Source {
+	var src Source
+	if m := homonymBeforeRe.FindStringSubmatch(content); m != nil {
+		src.Homonym, _ = strconv.Atoi(m[1])
+	} else if m := homonymAfterRe.FindStringSubmatch(content); m != nil {
+		src.Homonym, _ = strconv.Atoi(m[1])
+	}
+	if m := lNumberRe.FindStringSubmatch(content); m != nil {
+		src.LNumber, _ = strconv.ParseFloat(m[1], 64)
+	}
+	if m := pageRe.FindStringSubmatch(content); m != nil {
+		src.Page = m[1] + "," + m[2]
+	}
+	return src
+}
+
+// ParsePage splits a page-column reference ("510,2") into its page and
+// column. The column is 0 when the reference has none ("510").
+func ParsePage(page string) (number, column int, ok bool) {
+	p, c, hasColumn := strings.Cut(strings.TrimSpace(page), ",")
+	number, err := strconv.Atoi(p)
+	if err != nil || number <= 0 {
+		return 0, 0, false
+	}
+	if hasColumn {
+		if column, err = strconv.Atoi(c); err != nil {
+			return 0, 0, false
+		}
+	}
+	return number, column, true
+}
+
+// PageReference formats a page-column reference for citing the printed
+// dictionary: "MW p. 510, col. 2". The dictionary is left out when
+// dictCode is empty. It returns "" if page is not a valid reference.
+func PageReference(dictCode, page string) string {
+	number, column, ok := ParsePage(page)
+	if !ok {
+		return ""
+	}
+	ref := fmt.Sprintf("p. %d", number)
+	if column > 0 {
+		ref += fmt.Sprintf(", col. %d", column)
+	}
+	if dictCode != "" {
+		ref = strings.ToUpper(dictCode) + " " + ref
+	}
+	return ref
+}
@@ -0,0 +1,66 @@
+package article
+
+import "testing"
+
+func TestSourceOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Source
+	}{
+		{"homonym inside bold", "<b>1. kara</b> mf(ī)n. doing, making [L=52036] <pc>253,1</pc>", Source{1, 52036, "253,1"}},
+		{"homonym before bold", "2. <b>kara</b> m. the hand", Source{Homonym: 2}},
+		{"superscript homonym", "<b>kara</b><sup>3</sup> m. a ray of light", Source{Homonym: 3}},
+		{"record and page", "<b>kara</b> m. hand <L>52040.1</L> [Page253-2]", Source{LNumber: 52040.1, Page: "253,2"}},
+		{"plain article", "<b>agni</b> m. fire. 1. sacrificial fire", Source{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SourceOf(tt.content); got != tt.want {
+				t.Errorf("SourceOf(%q) = %+v, want %+v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceOfRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		rec     Record
+		content string
+		want    Source
+	}{
+		{"full record", Record{Key: "7", Hom: "2", L: "52036.1", PC: "253-1"}, "<b>kara</b> m. the hand", Source{2, 52036.1, "253,1"}},
+		{"record number from key", Record{Key: "52040"}, "<b>kara</b> m. hand", Source{LNumber: 52040}},
+		// The record wins over markers in the content
+		{"record over markers", Record{Key: "9", Hom: "1", PC: "254,2"}, "2. <b>kara</b> m. [L=52100] <pc>253,1</pc>", Source{1, 9, "254,2"}},
+		{"markers fill gaps", Record{Key: "x1"}, "<b>1. kara</b> mfn. doing [L=52036] <pc>253,1</pc>", Source{1, 52036, "253,1"}},
+		{"invalid page", Record{Key: "3", PC: "n/a"}, "<b>kara</b>", Source{LNumber: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SourceOfRecord(tt.rec, tt.content); got != tt.want {
+				t.Errorf("SourceOfRecord(%+v) = %+v, want %+v", tt.rec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageReference(t *testing.T) {
+	tests := []struct {
+		dictCode, page, want string
+	}{
+		{"mw", "510,2", "MW p. 510, col. 2"},
+		{"ap90", "42", "AP90 p. 42"},
+		{"", "253,1", "p. 253, col. 1"},
+		{"mw", "", ""},
+		{"mw", "x,1", ""},
+	}
+	for _, tt := range tests {
+		if got := PageReference(tt.dictCode, tt.page); got != tt.want {
+			t.Errorf("PageReference(%q, %q) = %q, want %q", tt.dictCode, tt.page, got, tt.want)
+		}
+	}
+}
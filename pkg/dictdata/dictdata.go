@@ -3,7 +3,66 @@ package dictdata
 
 import (
 	_ "embed"
+	"encoding/json"
+	"sync"
 )
 
 //go:embed dictionaries.json
 var JSON []byte
+
+// abbreviationsJSON holds abbreviation tables from the dictionaries' front
+// matter, keyed by dictionary code, as written by "indexer abbreviations".
+// A table keyed by "_" and a language code ("_en") applies to all
+// dictionaries written in that language.
+//
+//go:embed abbreviations.json
+var abbreviationsJSON []byte
+
+var (
+	abbrOnce   sync.Once
+	abbrTables map[string]map[string]string
+	dictLangs  map[string]string
+)
+
+// Language returns the code of the language a dictionary's articles are
+// written in ("en", "de"), or an empty string for an unknown dictionary.
+func Language(dictCode string) string {
+	load()
+	return dictLangs[dictCode]
+}
+
+// Abbreviations returns the abbreviations used in a dictionary mapped to
+// their meanings, including those shared by the dictionaries written in its
+// language. The returned map is a copy and may be modified by the caller.
+func Abbreviations(dictCode string) map[string]string {
+	load()
+	result := make(map[string]string)
+	if lang := dictLangs[dictCode]; lang != "" {
+		for abbr, meaning := range abbrTables["_"+lang] {
+			result[abbr] = meaning
+		}
+	}
+	for abbr, meaning := range abbrTables[dictCode] {
+		result[abbr] = meaning
+	}
+	return result
+}
+
+// load parses the embedded tables
+func load() {
+	abbrOnce.Do(func() {
+		if err := json.Unmarshal(abbreviationsJSON, &abbrTables); err != nil {
+			panic("dictdata: invalid embedded abbreviations.json: " + err.Error())
+		}
+		var dicts map[string]struct {
+			Lang string `json:"lang"`
+		}
+		if err := json.Unmarshal(JSON, &dicts); err != nil {
+			panic("dictdata: invalid embedded dictionaries.json: " + err.Error())
+		}
+		dictLangs = make(map[string]string, len(dicts))
+		for code, d := range dicts {
+			dictLangs[code] = d.Lang
+		}
+	})
+}
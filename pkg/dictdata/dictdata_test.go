@@ -0,0 +1,73 @@
+package dictdata
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	var dicts map[string]struct {
+		Description string `json:"description"`
+		Lang        string `json:"lang"`
+	}
+	if err := json.Unmarshal(JSON, &dicts); err != nil {
+		t.Fatalf("dictionaries.json is invalid: %v", err)
+	}
+	if dicts["mw"].Description == "" {
+		t.Error("mw has no description")
+	}
+	for code, d := range dicts {
+		if d.Lang == "" {
+			t.Errorf("%s has no language", code)
+		}
+	}
+}
+
+func TestAbbreviations(t *testing.T) {
+	mw := Abbreviations("mw")
+	if mw["MBh."] != "Mahā-bhārata" {
+		t.Errorf("mw MBh. = %q", mw["MBh."])
+	}
+	if mw["m."] != "masculine" {
+		t.Errorf("mw should include English abbreviations, m. = %q", mw["m."])
+	}
+
+	// Dictionary-specific meanings do not leak into other dictionaries
+	if _, ok := Abbreviations("ap90")["mfn."]; ok {
+		t.Error("ap90 should not include mw's mfn.")
+	}
+
+	// English abbreviations only apply to dictionaries written in English
+	if Language("pwg") != "de" || Language("mwe") != "en" {
+		t.Errorf("Language() = %q for pwg, %q for mwe", Language("pwg"), Language("mwe"))
+	}
+	if meaning, ok := Abbreviations("pwg")["m."]; ok {
+		t.Errorf("pwg m. = %q, want no English meaning", meaning)
+	}
+	if Abbreviations("mwe")["cf."] == "" {
+		t.Error("mwe should include English abbreviations")
+	}
+	if len(Abbreviations("nonexistent")) != 0 {
+		t.Error("unknown dictionary should get no abbreviations")
+	}
+
+	// Callers get their own copy
+	mw["MBh."] = "changed"
+	if Abbreviations("mw")["MBh."] != "Mahā-bhārata" {
+		t.Error("Abbreviations() returned a shared map")
+	}
+}
+
+func TestAbbreviationsEndWithPeriod(t *testing.T) {
+	var tables map[string]map[string]string
+	if err := json.Unmarshal(abbreviationsJSON, &tables); err != nil {
+		t.Fatal(err)
+	}
+	for code, table := range tables {
+		for abbr, meaning := range table {
+			if abbr == "" || abbr[len(abbr)-1] != '.' || meaning == "" {
+				t.Errorf("%s: bad entry %q = %q", code, abbr, meaning)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package download
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressions of published and local files
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+)
+
+// newFetcher returns a fetcher of a file to path, verified by checksum
+func newFetcher(url, checksum, path string) *fetcher {
+	return &fetcher{
+		url:        url,
+		checksum:   checksum,
+		path:       path,
+		client:     &http.Client{},
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		maxRetries: maxRetries,
+	}
+}
+
+// fetchFile downloads a file of a manifest to path. A compressed file is
+// downloaded as it is, resuming like any other, and then decompressed
+// next to path.
+func fetchFile(ctx context.Context, file ManifestFile, path string, progress ProgressFunc) error {
+	if file.Compression == "" {
+		return newFetcher(file.URL, file.SHA256, path).fetch(ctx, progress)
+	}
+	compressed := path + "." + file.Compression
+	if err := newFetcher(file.URL, file.DownloadSHA256, compressed).fetch(ctx, progress); err != nil {
+		return err
+	}
+	defer os.Remove(compressed)
+
+	in, err := os.Open(compressed)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = decompress(in, file.Compression, path, file)
+	return err
+}
+
+// decompress writes the decompressed data of r to path, through a
+// temporary file, if its checksum (and size, if known) match file. It
+// returns the size of the data.
+func decompress(r io.Reader, compression, path string, file ManifestFile) (int64, error) {
+	switch compression {
+	case "":
+	case CompressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, fmt.Errorf("decompress: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return 0, fmt.Errorf("decompress: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return 0, fmt.Errorf("%w: unknown compression %q", ErrInvalidManifest, compression)
+	}
+
+	tmp := path + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("create file: %w", err)
+	}
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, h), r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("decompress: %w", err)
+	}
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	if checksum != file.SHA256 || (file.Size > 0 && size != file.Size) {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, file.SHA256, checksum)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("rename file: %w", err)
+	}
+	return size, nil
+}
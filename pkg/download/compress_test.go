@@ -0,0 +1,99 @@
+package download
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressBytes compresses content with zstd or gzip
+func compressBytes(t *testing.T, content []byte, compression string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if compression == CompressionGzip {
+		w := gzip.NewWriter(&buf)
+		w.Write(content)
+		w.Close()
+		return buf.Bytes()
+	}
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(content)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDownloadCompressed(t *testing.T) {
+	// Compressible content, unlike testContent's
+	content := bytes.Repeat([]byte("dharma m. law, duty, virtue; "), 4000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	for _, compression := range []string{CompressionZstd, CompressionGzip} {
+		t.Run(compression, func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", t.TempDir())
+			compressed := compressBytes(t, content, compression)
+			downloadSum := sha256.Sum256(compressed)
+			srv := newDataServer(t, Manifest{
+				Version: "2025.06.01",
+				Files: []ManifestFile{{
+					Name: DatabaseFile, URL: "sanskrit.db", Size: int64(len(content)), SHA256: checksum,
+					Compression: compression, DownloadSize: int64(len(compressed)), DownloadSHA256: hex.EncodeToString(downloadSum[:]),
+				}},
+			}, compressed)
+			m, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+			if err != nil {
+				t.Fatalf("FetchManifest() error = %v", err)
+			}
+			if m.Size() != int64(len(compressed)) {
+				t.Errorf("Size() = %d, want the compressed size %d", m.Size(), len(compressed))
+			}
+
+			var downloaded int64
+			if err := Download(context.Background(), m, func(n, _ int64) { downloaded = n }); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if downloaded != int64(len(compressed)) {
+				t.Errorf("progress = %d bytes, want %d", downloaded, len(compressed))
+			}
+			if installed, err := InstallPending(); !installed || err != nil {
+				t.Fatalf("InstallPending() = %v, %v", installed, err)
+			}
+			if status := CheckDatabase(); status != DatabaseValid {
+				t.Errorf("CheckDatabase() = %v, want DatabaseValid", status)
+			}
+			if err := VerifyDatabase(); err != nil {
+				t.Errorf("VerifyDatabase() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadCompressedMismatch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	content, _ := testContent(1000)
+	compressed := compressBytes(t, content, CompressionZstd)
+	downloadSum := sha256.Sum256(compressed)
+	// The compressed file is intact, but doesn't hold the database
+	m := &Manifest{Version: "1", Files: []ManifestFile{{
+		Name: DatabaseFile, URL: "sanskrit.db", Size: 1000, SHA256: ExpectedChecksum,
+		Compression: CompressionZstd, DownloadSize: int64(len(compressed)), DownloadSHA256: hex.EncodeToString(downloadSum[:]),
+	}}}
+	srv := newDataServer(t, *m, compressed)
+	m.Files[0].URL = srv.URL + "/data/sanskrit.db"
+
+	if err := Download(context.Background(), m, nil); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Download() error = %v, want ErrChecksumMismatch", err)
+	}
+	if installed, _ := InstallPending(); installed {
+		t.Error("InstallPending() installed a database that failed verification")
+	}
+}
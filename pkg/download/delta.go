@@ -0,0 +1,76 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// ErrNoDelta is returned when the manifest has no delta from the
+// installed version of the data.
+var ErrNoDelta = errors.New("no delta update from the installed dictionary data")
+
+// DeltaFile returns the delta from the installed data to latest, if the
+// manifest has one.
+func DeltaFile(installed, latest *Manifest) (ManifestFile, bool) {
+	if installed == nil {
+		return ManifestFile{}, false
+	}
+	return latest.Delta(installed.Version)
+}
+
+// Patch downloads the delta from the installed data to latest and applies
+// it to the open database in a single transaction. The patched database
+// no longer matches the checksum of either version's database, so it is
+// recorded like a database assembled from shards: by its dictionaries.
+// Without a delta it returns ErrNoDelta, and search.ErrNoSourceIDs for a
+// database built before deltas; Update replaces the data instead.
+func Patch(ctx context.Context, db *search.DB, latest *Manifest, progress ProgressFunc) error {
+	file, ok := DeltaFile(InstalledManifest(), latest)
+	if !ok {
+		return ErrNoDelta
+	}
+	if !db.HasSourceIDs() {
+		return search.ErrNoSourceIDs
+	}
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return fmt.Errorf("get database path: %w", err)
+	}
+
+	path := dbPath + ".delta"
+	if err := fetchFile(ctx, file, path, progress); err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	delta, err := readDelta(path)
+	if err != nil {
+		return err
+	}
+	if err := db.ApplyDelta(delta); err != nil {
+		return err
+	}
+	m, err := dictsManifest(latest, latest, db)
+	if err != nil {
+		return err
+	}
+	return writeManifest(dbPath, m)
+}
+
+// readDelta reads a delta file
+func readDelta(path string) (*search.Delta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var delta search.Delta
+	if err := json.Unmarshal(data, &delta); err != nil {
+		return nil, fmt.Errorf("read delta: %w", err)
+	}
+	return &delta, nil
+}
@@ -0,0 +1,108 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// installSourceDB installs a database of version 1 with an article of mw
+// keyed by source ID 1
+func installSourceDB(t *testing.T) *search.DB {
+	t.Helper()
+	dbPath, _ := GetDatabasePath()
+	db, err := search.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InitSchemaForBulkInsert(); err != nil {
+		t.Fatal(err)
+	}
+	bi, err := db.NewBulkInserter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi.InsertDict("mw", "Monier-Williams", "sa", "en", true)
+	id, err := bi.InsertSourceArticle("mw", "1", "dharma m. law", article.Source{LNumber: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi.InsertWord("dharma", "धर्म", id, "mw")
+	if err := bi.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RebuildFTS(); err != nil {
+		t.Fatal(err)
+	}
+	_, checksum := testContent(10)
+	writeManifest(dbPath, &Manifest{Version: "1", Files: []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: 10, SHA256: checksum}}})
+	return db
+}
+
+func TestPatch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	db := installSourceDB(t)
+
+	delta, _ := json.Marshal(search.Delta{Dicts: []search.DictDelta{{
+		Code: "mw", Name: "Monier-Williams", FromLang: "sa", ToLang: "en", Favorite: true,
+		Articles: []search.ArticleDelta{
+			{SourceID: "1", Content: "dharma m. law, duty", Words: []search.DeltaWord{{IAST: "dharma", Deva: "धर्म"}}},
+			{SourceID: "2", Content: "karma n. act", Words: []search.DeltaWord{{IAST: "karma", Deva: "कर्म"}}},
+		},
+	}}})
+	sum := sha256.Sum256(delta)
+	_, dbChecksum := testContent(10)
+	m := Manifest{Version: "2", Files: []ManifestFile{
+		{Name: DatabaseFile, URL: "sanskrit.db", Size: 10, SHA256: dbChecksum},
+		{Name: "delta-1.json", URL: "delta-1.json", Size: int64(len(delta)), SHA256: hex.EncodeToString(sum[:]), From: "1"},
+	}}
+	manifest, sig := signedManifest(m)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data/manifest.json":
+			w.Write(manifest)
+		case "/data/manifest.json.sig":
+			w.Write(sig)
+		case "/data/delta-1.json":
+			w.Write(delta)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	latest, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	if latest.Size() != 10 {
+		t.Errorf("Size() = %d, want the database only", latest.Size())
+	}
+
+	if err := Patch(context.Background(), db, latest, nil); err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	for _, word := range []string{"duty", "act"} {
+		if results, _ := db.Search(word, search.ModeReverse, nil); len(results) != 1 {
+			t.Errorf("Search(%s) found %d results after patching, want 1", word, len(results))
+		}
+	}
+	installed := InstalledManifest()
+	if installed == nil || installed.Version != "2" || !installed.Modular() {
+		t.Errorf("InstalledManifest() = %+v, want version 2 listing its dictionaries", installed)
+	}
+	if err := VerifyDatabase(); err != nil {
+		t.Errorf("VerifyDatabase() error = %v", err)
+	}
+	if err := Patch(context.Background(), db, latest, nil); !errors.Is(err, ErrNoDelta) {
+		t.Errorf("second Patch() error = %v, want ErrNoDelta", err)
+	}
+}
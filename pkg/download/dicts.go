@@ -0,0 +1,234 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// ErrNoShard is returned for a dictionary the manifest doesn't list.
+var ErrNoShard = errors.New("the dictionary is not available for download")
+
+// shardPath returns where the shard of a dictionary is downloaded to
+// before it is merged into a database
+func shardPath(dbPath, dictCode string) string {
+	return filepath.Join(filepath.Dir(dbPath), "shards", dictCode+".db")
+}
+
+// fetchShard downloads the shard of a dictionary, resuming an interrupted
+// download like Download does, and returns its path
+func fetchShard(ctx context.Context, dbPath string, file ManifestFile, progress ProgressFunc) (string, error) {
+	path := shardPath(dbPath, file.Dict)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := fetchFile(ctx, file, path, progress); err != nil {
+		return "", fmt.Errorf("download %s: %w", file.Dict, err)
+	}
+	return path, nil
+}
+
+// dictsManifest returns the manifest of a database assembled from shards
+// or patched, with an entry for each dictionary in db: the shard of latest
+// for the updated dictionaries, or else the entry of installed. Entries
+// of dictionaries installed with a whole database hold the code only. The
+// version is that of latest once every dictionary is its shard of latest.
+func dictsManifest(installed, latest *Manifest, db *search.DB, updated ...string) (*Manifest, error) {
+	dicts, err := db.GetDicts()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{Version: latest.Version, MinAppVersion: latest.MinAppVersion, Files: []ManifestFile{}}
+	for _, d := range dicts {
+		f, ok := latest.Shard(d.Code)
+		if !ok || !slices.Contains(updated, d.Code) {
+			if f, ok = installed.Shard(d.Code); !ok {
+				f = ManifestFile{Name: d.Code + ".db", Dict: d.Code, Title: d.Name}
+			}
+		}
+		if s, ok := latest.Shard(d.Code); !ok || s.SHA256 != f.SHA256 {
+			m.Version = installed.Version
+		}
+		m.Files = append(m.Files, f)
+	}
+	return m, nil
+}
+
+// ShardOutdated reports whether latest publishes another copy of an
+// installed dictionary than the installed one. A dictionary installed with
+// a whole database is outdated if the database is of another version.
+func ShardOutdated(installed, latest *Manifest, dictCode string) bool {
+	f, ok := latest.Shard(dictCode)
+	if !ok || installed == nil {
+		return ok
+	}
+	if s, ok := installed.Shard(dictCode); ok && s.SHA256 != "" {
+		return s.SHA256 != f.SHA256
+	}
+	return installed.Version != latest.Version
+}
+
+// AddDict downloads a dictionary and merges it into the open database,
+// replacing the installed copy, e.g. to update it. Article IDs don't change
+// between builds, so any published dictionary fits installed data of any
+// version. Only data from before that numbered the articles otherwise;
+// AddDict returns the new IDs of those of ids whose ID changed (see
+// search.DB.MapArticles), so that user data kept by article ID can follow
+// them.
+func AddDict(ctx context.Context, db *search.DB, latest *Manifest, dictCode string, ids []int64, progress ProgressFunc) (map[int64]int64, error) {
+	installed := InstalledManifest()
+	if installed == nil {
+		installed = &Manifest{}
+	}
+	file, ok := latest.Shard(dictCode)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoShard, dictCode)
+	}
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return nil, fmt.Errorf("get database path: %w", err)
+	}
+
+	path, err := fetchShard(ctx, dbPath, file, progress)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	moved, err := db.MapArticles(path, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.InstallShard(path); err != nil {
+		return nil, err
+	}
+	m, err := dictsManifest(installed, latest, db, dictCode)
+	if err != nil {
+		return nil, err
+	}
+	return moved, writeManifest(dbPath, m)
+}
+
+// RemoveDict removes a dictionary from the open database. The installed
+// data then lists its dictionaries, so that updates download only those.
+func RemoveDict(db *search.DB, dictCode string) error {
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return fmt.Errorf("get database path: %w", err)
+	}
+	installed := InstalledManifest()
+	if installed == nil {
+		installed = &Manifest{}
+	}
+
+	if err := db.RemoveDict(dictCode); err != nil {
+		return err
+	}
+	m, err := dictsManifest(installed, installed, db)
+	if err != nil {
+		return err
+	}
+	return writeManifest(dbPath, m)
+}
+
+// DownloadDicts downloads the shards of the given dictionaries and
+// assembles them into a database, installed by InstallPending like a
+// database from Download.
+func DownloadDicts(ctx context.Context, m *Manifest, dictCodes []string, progress ProgressFunc) error {
+	var files []ManifestFile
+	var total int64
+	for _, code := range dictCodes {
+		f, ok := m.Shard(code)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrNoShard, code)
+		}
+		files = append(files, f)
+		total += f.TransferSize()
+	}
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return fmt.Errorf("get database path: %w", err)
+	}
+
+	var shards []string
+	defer func() {
+		for _, path := range shards {
+			os.Remove(path)
+		}
+	}()
+	var done int64
+	for _, f := range files {
+		path, err := fetchShard(ctx, dbPath, f, func(downloaded, _ int64) {
+			if progress != nil {
+				progress(done+downloaded, total)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		shards = append(shards, path)
+		done += f.TransferSize()
+	}
+
+	pending := pendingPath(dbPath)
+	os.Remove(manifestPath(pending))
+	if err := os.Remove(pending); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	installed, err := assemble(pending, m, shards)
+	if err != nil {
+		os.Remove(pending)
+		return err
+	}
+	return writeManifest(pending, installed)
+}
+
+// assemble creates a database at path from shards and returns its manifest
+func assemble(path string, m *Manifest, shards []string) (*Manifest, error) {
+	db, err := search.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	for _, shard := range shards {
+		if err := db.InstallShard(shard); err != nil {
+			return nil, err
+		}
+	}
+	return dictsManifest(m, m, db)
+}
+
+// UpdateFiles returns the files Update downloads: the shards of the
+// installed dictionaries for a database assembled from shards or patched
+// with a delta, or else the database.
+func UpdateFiles(installed, latest *Manifest) []ManifestFile {
+	if installed == nil || !installed.Modular() || len(latest.Shards()) == 0 {
+		f, _ := latest.File(DatabaseFile)
+		return []ManifestFile{f}
+	}
+	var files []ManifestFile
+	for _, s := range installed.Shards() {
+		if f, ok := latest.Shard(s.Dict); ok {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Update downloads the latest version of the installed data, to be
+// installed by InstallPending.
+func Update(ctx context.Context, latest *Manifest, progress ProgressFunc) error {
+	installed := InstalledManifest()
+	if installed == nil || !installed.Modular() || len(latest.Shards()) == 0 {
+		return Download(ctx, latest, progress)
+	}
+	var codes []string
+	for _, f := range UpdateFiles(installed, latest) {
+		codes = append(codes, f.Dict)
+	}
+	return DownloadDicts(ctx, latest, codes, progress)
+}
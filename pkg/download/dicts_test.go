@@ -0,0 +1,219 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// newShardServer serves a manifest at /data/manifest.json listing shards
+// of two sample dictionaries, mw and ap90, next to it. The articles name
+// the version, so that every version has other shards.
+func newShardServer(t *testing.T, version string) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	full, err := search.Open(filepath.Join(dir, "full.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer full.Close()
+	if err := full.InitSchemaForBulkInsert(); err != nil {
+		t.Fatal(err)
+	}
+	bi, err := full.NewBulkInserter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []struct{ code, name, word string }{
+		{"mw", "Monier-Williams", "dharma"},
+		{"ap90", "Apte", "karma"},
+	} {
+		bi.InsertDict(d.code, d.name, "sa", "en", false)
+		id, err := bi.InsertSourceArticle(d.code, "1", d.word+" m. an article of "+version, article.Source{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bi.InsertWord(d.word, "", id, d.code)
+	}
+	if err := bi.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := full.RebuildFTS(); err != nil {
+		t.Fatal(err)
+	}
+
+	dbContent, dbChecksum := testContent(1000)
+	m := Manifest{
+		Version: version,
+		Files:   []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: 1000, SHA256: dbChecksum}},
+	}
+	files := map[string][]byte{"sanskrit.db": dbContent}
+	for _, d := range []struct{ code, name string }{{"mw", "Monier-Williams"}, {"ap90", "Apte"}} {
+		path := filepath.Join(dir, d.code+".db")
+		if err := full.ExportShard(d.code, path); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(content)
+		m.Files = append(m.Files, ManifestFile{
+			Name: d.code + ".db", URL: d.code + ".db", Size: int64(len(content)),
+			SHA256: hex.EncodeToString(sum[:]), Dict: d.code, Title: d.name,
+		})
+		files[d.code+".db"] = content
+	}
+
+	files["manifest.json"], files["manifest.json.sig"] = signedManifest(m)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/data/")
+		content, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func installedDicts(t *testing.T) []string {
+	t.Helper()
+	m := InstalledManifest()
+	if m == nil {
+		t.Fatal("no installed manifest")
+	}
+	var codes []string
+	for _, f := range m.Shards() {
+		codes = append(codes, f.Dict)
+	}
+	return codes
+}
+
+func TestDownloadDicts(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	srv := newShardServer(t, "2025.06.01")
+	latest, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	if len(latest.Shards()) != 2 || latest.Size() != 1000 {
+		t.Errorf("Shards() = %d, Size() = %d; want shards apart from the database", len(latest.Shards()), latest.Size())
+	}
+
+	if err := DownloadDicts(context.Background(), latest, []string{"mw"}, nil); err != nil {
+		t.Fatalf("DownloadDicts() error = %v", err)
+	}
+	if installed, err := InstallPending(); !installed || err != nil {
+		t.Fatalf("InstallPending() = %v, %v", installed, err)
+	}
+	if status := CheckDatabase(); status != DatabaseValid {
+		t.Errorf("CheckDatabase() = %v, want DatabaseValid", status)
+	}
+	if codes := installedDicts(t); strings.Join(codes, ",") != "mw" {
+		t.Errorf("installed dicts = %v, want [mw]", codes)
+	}
+
+	dbPath, _ := GetDatabasePath()
+	db, err := search.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := AddDict(context.Background(), db, latest, "ap90", nil, nil); err != nil {
+		t.Fatalf("AddDict() error = %v", err)
+	}
+	if results, _ := db.Search("karma", search.ModeExact, nil); len(results) != 1 {
+		t.Errorf("added dictionary not searchable: %d results", len(results))
+	}
+	if err := RemoveDict(db, "mw"); err != nil {
+		t.Fatalf("RemoveDict() error = %v", err)
+	}
+	if codes := installedDicts(t); strings.Join(codes, ",") != "ap90" {
+		t.Errorf("installed dicts = %v, want [ap90]", codes)
+	}
+	if files := UpdateFiles(InstalledManifest(), latest); len(files) != 1 || files[0].Dict != "ap90" {
+		t.Errorf("UpdateFiles() = %+v, want the ap90 shard", files)
+	}
+	if err := VerifyDatabase(); err != nil {
+		t.Errorf("VerifyDatabase() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(shardPath(dbPath, "mw"))); len(entries) > 0 {
+		t.Errorf("downloaded shards left behind: %v", entries)
+	}
+}
+
+func TestAddDictUpdate(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	srv := newShardServer(t, "2025.06.01")
+	old, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	if err := DownloadDicts(context.Background(), old, []string{"mw", "ap90"}, nil); err != nil {
+		t.Fatalf("DownloadDicts() error = %v", err)
+	}
+	InstallPending()
+
+	dbPath, _ := GetDatabasePath()
+	db, err := search.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	srv = newShardServer(t, "2025.07.01")
+	latest, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	if !ShardOutdated(InstalledManifest(), latest, "mw") || ShardOutdated(InstalledManifest(), old, "mw") {
+		t.Error("ShardOutdated() doesn't compare the installed shard with the published one")
+	}
+
+	// A dictionary is updated on its own
+	if _, err := AddDict(context.Background(), db, latest, "mw", nil, nil); err != nil {
+		t.Fatalf("AddDict() error = %v", err)
+	}
+	results, err := db.Search("dharma", search.ModeExact, nil)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search() = %+v, %v", results, err)
+	}
+	if content, _ := db.GetArticleContent(results[0].ArticleID); !strings.HasSuffix(content, "2025.07.01") {
+		t.Errorf("updated article = %q", content)
+	}
+	installed := InstalledManifest()
+	if ShardOutdated(installed, latest, "mw") || !ShardOutdated(installed, latest, "ap90") {
+		t.Error("ShardOutdated() after updating mw, want only ap90 outdated")
+	}
+	if installed.Version != "2025.06.01" {
+		t.Errorf("version = %q with ap90 not updated, want 2025.06.01", installed.Version)
+	}
+
+	// The data is of the latest version once all its dictionaries are
+	if _, err := AddDict(context.Background(), db, latest, "ap90", nil, nil); err != nil {
+		t.Fatalf("AddDict() error = %v", err)
+	}
+	if got := InstalledManifest().Version; got != "2025.07.01" {
+		t.Errorf("version = %q, want 2025.07.01", got)
+	}
+	if _, err := AddDict(context.Background(), db, latest, "pw", nil, nil); !errors.Is(err, ErrNoShard) {
+		t.Errorf("AddDict() error = %v, want ErrNoShard", err)
+	}
+	if err := db.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+}
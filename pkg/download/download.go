@@ -2,18 +2,21 @@
 package download
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"os"
+	"time"
 
 	"github.com/licht1stein/sanskrit-upaya/pkg/paths"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
 )
 
 const (
-	// DatabaseURL is the URL to download the dictionary database from.
+	// DatabaseURL is the URL of the database distributed before manifests.
 	DatabaseURL = "https://sanskrit.myke.blog/dict.db"
 
 	// AppSecret is sent as a header to authenticate the download.
@@ -23,11 +26,22 @@ const (
 	// HeaderName is the custom header name for authentication.
 	HeaderName = "X-Sanskrit-Mitra"
 
-	// ExpectedChecksum is the SHA256 checksum of the database file.
-	// Update this when the database is updated on the server.
+	// ExpectedChecksum is the SHA256 checksum of the database distributed
+	// before manifests. Databases installed by older versions of the app
+	// are recognized by it; newer data is described by its manifest.
 	ExpectedChecksum = "2eeb4a92e8da19b24e4889ce15d57ea9b41ec4db29327116a9dd3e614c547b34"
 )
 
+const (
+	// Failed requests are retried after minBackoff, doubling up to maxBackoff
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+
+	// maxRetries is how many times in a row a request may fail without
+	// downloading anything before the download gives up
+	maxRetries = 8
+)
+
 // ProgressFunc is called during download with bytes downloaded and total size.
 type ProgressFunc func(downloaded, total int64)
 
@@ -46,33 +60,101 @@ const (
 	DatabaseNeedsUpdate // Checksum mismatch - corrupted or new version available
 )
 
-// CheckDatabase checks the database file status.
+// CheckDatabase checks the database file status against the installed
+// manifest. Only the size is compared; VerifyDatabase checks the contents.
+// A database installed before manifests is hashed once and adopted if it
+// is the database of that time.
 func CheckDatabase() DatabaseStatus {
 	dbPath, err := GetDatabasePath()
 	if err != nil {
 		return DatabaseMissing
 	}
-	_, err = os.Stat(dbPath)
+	info, err := os.Stat(dbPath)
 	if err != nil {
 		return DatabaseMissing
 	}
 
-	// If no expected checksum is set, just check file exists
-	if ExpectedChecksum == "" {
+	m, err := readManifest(dbPath)
+	if err != nil {
+		return adoptDatabase(dbPath, info.Size())
+	}
+	if m.Modular() {
+		// Its size changes as dictionaries are added and removed
 		return DatabaseValid
 	}
+	if f, ok := m.File(DatabaseFile); !ok || f.Size != info.Size() {
+		return DatabaseNeedsUpdate
+	}
+	return DatabaseValid
+}
 
-	// Verify checksum
+// adoptDatabase writes a manifest for a database installed before
+// manifests if it has the checksum of that time
+func adoptDatabase(dbPath string, size int64) DatabaseStatus {
 	checksum, err := computeFileChecksum(dbPath)
-	if err != nil {
+	if err != nil || checksum != ExpectedChecksum {
 		return DatabaseNeedsUpdate
 	}
-	if checksum != ExpectedChecksum {
-		return DatabaseNeedsUpdate
+	m := &Manifest{Files: []ManifestFile{{Name: DatabaseFile, URL: DatabaseURL, Size: size, SHA256: checksum}}}
+	if err := writeManifest(dbPath, m); err != nil {
+		log.Printf("Warning: Could not write database manifest: %v", err)
 	}
 	return DatabaseValid
 }
 
+// VerifyDatabase hashes the installed database and compares it with its
+// manifest; a database assembled from shards has no checksum and gets an
+// integrity check instead. A damaged database loses its manifest, so that
+// CheckDatabase reports DatabaseNeedsUpdate at the next start.
+func VerifyDatabase() error {
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return err
+	}
+	m, err := readManifest(dbPath)
+	if err != nil {
+		info, statErr := os.Stat(dbPath)
+		if statErr != nil {
+			return statErr
+		}
+		if adoptDatabase(dbPath, info.Size()) != DatabaseValid {
+			return ErrChecksumMismatch
+		}
+		return nil
+	}
+	if m.Modular() {
+		return checkIntegrity(dbPath)
+	}
+	f, ok := m.File(DatabaseFile)
+	if !ok {
+		return ErrInvalidManifest
+	}
+	checksum, err := computeFileChecksum(dbPath)
+	if err != nil {
+		return err
+	}
+	if checksum != f.SHA256 {
+		os.Remove(manifestPath(dbPath))
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, f.SHA256, checksum)
+	}
+	return nil
+}
+
+// checkIntegrity runs SQLite's integrity check on a database assembled
+// from shards
+func checkIntegrity(dbPath string) error {
+	db, err := search.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.CheckIntegrity(); err != nil {
+		os.Remove(manifestPath(dbPath))
+		return fmt.Errorf("%w: %v", ErrChecksumMismatch, err)
+	}
+	return nil
+}
+
 // computeFileChecksum calculates SHA256 checksum of a file.
 func computeFileChecksum(path string) (string, error) {
 	f, err := os.Open(path)
@@ -88,90 +170,97 @@ func computeFileChecksum(path string) (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// Download downloads the database with progress reporting.
-// Returns the SHA256 checksum of the downloaded file.
-func Download(progress ProgressFunc) error {
+// pendingPath returns where downloaded data waits to be installed
+func pendingPath(dbPath string) string {
+	return dbPath + ".new"
+}
+
+// Download downloads the database of a manifest with progress reporting.
+// The data is installed by InstallPending, so it can be downloaded while
+// the current database is open. An interrupted download is resumed, both
+// after network errors (retried with backoff) and at the next call: the
+// partial file and its hash state are kept next to the database until the
+// download completes. Cancelling ctx stops the download and keeps the
+// partial file. A compressed database is decompressed once downloaded.
+func Download(ctx context.Context, m *Manifest, progress ProgressFunc) error {
+	file, ok := m.File(DatabaseFile)
+	if !ok {
+		return fmt.Errorf("%w: no %s", ErrInvalidManifest, DatabaseFile)
+	}
 	dbPath, err := GetDatabasePath()
 	if err != nil {
 		return fmt.Errorf("get database path: %w", err)
 	}
-
-	// Create temporary file for download
-	tmpPath := dbPath + ".tmp"
-
-	// Create HTTP request with custom header
-	req, err := http.NewRequest("GET", DatabaseURL, nil)
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+	pending := pendingPath(dbPath)
+	if err := fetchFile(ctx, file, pending, progress); err != nil {
+		return err
 	}
-	req.Header.Set(HeaderName, AppSecret)
+	if file.Size == 0 {
+		// The database distributed before manifests has no size in its
+		// manifest, which CheckDatabase needs
+		info, err := os.Stat(pending)
+		if err != nil {
+			return err
+		}
+		file.Size = info.Size()
+		m = &Manifest{Version: m.Version, MinAppVersion: m.MinAppVersion, Files: []ManifestFile{file}}
+	}
+	return writeManifest(pending, m)
+}
 
-	// Perform request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// MovedArticles returns the IDs that articles of the installed database
+// have in the completed download, for those of ids whose ID changes (see
+// search.DB.MapArticles), so that user data kept by article ID can follow
+// them. It returns none without a download. Call it before InstallPending.
+func MovedArticles(ids []int64) (map[int64]int64, error) {
+	dbPath, err := GetDatabasePath()
 	if err != nil {
-		return fmt.Errorf("download request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	pending := pendingPath(dbPath)
+	for _, path := range []string{manifestPath(pending), pending, dbPath} {
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
 	}
-
-	totalSize := resp.ContentLength
-
-	// Create output file
-	out, err := os.Create(tmpPath)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	db, err := search.Open(dbPath)
 	if err != nil {
-		return fmt.Errorf("create file: %w", err)
-	}
-	defer out.Close()
-
-	// Download with progress, computing checksum as we go
-	var downloaded int64
-	buf := make([]byte, 32*1024) // 32KB buffer
-	hasher := sha256.New()
-
-	for {
-		n, err := resp.Body.Read(buf)
-		if n > 0 {
-			_, writeErr := out.Write(buf[:n])
-			if writeErr != nil {
-				out.Close()
-				os.Remove(tmpPath)
-				return fmt.Errorf("write file: %w", writeErr)
-			}
-			hasher.Write(buf[:n])
-			downloaded += int64(n)
-			if progress != nil {
-				progress(downloaded, totalSize)
-			}
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			out.Close()
-			os.Remove(tmpPath)
-			return fmt.Errorf("read response: %w", err)
-		}
+		return nil, err
 	}
+	defer db.Close()
+	return db.MapArticles(pending, ids)
+}
 
-	// Close file before verifying and renaming
-	out.Close()
-
-	// Verify checksum if expected checksum is set
-	checksum := hex.EncodeToString(hasher.Sum(nil))
-	if ExpectedChecksum != "" && checksum != ExpectedChecksum {
-		os.Remove(tmpPath)
-		return fmt.Errorf("checksum mismatch: expected %s, got %s", ExpectedChecksum, checksum)
+// InstallPending replaces the database with a completed download, if
+// there is one. It must be called before the database is opened.
+func InstallPending() (bool, error) {
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return false, err
 	}
-
-	// Move temp file to final location
-	if err := os.Rename(tmpPath, dbPath); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("rename file: %w", err)
+	pending := pendingPath(dbPath)
+	if _, err := os.Stat(manifestPath(pending)); err != nil {
+		return false, nil
 	}
 
-	return nil
+	// Without its manifest a half-installed database is downloaded again.
+	// The database is already in place if installing stopped before the
+	// manifest was.
+	if _, err := os.Stat(pending); err == nil {
+		os.Remove(manifestPath(dbPath))
+		// A write-ahead log left by the old database must not be applied
+		// to the new one
+		os.Remove(dbPath + "-wal")
+		os.Remove(dbPath + "-shm")
+		if err := os.Rename(pending, dbPath); err != nil {
+			return false, fmt.Errorf("install database: %w", err)
+		}
+	}
+	if err := os.Rename(manifestPath(pending), manifestPath(dbPath)); err != nil {
+		return false, fmt.Errorf("install manifest: %w", err)
+	}
+	return true, nil
 }
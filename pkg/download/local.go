@@ -0,0 +1,166 @@
+package download
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoLocalDatabase is returned when a folder holds no dictionary database.
+var ErrNoLocalDatabase = errors.New("no dictionary database found")
+
+// localNames are the database files looked for in a folder, e.g. the
+// published files copied to a USB drive
+var localNames = []string{DatabaseFile, DatabaseFile + ".zst", DatabaseFile + ".gz", "dict.db"}
+
+// LocalSource is a dictionary database on a local disk, for machines
+// without internet access, with the manifest it is verified against.
+type LocalSource struct {
+	Path     string
+	Manifest *Manifest
+}
+
+// FindLocal finds the database at path: a .db, .db.gz or .db.zst file, or
+// a folder holding one. The database is verified against manifest.json
+// next to it, whose signature must be next to it as well. Unless unsigned
+// data is allowed: else against a checksum file next to it
+// (sanskrit.db.sha256, as written by sha256sum), else against the
+// checksum of the database distributed before manifests.
+func FindLocal(path string) (*LocalSource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		dir := path
+		path = ""
+		for _, name := range localNames {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				path = filepath.Join(dir, name)
+				break
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("%w in %s", ErrNoLocalDatabase, dir)
+		}
+	}
+
+	m, err := localManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalSource{Path: path, Manifest: m}, nil
+}
+
+// localManifest returns the manifest to verify a local database against
+func localManifest(path string) (*Manifest, error) {
+	dir := filepath.Dir(path)
+	if data, err := os.ReadFile(filepath.Join(dir, "manifest.json")); err == nil {
+		sig, err := os.ReadFile(filepath.Join(dir, "manifest.json"+SignatureSuffix))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err := verifyManifest(data, sig); err != nil {
+			return nil, err
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+		}
+		if err := m.validate(); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+
+	// Without a manifest the database distributed before manifests is
+	// recognized by its built-in checksum, and others by an unsigned
+	// checksum file only if unsigned data is allowed
+	checksum := ExpectedChecksum
+	base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".zst")
+	sumPath := filepath.Join(dir, base+".sha256")
+	if data, err := os.ReadFile(sumPath); err == nil {
+		if err := verifyManifest(nil, nil); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, sumPath)
+		}
+		// "<checksum>  <file name>"
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 || len(fields[0]) != 64 {
+			return nil, fmt.Errorf("%w: %s.sha256", ErrInvalidManifest, base)
+		}
+		checksum = strings.ToLower(fields[0])
+	}
+	return &Manifest{Files: []ManifestFile{{Name: DatabaseFile, URL: DatabaseURL, SHA256: checksum}}}, nil
+}
+
+// Install decompresses the database if needed and verifies it, to be
+// installed by InstallPending. Progress is reported in bytes read from
+// the local file.
+func (s *LocalSource) Install(ctx context.Context, progress ProgressFunc) error {
+	file, ok := s.Manifest.File(DatabaseFile)
+	if !ok {
+		return fmt.Errorf("%w: no %s", ErrInvalidManifest, DatabaseFile)
+	}
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return fmt.Errorf("get database path: %w", err)
+	}
+
+	in, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	compression := ""
+	switch {
+	case strings.HasSuffix(s.Path, ".gz"):
+		compression = CompressionGzip
+	case strings.HasSuffix(s.Path, ".zst"):
+		compression = CompressionZstd
+	}
+
+	pending := pendingPath(dbPath)
+	os.Remove(manifestPath(pending))
+	r := &progressReader{ctx: ctx, r: bufio.NewReader(in), total: info.Size(), progress: progress}
+	size, err := decompress(r, compression, pending, file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", s.Path, err)
+	}
+
+	// Installed data is described by its database alone
+	installed := *s.Manifest
+	file.Size = size
+	installed.Files = []ManifestFile{file}
+	return writeManifest(pending, &installed)
+}
+
+// progressReader reports reading progress and stops when ctx is cancelled
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	read     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.read, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,126 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLocal writes content to dir/name, compressed by its extension
+func writeLocal(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	switch filepath.Ext(name) {
+	case ".gz":
+		content = compressBytes(t, content, CompressionGzip)
+	case ".zst":
+		content = compressBytes(t, content, CompressionZstd)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstallLocal(t *testing.T) {
+	content, checksum := testContent(100_000)
+	m := Manifest{
+		Version: "2025.06.01",
+		Files:   []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: int64(len(content)), SHA256: checksum}},
+	}
+	manifest, sig := signedManifest(m)
+
+	for _, name := range []string{"sanskrit.db", "sanskrit.db.gz", "sanskrit.db.zst"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("XDG_DATA_HOME", t.TempDir())
+			// A folder as copied to a USB drive
+			usb := t.TempDir()
+			writeLocal(t, usb, name, content)
+			os.WriteFile(filepath.Join(usb, "manifest.json"), manifest, 0644)
+			os.WriteFile(filepath.Join(usb, "manifest.json.sig"), sig, 0644)
+
+			src, err := FindLocal(usb)
+			if err != nil {
+				t.Fatalf("FindLocal() error = %v", err)
+			}
+			if src.Path != filepath.Join(usb, name) || src.Manifest.Version != m.Version {
+				t.Errorf("FindLocal() = %s, version %q", src.Path, src.Manifest.Version)
+			}
+			var read int64
+			if err := src.Install(context.Background(), func(n, _ int64) { read = n }); err != nil {
+				t.Fatalf("Install() error = %v", err)
+			}
+			if info, _ := os.Stat(src.Path); read != info.Size() {
+				t.Errorf("progress = %d bytes, want %d", read, info.Size())
+			}
+			if installed, err := InstallPending(); !installed || err != nil {
+				t.Fatalf("InstallPending() = %v, %v", installed, err)
+			}
+			if status := CheckDatabase(); status != DatabaseValid {
+				t.Errorf("CheckDatabase() = %v, want DatabaseValid", status)
+			}
+			if got := InstalledManifest(); got == nil || got.Version != m.Version {
+				t.Errorf("InstalledManifest() = %+v", got)
+			}
+			if err := VerifyDatabase(); err != nil {
+				t.Errorf("VerifyDatabase() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallLocalChecksumFile(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	content, checksum := testContent(1000)
+	dir := t.TempDir()
+	path := writeLocal(t, dir, "sanskrit.db.gz", content)
+	os.WriteFile(filepath.Join(dir, "sanskrit.db.sha256"), []byte(checksum+"  sanskrit.db\n"), 0644)
+
+	// A checksum file isn't signed
+	if _, err := FindLocal(path); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("FindLocal() error = %v, want ErrUnsigned", err)
+	}
+	allowUnsignedData(t)
+	src, err := FindLocal(path)
+	if err != nil {
+		t.Fatalf("FindLocal() error = %v", err)
+	}
+	if err := src.Install(context.Background(), nil); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	InstallPending()
+	if status := CheckDatabase(); status != DatabaseValid {
+		t.Errorf("CheckDatabase() = %v, want DatabaseValid", status)
+	}
+}
+
+func TestInstallLocalMismatch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	content, _ := testContent(1000)
+	dir := t.TempDir()
+
+	if _, err := FindLocal(dir); !errors.Is(err, ErrNoLocalDatabase) {
+		t.Errorf("FindLocal() of an empty folder error = %v, want ErrNoLocalDatabase", err)
+	}
+
+	// Without a manifest or checksum file only the database distributed
+	// before manifests is accepted, by its built-in checksum, which needs
+	// no signature
+	path := writeLocal(t, dir, "dict.db", content)
+	src, err := FindLocal(path)
+	if err != nil {
+		t.Fatalf("FindLocal() error = %v", err)
+	}
+	if err := src.Install(context.Background(), nil); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Install() error = %v, want ErrChecksumMismatch", err)
+	}
+	if installed, _ := InstallPending(); installed {
+		t.Error("InstallPending() installed a database that failed verification")
+	}
+	dbPath, _ := GetDatabasePath()
+	if _, err := os.Stat(pendingPath(dbPath) + ".import"); !os.IsNotExist(err) {
+		t.Error("the rejected database was kept")
+	}
+}
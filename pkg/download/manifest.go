@@ -0,0 +1,305 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/version"
+)
+
+const (
+	// DefaultManifestURL is where the manifest of the current dictionary
+	// data is published.
+	DefaultManifestURL = "https://sanskrit.myke.blog/manifest.json"
+
+	// ManifestURLEnv names the environment variable that overrides the
+	// manifest URL, e.g. for a mirror.
+	ManifestURLEnv = "SANSKRIT_UPAYA_MANIFEST_URL"
+
+	// DatabaseFile is the manifest entry of the dictionary database.
+	DatabaseFile = "sanskrit.db"
+
+	// manifestTimeout limits fetching the manifest, which is small
+	manifestTimeout = 30 * time.Second
+)
+
+var (
+	// ErrAppTooOld is returned for dictionary data that needs a newer
+	// version of the app.
+	ErrAppTooOld = errors.New("the dictionary data requires a newer version of the app")
+
+	// ErrInvalidManifest is returned for a manifest without the database
+	// or with incomplete file entries.
+	ErrInvalidManifest = errors.New("invalid manifest")
+
+	// ErrChecksumMismatch is returned when a file doesn't match the
+	// checksum in its manifest.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Manifest describes a version of the dictionary data: the files to
+// download and the oldest app version that can read them.
+type Manifest struct {
+	Version       string         `json:"version"`
+	MinAppVersion string         `json:"min_app_version,omitempty"`
+	Files         []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a file of the dictionary data: the database, the shard
+// of a single dictionary (see search.DB.ExportShard) or a delta from an
+// earlier version (see search.Delta).
+//
+// A file may be published compressed: Size and SHA256 then describe the
+// decompressed file, and DownloadSize and DownloadSHA256 the file at URL.
+type ManifestFile struct {
+	Name           string `json:"name"`
+	URL            string `json:"url"` // Absolute, or relative to the manifest
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"`
+	Dict           string `json:"dict,omitempty"`        // Code of the dictionary in a shard
+	Title          string `json:"title,omitempty"`       // Name of the dictionary in a shard
+	From           string `json:"from,omitempty"`        // Version a delta applies to
+	Compression    string `json:"compression,omitempty"` // CompressionZstd or CompressionGzip
+	DownloadSize   int64  `json:"download_size,omitempty"`
+	DownloadSHA256 string `json:"download_sha256,omitempty"`
+}
+
+// TransferSize returns the size of the file to download.
+func (f ManifestFile) TransferSize() int64 {
+	if f.Compression != "" {
+		return f.DownloadSize
+	}
+	return f.Size
+}
+
+// File returns the file with the given name.
+func (m *Manifest) File(name string) (ManifestFile, bool) {
+	for _, f := range m.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// Size returns the total download size of the files other than shards
+// and deltas.
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, f := range m.Files {
+		if f.Dict == "" && f.From == "" {
+			size += f.TransferSize()
+		}
+	}
+	return size
+}
+
+// Shards returns the files holding single dictionaries.
+func (m *Manifest) Shards() []ManifestFile {
+	var shards []ManifestFile
+	for _, f := range m.Files {
+		if f.Dict != "" {
+			shards = append(shards, f)
+		}
+	}
+	return shards
+}
+
+// Shard returns the file holding a dictionary.
+func (m *Manifest) Shard(dictCode string) (ManifestFile, bool) {
+	for _, f := range m.Files {
+		if f.Dict == dictCode {
+			return f, true
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// Delta returns the delta from a version of the data to this one.
+func (m *Manifest) Delta(from string) (ManifestFile, bool) {
+	for _, f := range m.Files {
+		if from != "" && f.From == from {
+			return f, true
+		}
+	}
+	return ManifestFile{}, false
+}
+
+// Modular reports whether this is the manifest of an installed database
+// assembled from shards or patched with a delta, which lists the shards of
+// the dictionaries in it instead of the database.
+func (m *Manifest) Modular() bool {
+	_, ok := m.File(DatabaseFile)
+	return !ok
+}
+
+// Supports reports whether an app version can use the data.
+func (m *Manifest) Supports(appVersion string) bool {
+	return version.AtLeast(appVersion, m.MinAppVersion)
+}
+
+// validate checks that the manifest lists the database and that every
+// file has a URL, size and checksum, and those of the download if it is
+// compressed
+func (m *Manifest) validate() error {
+	if _, ok := m.File(DatabaseFile); !ok {
+		return fmt.Errorf("%w: no %s", ErrInvalidManifest, DatabaseFile)
+	}
+	for _, f := range m.Files {
+		if f.Name == "" || f.URL == "" || f.Size <= 0 || len(f.SHA256) != 64 {
+			return fmt.Errorf("%w: incomplete entry %q", ErrInvalidManifest, f.Name)
+		}
+		switch f.Compression {
+		case "":
+		case CompressionZstd, CompressionGzip:
+			if f.DownloadSize <= 0 || len(f.DownloadSHA256) != 64 {
+				return fmt.Errorf("%w: incomplete entry %q", ErrInvalidManifest, f.Name)
+			}
+		default:
+			return fmt.Errorf("%w: unknown compression %q of %q", ErrInvalidManifest, f.Compression, f.Name)
+		}
+	}
+	return nil
+}
+
+// ManifestURL returns the manifest URL from the environment, or the
+// default one.
+func ManifestURL() string {
+	if u := os.Getenv(ManifestURLEnv); u != "" {
+		return u
+	}
+	return DefaultManifestURL
+}
+
+// FetchManifest downloads and checks a manifest and verifies its
+// signature, published next to it. Relative file URLs are resolved
+// against the manifest's URL.
+func FetchManifest(ctx context.Context, manifestURL string) (*Manifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, manifestTimeout)
+	defer cancel()
+
+	base, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("manifest URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set(HeaderName, AppSecret)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("manifest request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return legacyManifest(base), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("manifest: %w", &statusError{resp.StatusCode})
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	sig, err := fetchSignature(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyManifest(data, sig); err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	for i, f := range m.Files {
+		ref, err := url.Parse(f.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s URL: %v", ErrInvalidManifest, f.Name, err)
+		}
+		m.Files[i].URL = base.ResolveReference(ref).String()
+	}
+	return &m, nil
+}
+
+// legacyManifest describes the database distributed before manifests, for
+// servers that publish no manifest. It is published next to where the
+// manifest would be, and its checksum is built in instead of signed. Its
+// size is filled in when it is downloaded.
+func legacyManifest(base *url.URL) *Manifest {
+	ref := &url.URL{Path: path.Base(DatabaseURL)}
+	return &Manifest{Files: []ManifestFile{{Name: DatabaseFile, URL: base.ResolveReference(ref).String(), SHA256: ExpectedChecksum}}}
+}
+
+// manifestPath returns where the manifest of the data installed at path is kept
+func manifestPath(path string) string {
+	return path + ".manifest.json"
+}
+
+// readManifest reads the manifest of the data installed at path
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// writeManifest records the manifest of the data installed at path
+func writeManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := manifestPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, manifestPath(path))
+}
+
+// InstalledManifest returns the manifest of the installed database, or
+// nil if there is none.
+func InstalledManifest() *Manifest {
+	dbPath, err := GetDatabasePath()
+	if err != nil {
+		return nil
+	}
+	m, err := readManifest(dbPath)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// HasUpdate reports whether latest is a newer version of the data than
+// installed. Without an installed manifest any versioned data is newer.
+func HasUpdate(installed, latest *Manifest) bool {
+	if latest.Version == "" {
+		// The database distributed before manifests is never newer
+		return false
+	}
+	if installed == nil || installed.Version == "" {
+		return true
+	}
+	return version.IsNewer(latest.Version, installed.Version)
+}
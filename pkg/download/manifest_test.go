@@ -0,0 +1,170 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// newDataServer serves a manifest for content at /data/manifest.json,
+// signed with the test key, and the content at /data/sanskrit.db
+func newDataServer(t *testing.T, m Manifest, content []byte) *httptest.Server {
+	t.Helper()
+	manifest, sig := signedManifest(m)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data/manifest.json":
+			w.Write(manifest)
+		case "/data/manifest.json.sig":
+			w.Write(sig)
+		case "/data/sanskrit.db":
+			w.Write(content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchManifest(t *testing.T) {
+	content, checksum := testContent(1000)
+	m := Manifest{
+		Version:       "2025.06.01",
+		MinAppVersion: "v1.4.0",
+		Files:         []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: 1000, SHA256: checksum}},
+	}
+	srv := newDataServer(t, m, content)
+
+	got, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	f, ok := got.File(DatabaseFile)
+	if !ok || f.URL != srv.URL+"/data/sanskrit.db" {
+		t.Errorf("database entry = %+v, want URL resolved against the manifest", f)
+	}
+	if got.Size() != 1000 {
+		t.Errorf("Size() = %d", got.Size())
+	}
+	if got.Supports("v1.3.2") || !got.Supports("v1.4.0") || !got.Supports("dev") {
+		t.Error("Supports() doesn't follow min_app_version")
+	}
+}
+
+func TestFetchManifestInvalid(t *testing.T) {
+	for name, m := range map[string]Manifest{
+		"no database": {Version: "1", Files: []ManifestFile{{Name: "mw.db", URL: "mw.db", Size: 1, SHA256: "00"}}},
+		"no checksum": {Version: "1", Files: []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: 1}}},
+	} {
+		srv := newDataServer(t, m, nil)
+		if _, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json"); !errors.Is(err, ErrInvalidManifest) {
+			t.Errorf("%s: FetchManifest() error = %v, want ErrInvalidManifest", name, err)
+		}
+	}
+}
+
+func TestFetchManifestLegacy(t *testing.T) {
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	// A server without a manifest has the database distributed before
+	// manifests
+	content, checksum := testContent(1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data/dict.db" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	m, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	f, ok := m.File(DatabaseFile)
+	if !ok || f.URL != srv.URL+"/data/dict.db" || f.SHA256 != ExpectedChecksum {
+		t.Errorf("database entry = %+v, want the database distributed before manifests", f)
+	}
+	if HasUpdate(&Manifest{}, m) {
+		t.Error("HasUpdate() for the database distributed before manifests")
+	}
+
+	// Its size is recorded when it is downloaded
+	m.Files[0].SHA256 = checksum
+	if err := Download(context.Background(), m, nil); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if _, err := InstallPending(); err != nil {
+		t.Fatalf("InstallPending() error = %v", err)
+	}
+	if status := CheckDatabase(); status != DatabaseValid {
+		t.Errorf("CheckDatabase() = %v, want DatabaseValid", status)
+	}
+}
+
+func TestDownloadAndInstall(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("XDG_DATA_HOME", tmpDir)
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	content, checksum := testContent(100_000)
+	srv := newDataServer(t, Manifest{
+		Version: "2025.06.01",
+		Files:   []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: int64(len(content)), SHA256: checksum}},
+	}, content)
+	m, err := FetchManifest(context.Background(), srv.URL+"/data/manifest.json")
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+
+	if err := Download(context.Background(), m, nil); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	// Downloaded data waits until it is installed
+	if status := CheckDatabase(); status != DatabaseMissing {
+		t.Errorf("CheckDatabase() before install = %v, want DatabaseMissing", status)
+	}
+	if installed, err := InstallPending(); !installed || err != nil {
+		t.Fatalf("InstallPending() = %v, %v", installed, err)
+	}
+	if installed, _ := InstallPending(); installed {
+		t.Error("second InstallPending() installed again")
+	}
+
+	if status := CheckDatabase(); status != DatabaseValid {
+		t.Errorf("CheckDatabase() = %v, want DatabaseValid", status)
+	}
+	if got := InstalledManifest(); got == nil || got.Version != "2025.06.01" {
+		t.Errorf("InstalledManifest() = %+v", got)
+	}
+	if HasUpdate(InstalledManifest(), m) {
+		t.Error("HasUpdate() for the installed version")
+	}
+	if !HasUpdate(InstalledManifest(), &Manifest{Version: "2025.07.01"}) {
+		t.Error("no HasUpdate() for a newer version")
+	}
+	if err := VerifyDatabase(); err != nil {
+		t.Errorf("VerifyDatabase() error = %v", err)
+	}
+
+	// A damaged database of the right size is only found by verifying it
+	dbPath, _ := GetDatabasePath()
+	damaged := append([]byte(nil), content...)
+	damaged[500] ^= 0xff
+	os.WriteFile(dbPath, damaged, 0644)
+	if status := CheckDatabase(); status != DatabaseValid {
+		t.Errorf("CheckDatabase() = %v, want DatabaseValid without rehashing", status)
+	}
+	if err := VerifyDatabase(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyDatabase() error = %v, want ErrChecksumMismatch", err)
+	}
+	if status := CheckDatabase(); status != DatabaseNeedsUpdate {
+		t.Errorf("CheckDatabase() after failed verification = %v, want DatabaseNeedsUpdate", status)
+	}
+}
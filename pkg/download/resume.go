@@ -0,0 +1,303 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// saveInterval is how many bytes are downloaded between saves of the
+// partial download's state
+const saveInterval = 4 << 20
+
+// fetcher downloads a file to path through path+".tmp", resuming partial
+// downloads with HTTP range requests.
+type fetcher struct {
+	url      string
+	checksum string // Expected SHA-256 of the file, empty to skip the check
+	path     string
+	client   *http.Client
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	maxRetries int
+}
+
+// partialState describes a partial download, saved next to it so it can
+// be resumed without hashing the downloaded part again.
+type partialState struct {
+	URL       string `json:"url"`
+	Checksum  string `json:"checksum"`
+	Validator string `json:"validator,omitempty"` // ETag or Last-Modified of the file being downloaded
+	Size      int64  `json:"size"`                // Bytes downloaded and hashed
+	Total     int64  `json:"total,omitempty"`     // Size of the whole file, 0 if unknown
+	Hash      []byte `json:"hash"`                // Marshaled SHA-256 state after Size bytes
+}
+
+// statusError is an unexpected HTTP response status
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("download failed: HTTP %d", e.code)
+}
+
+// temporary reports whether a request with this status may succeed later
+func (e *statusError) temporary() bool {
+	return e.code >= 500 || e.code == http.StatusRequestTimeout || e.code == http.StatusTooManyRequests
+}
+
+func (f *fetcher) tmpPath() string   { return f.path + ".tmp" }
+func (f *fetcher) statePath() string { return f.path + ".tmp.json" }
+
+// fetch downloads the file, resuming a partial download and retrying
+// failed requests with backoff until maxRetries requests in a row make no
+// progress.
+func (f *fetcher) fetch(ctx context.Context, progress ProgressFunc) error {
+	st, h := f.loadState()
+
+	retries := 0
+	backoff := f.minBackoff
+	for {
+		before := st.Size
+		err := f.fetchOnce(ctx, &st, h, progress)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var se *statusError
+		if errors.As(err, &se) && !se.temporary() {
+			return err
+		}
+
+		if st.Size > before {
+			retries = 0
+			backoff = f.minBackoff
+		}
+		retries++
+		if retries > f.maxRetries {
+			return fmt.Errorf("giving up after %d attempts: %w", retries, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, f.maxBackoff)
+	}
+
+	// The partial state is of no use once the whole file is there
+	os.Remove(f.statePath())
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+	if f.checksum != "" && checksum != f.checksum {
+		os.Remove(f.tmpPath())
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, f.checksum, checksum)
+	}
+	if err := os.Rename(f.tmpPath(), f.path); err != nil {
+		os.Remove(f.tmpPath())
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return nil
+}
+
+// loadState returns the state of the partial download to resume, or a new
+// state when there is none or it doesn't match the partial file
+func (f *fetcher) loadState() (partialState, hash.Hash) {
+	fresh := partialState{URL: f.url, Checksum: f.checksum}
+	h := sha256.New()
+
+	data, err := os.ReadFile(f.statePath())
+	if err != nil {
+		return fresh, h
+	}
+	var st partialState
+	if json.Unmarshal(data, &st) != nil || st.URL != f.url || st.Checksum != f.checksum {
+		return fresh, h
+	}
+	// The partial file may be longer than its state if the app stopped
+	// between writing and saving the state; it is truncated on resume
+	info, err := os.Stat(f.tmpPath())
+	if err != nil || info.Size() < st.Size {
+		return fresh, h
+	}
+	if h.(encoding.BinaryUnmarshaler).UnmarshalBinary(st.Hash) != nil {
+		return fresh, sha256.New()
+	}
+	return st, h
+}
+
+// saveState writes the state of the partial download
+func (f *fetcher) saveState(st *partialState, h hash.Hash) error {
+	var err error
+	st.Hash, err = h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := f.statePath() + ".new"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.statePath())
+}
+
+// fetchOnce makes one request for the rest of the file, appending to the
+// partial file. A server that doesn't resume the same file restarts the
+// download from the beginning, and one with nothing left to send completes
+// it.
+func (f *fetcher) fetchOnce(ctx context.Context, st *partialState, h hash.Hash, progress ProgressFunc) error {
+	out, err := os.OpenFile(f.tmpPath(), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer out.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", f.url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set(HeaderName, AppSecret)
+	if st.Size > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", st.Size))
+		if st.Validator != "" {
+			// Sends the whole file instead if it changed on the server
+			req.Header.Set("If-Range", st.Validator)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != st.Size {
+			f.restart(st, h)
+			return fmt.Errorf("unexpected range %q", resp.Header.Get("Content-Range"))
+		}
+		st.Total = total
+	case http.StatusOK:
+		f.restart(st, h)
+		st.Validator = resp.Header.Get("ETag")
+		if st.Validator == "" {
+			st.Validator = resp.Header.Get("Last-Modified")
+		}
+		st.Total = max(resp.ContentLength, 0)
+	case http.StatusRequestedRangeNotSatisfiable:
+		if st.Total > 0 && st.Size == st.Total {
+			// The download stopped after the whole file was there
+			if err := out.Truncate(st.Size); err != nil {
+				return fmt.Errorf("truncate file: %w", err)
+			}
+			return nil
+		}
+		// The partial file doesn't fit the file on the server, e.g. one
+		// replaced under the same validator; the retry starts over
+		f.restart(st, h)
+		return fmt.Errorf("download failed: HTTP %d, restarting", resp.StatusCode)
+	default:
+		return &statusError{resp.StatusCode}
+	}
+
+	if err := out.Truncate(st.Size); err != nil {
+		return fmt.Errorf("truncate file: %w", err)
+	}
+	if _, err := out.Seek(st.Size, io.SeekStart); err != nil {
+		return fmt.Errorf("seek file: %w", err)
+	}
+
+	buf := make([]byte, 32*1024) // 32KB buffer
+	unsaved := int64(0)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				return fmt.Errorf("write file: %w", err)
+			}
+			h.Write(buf[:n])
+			st.Size += int64(n)
+			unsaved += int64(n)
+			if progress != nil {
+				progress(st.Size, st.Total)
+			}
+			if unsaved >= saveInterval {
+				if err := f.saveState(st, h); err != nil {
+					return fmt.Errorf("save download state: %w", err)
+				}
+				unsaved = 0
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr == nil {
+			// Buffered data may still arrive after cancellation
+			readErr = ctx.Err()
+		}
+		if readErr != nil {
+			f.saveState(st, h)
+			return fmt.Errorf("read response: %w", readErr)
+		}
+	}
+
+	if st.Total > 0 && st.Size < st.Total {
+		f.saveState(st, h)
+		return fmt.Errorf("read response: %w", io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// restart discards the partial download
+func (f *fetcher) restart(st *partialState, h hash.Hash) {
+	*st = partialState{URL: f.url, Checksum: f.checksum}
+	h.Reset()
+	os.Remove(f.statePath())
+}
+
+// parseContentRange parses a "bytes start-end/total" header. The total is
+// 0 when unknown ("*").
+func parseContentRange(header string) (start, total int64, ok bool) {
+	rangeSpec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, size, found := strings.Cut(rangeSpec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, total, true
+}
@@ -0,0 +1,333 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cutWriter aborts the connection after limit bytes of the body
+type cutWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *cutWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		w.ResponseWriter.Write(p[:w.limit])
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.limit -= len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// flakyServer serves content with range support, dropping the connection
+// after cut bytes for the first drops requests. It records each request's
+// Range header.
+type flakyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	drops   int
+	cut     int
+	ranges  []string
+}
+
+func newFlakyServer(t *testing.T, content []byte, drops, cut int) *flakyServer {
+	t.Helper()
+	s := &flakyServer{content: content, etag: `"v1"`, drops: drops, cut: cut}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderName) != AppSecret {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		content, etag := s.content, s.etag
+		drop := s.drops > 0
+		if drop {
+			s.drops--
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("ETag", etag)
+		if drop {
+			w = &cutWriter{ResponseWriter: w, limit: s.cut}
+		}
+		http.ServeContent(w, r, "dict.db", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *flakyServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func testContent(size int) ([]byte, string) {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(content)
+	sum := sha256.Sum256(content)
+	return content, hex.EncodeToString(sum[:])
+}
+
+func newTestFetcher(t *testing.T, url, checksum string) *fetcher {
+	t.Helper()
+	return &fetcher{
+		url:        url,
+		checksum:   checksum,
+		path:       filepath.Join(t.TempDir(), "sanskrit.db"),
+		client:     &http.Client{},
+		minBackoff: time.Millisecond,
+		maxBackoff: 10 * time.Millisecond,
+		maxRetries: 3,
+	}
+}
+
+func assertDownloaded(t *testing.T, f *fetcher, content []byte) {
+	t.Helper()
+	got, err := os.ReadFile(f.path)
+	if err != nil {
+		t.Fatalf("downloaded file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes differing from the %d served", len(got), len(content))
+	}
+	for _, leftover := range []string{f.tmpPath(), f.statePath()} {
+		if _, err := os.Stat(leftover); err == nil {
+			t.Errorf("%s left behind", filepath.Base(leftover))
+		}
+	}
+}
+
+func TestFetchResumesAfterDrops(t *testing.T) {
+	content, checksum := testContent(1 << 20)
+	srv := newFlakyServer(t, content, 3, 100_000)
+	f := newTestFetcher(t, srv.URL, checksum)
+
+	var last, total int64
+	err := f.fetch(context.Background(), func(downloaded, size int64) {
+		if downloaded < last {
+			t.Errorf("progress went back from %d to %d", last, downloaded)
+		}
+		last, total = downloaded, size
+	})
+	if err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+	assertDownloaded(t, f, content)
+	if last != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("last progress = %d/%d, want %d", last, total, len(content))
+	}
+
+	// Every retry asks for the rest of the file only
+	ranges := srv.requests()
+	if len(ranges) != 4 || ranges[0] != "" {
+		t.Fatalf("requests = %q, want a full request and 3 resumes", ranges)
+	}
+	for _, r := range ranges[1:] {
+		if !strings.HasPrefix(r, "bytes=") || r == "bytes=0-" {
+			t.Errorf("retry requested %q, want the rest of the file", r)
+		}
+	}
+}
+
+func TestFetchResumesFromDisk(t *testing.T) {
+	content, checksum := testContent(1 << 20)
+	srv := newFlakyServer(t, content, 100, 300_000)
+	f := newTestFetcher(t, srv.URL, checksum)
+	f.maxRetries = 0
+
+	// The first attempt fails, keeping the partial file and its state
+	if err := f.fetch(context.Background(), nil); err == nil {
+		t.Fatal("fetch() succeeded through a dropped connection")
+	}
+	if _, err := os.Stat(f.statePath()); err != nil {
+		t.Fatalf("no download state kept: %v", err)
+	}
+
+	// The next run, e.g. after restarting the app, continues where it stopped
+	srv.mu.Lock()
+	srv.drops = 0
+	srv.mu.Unlock()
+	if err := f.fetch(context.Background(), nil); err != nil {
+		t.Fatalf("second fetch() error = %v", err)
+	}
+	assertDownloaded(t, f, content)
+	if ranges := srv.requests(); ranges[len(ranges)-1] != "bytes=300000-" {
+		t.Errorf("resumed with %q, want bytes=300000-", ranges[len(ranges)-1])
+	}
+}
+
+func TestFetchRestartsWhenFileChanged(t *testing.T) {
+	old, _ := testContent(1 << 20)
+	srv := newFlakyServer(t, old, 1, 300_000)
+	f := newTestFetcher(t, srv.URL, "")
+	f.maxRetries = 0
+	f.fetch(context.Background(), nil)
+
+	// A new version of the file is published before the download resumes
+	content := append([]byte("new version"), old...)
+	srv.mu.Lock()
+	srv.content, srv.etag = content, `"v2"`
+	srv.mu.Unlock()
+
+	if err := f.fetch(context.Background(), nil); err != nil {
+		t.Fatalf("fetch() error = %v", err)
+	}
+	assertDownloaded(t, f, content)
+}
+
+// writePartial leaves a partial download of part of a file of total bytes,
+// as if the app stopped while downloading it
+func writePartial(t *testing.T, f *fetcher, part []byte, total int64) {
+	t.Helper()
+	if err := os.WriteFile(f.tmpPath(), part, 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	h.Write(part)
+	st := partialState{URL: f.url, Checksum: f.checksum, Validator: `"v1"`, Size: int64(len(part)), Total: total}
+	if err := f.saveState(&st, h); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchRangeNotSatisfiable(t *testing.T) {
+	content, checksum := testContent(100_000)
+	srv := newFlakyServer(t, content, 0, 0)
+
+	// Stopped after the whole file was downloaded: nothing is left to send
+	f := newTestFetcher(t, srv.URL, checksum)
+	writePartial(t, f, content, int64(len(content)))
+	if err := f.fetch(context.Background(), nil); err != nil {
+		t.Fatalf("fetch() of a complete download error = %v", err)
+	}
+	assertDownloaded(t, f, content)
+	if ranges := srv.requests(); len(ranges) != 1 || ranges[0] != "bytes=100000-" {
+		t.Errorf("requests = %q, want a single resume", ranges)
+	}
+
+	// A partial file longer than the file on the server starts over
+	longer, _ := testContent(200_000)
+	f = newTestFetcher(t, srv.URL, checksum)
+	writePartial(t, f, longer[:150_000], int64(len(longer)))
+	if err := f.fetch(context.Background(), nil); err != nil {
+		t.Fatalf("fetch() of a stale download error = %v", err)
+	}
+	assertDownloaded(t, f, content)
+	if ranges := srv.requests(); len(ranges) != 3 || ranges[1] != "bytes=150000-" || ranges[2] != "" {
+		t.Errorf("requests = %q, want a resume and a full request", ranges)
+	}
+}
+
+func TestFetchChecksumMismatch(t *testing.T) {
+	content, _ := testContent(100_000)
+	srv := newFlakyServer(t, content, 0, 0)
+	f := newTestFetcher(t, srv.URL, strings.Repeat("0", 64))
+
+	if err := f.fetch(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("fetch() error = %v, want checksum mismatch", err)
+	}
+	for _, path := range []string{f.path, f.tmpPath(), f.statePath()} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s kept after a checksum mismatch", filepath.Base(path))
+		}
+	}
+}
+
+func TestFetchGivesUp(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// Server errors are retried, up to maxRetries times
+	f := newTestFetcher(t, srv.URL+"/busy", "")
+	var se *statusError
+	if err := f.fetch(context.Background(), nil); !errors.As(err, &se) || se.code != http.StatusServiceUnavailable {
+		t.Errorf("fetch() error = %v, want HTTP 503", err)
+	}
+	if requests != f.maxRetries+1 {
+		t.Errorf("made %d requests, want %d", requests, f.maxRetries+1)
+	}
+
+	// Client errors are not
+	requests = 0
+	f = newTestFetcher(t, srv.URL+"/missing", "")
+	if err := f.fetch(context.Background(), nil); !errors.As(err, &se) || se.code != http.StatusNotFound {
+		t.Errorf("fetch() error = %v, want HTTP 404", err)
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests for a missing file, want 1", requests)
+	}
+}
+
+func TestFetchCancel(t *testing.T) {
+	content, checksum := testContent(1 << 20)
+	srv := newFlakyServer(t, content, 0, 0)
+	f := newTestFetcher(t, srv.URL, checksum)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := f.fetch(ctx, func(downloaded, total int64) {
+		if downloaded >= 200_000 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("fetch() error = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(f.path); err == nil {
+		t.Error("cancelled download was installed")
+	}
+	if _, err := os.Stat(f.statePath()); err != nil {
+		t.Errorf("cancelled download can't be resumed: %v", err)
+	}
+
+	if err := f.fetch(context.Background(), nil); err != nil {
+		t.Fatalf("fetch() after cancel error = %v", err)
+	}
+	assertDownloaded(t, f, content)
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 100-199/1000", 100, 1000, true},
+		{"bytes 0-99/*", 0, 0, true},
+		{"bytes */1000", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.header)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, want %d, %d, %v",
+				tt.header, start, total, ok, tt.start, tt.total, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package download
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Published data is authenticated by an Ed25519 signature of its manifest,
+// published next to it as manifest.json.sig (base64). The manifest holds
+// the SHA-256 checksum of every file, so a verified manifest vouches for
+// the database, shards and deltas downloaded with it.
+
+const (
+	// SignatureSuffix is appended to the URL or path of a manifest for
+	// its signature.
+	SignatureSuffix = ".sig"
+
+	// AllowUnsignedEnv names the environment variable that, set to 1,
+	// lets data without a valid signature be installed, e.g. data built
+	// locally.
+	AllowUnsignedEnv = "SANSKRIT_UPAYA_ALLOW_UNSIGNED"
+
+	// maxSignatureSize limits reading a signature file
+	maxSignatureSize = 1024
+)
+
+// PublicKey is the base64 Ed25519 key that published data is signed with.
+// Release builds set it with -ldflags
+// "-X github.com/licht1stein/sanskrit-upaya/pkg/download.PublicKey=...";
+// builds without it refuse signed data unless unsigned data is allowed.
+var PublicKey = ""
+
+// AllowUnsigned lets data without a valid signature be installed, with a
+// warning. Setting AllowUnsignedEnv does the same.
+var AllowUnsigned = false
+
+var (
+	// ErrUnsigned is returned for data without a signature.
+	ErrUnsigned = errors.New("the dictionary data is not signed")
+
+	// ErrBadSignature is returned for data whose signature doesn't match
+	// it or the app's public key.
+	ErrBadSignature = errors.New("the signature of the dictionary data is invalid")
+
+	// ErrNoPublicKey is returned for data checked by a build without
+	// PublicKey.
+	ErrNoPublicKey = errors.New("this build of the app has no key to verify the dictionary data with")
+)
+
+// allowUnsigned reports whether data without a valid signature may be used
+func allowUnsigned() bool {
+	return AllowUnsigned || os.Getenv(AllowUnsignedEnv) == "1"
+}
+
+// verifyManifest checks the signature of a manifest's data, nil if it has
+// none. Data without a valid signature is refused unless allowed.
+func verifyManifest(data, sig []byte) error {
+	err := checkSignature(data, sig)
+	if err != nil && allowUnsigned() {
+		log.Printf("Warning: using dictionary data without a valid signature: %v", err)
+		return nil
+	}
+	return err
+}
+
+// checkSignature verifies a base64 signature of data with PublicKey
+func checkSignature(data, sig []byte) error {
+	if sig == nil {
+		return ErrUnsigned
+	}
+	if PublicKey == "" {
+		return ErrNoPublicKey
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: the app's public key is malformed", ErrBadSignature)
+	}
+	s, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(key, data, s) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// SignManifest returns the signature of a manifest's data, to publish
+// next to the manifest.
+func SignManifest(data []byte, key ed25519.PrivateKey) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n")
+}
+
+// fetchSignature fetches the signature published next to a manifest, nil
+// if there is none
+func fetchSignature(ctx context.Context, manifestURL *url.URL) ([]byte, error) {
+	sigURL := *manifestURL
+	sigURL.Path += SignatureSuffix
+	req, err := http.NewRequestWithContext(ctx, "GET", sigURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set(HeaderName, AppSecret)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("signature request: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("signature: %w", &statusError{resp.StatusCode})
+	}
+	sig, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	return sig, nil
+}
@@ -0,0 +1,107 @@
+package download
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testKey signs the manifests of the tests, in place of the key of the
+// published data
+var testKey ed25519.PrivateKey
+
+func init() {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	testKey = priv
+	PublicKey = base64.StdEncoding.EncodeToString(pub)
+}
+
+// signedManifest encodes a manifest and signs it with the test key
+func signedManifest(m Manifest) (data, sig []byte) {
+	data, _ = json.Marshal(m)
+	return data, SignManifest(data, testKey)
+}
+
+// allowUnsignedData allows unsigned data for the rest of a test
+func allowUnsignedData(t *testing.T) {
+	AllowUnsigned = true
+	t.Cleanup(func() { AllowUnsigned = false })
+}
+
+func TestFetchManifestSignature(t *testing.T) {
+	_, checksum := testContent(1000)
+	m := Manifest{
+		Version: "2025.06.01",
+		Files:   []ManifestFile{{Name: DatabaseFile, URL: "sanskrit.db", Size: 1000, SHA256: checksum}},
+	}
+	data, sig := signedManifest(m)
+	m.Version = "2025.06.02"
+	tampered, _ := signedManifest(m)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name     string
+		manifest []byte
+		sig      []byte // nil for none
+		want     error
+	}{
+		{"signed", data, sig, nil},
+		{"unsigned", data, nil, ErrUnsigned},
+		{"tampered", tampered, sig, ErrBadSignature},
+		{"other key", data, SignManifest(data, otherKey), ErrBadSignature},
+		{"garbage", data, []byte("not a signature"), ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/manifest.json":
+					w.Write(tt.manifest)
+				case r.URL.Path == "/manifest.json.sig" && tt.sig != nil:
+					w.Write(tt.sig)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			_, err := FetchManifest(context.Background(), srv.URL+"/manifest.json")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("FetchManifest() error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			// Unless unsigned data is allowed explicitly
+			t.Setenv(AllowUnsignedEnv, "1")
+			if _, err := FetchManifest(context.Background(), srv.URL+"/manifest.json"); err != nil {
+				t.Errorf("FetchManifest() with %s set error = %v", AllowUnsignedEnv, err)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutPublicKey(t *testing.T) {
+	key := PublicKey
+	PublicKey = ""
+	defer func() { PublicKey = key }()
+
+	data, sig := signedManifest(Manifest{Version: "2025.06.01"})
+	if err := verifyManifest(data, sig); !errors.Is(err, ErrNoPublicKey) {
+		t.Errorf("verifyManifest() error = %v, want ErrNoPublicKey", err)
+	}
+	allowUnsignedData(t)
+	if err := verifyManifest(data, sig); err != nil {
+		t.Errorf("verifyManifest() with unsigned data allowed error = %v", err)
+	}
+}
@@ -44,3 +44,27 @@ func GetStatePath() (string, error) {
 	}
 	return filepath.Join(dataDir, "state.db"), nil
 }
+
+// GetProfilesDir returns the directory holding the user profiles other than
+// the default one, which keeps its state in the data directory itself.
+func GetProfilesDir() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "profiles"), nil
+}
+
+// GetProfileStatePath returns the path of a named profile's state database.
+// The profile's directory is created if it doesn't exist.
+func GetProfileStatePath(profile string) (string, error) {
+	profilesDir, err := GetProfilesDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(profilesDir, profile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.db"), nil
+}
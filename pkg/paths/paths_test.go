@@ -104,3 +104,24 @@ func TestPathsConsistency(t *testing.T) {
 		t.Errorf("StatePath %v not under DataDir %v", statePath, dataDir)
 	}
 }
+
+func TestGetProfileStatePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("XDG_DATA_HOME", tmpDir)
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	path, err := GetProfileStatePath("asha")
+	if err != nil {
+		t.Fatalf("GetProfileStatePath() error = %v", err)
+	}
+
+	expected := filepath.Join(tmpDir, "sanskrit-dictionary", "profiles", "asha", "state.db")
+	if path != expected {
+		t.Errorf("GetProfileStatePath() = %v, want %v", path, expected)
+	}
+
+	// Check the profile directory was created
+	if _, err := os.Stat(filepath.Dir(path)); os.IsNotExist(err) {
+		t.Errorf("Directory was not created: %v", filepath.Dir(path))
+	}
+}
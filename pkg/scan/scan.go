@@ -0,0 +1,82 @@
+// Package scan finds page images of the printed dictionaries in a locally
+// installed scan folder.
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when the folder has no scan of the page.
+var ErrNotFound = errors.New("page scan not found")
+
+// extensions lists the file types accepted as page scans
+var extensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".tif": true, ".tiff": true, ".webp": true, ".pdf": true,
+}
+
+var numberRe = regexp.MustCompile(`\d+`)
+
+// Find returns the path of the scan of a printed page of a dictionary.
+//
+// Scans are looked up first in a subfolder named after the dictionary
+// (dir/mw), where the last number in a file name is its page: "0510.png",
+// "p510.jpg" and "page-510.pdf" are all page 510. Scans kept directly in
+// dir must start with the dictionary code: "mw0510.png", "mw_510.jpg".
+func Find(dir, dictCode string, page int) (string, error) {
+	if dir == "" {
+		return "", ErrNotFound
+	}
+	if path, ok := findIn(filepath.Join(dir, dictCode), "", page); ok {
+		return path, nil
+	}
+	if path, ok := findIn(dir, strings.ToLower(dictCode), page); ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("%w: %s page %d in %s", ErrNotFound, dictCode, page, dir)
+}
+
+// findIn looks for a scan of page among the files of dir whose names start
+// with prefix followed by a non-letter.
+func findIn(dir, prefix string, page int) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if !extensions[ext] {
+			continue
+		}
+		base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+		if prefix != "" {
+			rest, ok := strings.CutPrefix(base, prefix)
+			if !ok || (rest != "" && isLetter(rest[0])) {
+				continue
+			}
+			base = rest
+		}
+		numbers := numberRe.FindAllString(base, -1)
+		if len(numbers) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(numbers[len(numbers)-1]); err == nil && n == page {
+			return filepath.Join(dir, name), true
+		}
+	}
+	return "", false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
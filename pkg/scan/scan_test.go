@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "mw", "0510.png"))
+	touch(t, filepath.Join(dir, "mw", "notes.txt"))
+	touch(t, filepath.Join(dir, "mw", "vol1-page-0511.pdf"))
+	touch(t, filepath.Join(dir, "ap90_0042.jpg"))
+	touch(t, filepath.Join(dir, "ap900043.jpg"))
+	touch(t, filepath.Join(dir, "mwe0042.jpg"))
+
+	tests := []struct {
+		dictCode string
+		page     int
+		want     string
+	}{
+		{"mw", 510, filepath.Join(dir, "mw", "0510.png")},
+		{"mw", 511, filepath.Join(dir, "mw", "vol1-page-0511.pdf")},
+		{"ap90", 42, filepath.Join(dir, "ap90_0042.jpg")},
+		{"ap90", 43, filepath.Join(dir, "ap900043.jpg")},
+		{"mw", 42, ""},   // "mwe0042" belongs to another dictionary
+		{"pwg", 510, ""}, // no scans installed
+	}
+
+	for _, tt := range tests {
+		got, err := Find(dir, tt.dictCode, tt.page)
+		if tt.want == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Find(%s, %d) = %q, %v, want ErrNotFound", tt.dictCode, tt.page, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Find(%s, %d) = %q, %v, want %q", tt.dictCode, tt.page, got, err, tt.want)
+		}
+	}
+}
+
+func TestFindNoFolder(t *testing.T) {
+	if _, err := Find("", "mw", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find() without folder error = %v, want ErrNotFound", err)
+	}
+	if _, err := Find(filepath.Join(t.TempDir(), "missing"), "mw", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find() in missing folder error = %v, want ErrNotFound", err)
+	}
+}
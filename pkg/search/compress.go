@@ -0,0 +1,251 @@
+package search
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"modernc.org/sqlite"
+)
+
+// Article content may be stored compressed to cut the size of the
+// database on disk: an article is then a zstd frame stored as a BLOB,
+// compressed with a dictionary of sample articles that all articles of a
+// build share. The dictionaries are kept in the article_dicts table and
+// named by the frames, so databases and shards of different builds can be
+// open at once. Content stored as TEXT is plain. The SQL function
+// article_text(content) returns the plain text of either, for queries and
+// the triggers filling the full-text index.
+
+// ErrUnknownArticleDict is returned for an article compressed with a
+// dictionary that no open database has.
+var ErrUnknownArticleDict = errors.New("article compressed with an unknown dictionary")
+
+// maxArticleDictSize is the size of a compression dictionary, zstd's
+// default for trained dictionaries
+const maxArticleDictSize = 110 << 10
+
+// zstdMagic starts every zstd frame; article text never does, since 0xB5
+// can't follow '(' in UTF-8
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// articleDecoders decompress articles by the ID of their dictionary
+var articleDecoders = struct {
+	sync.RWMutex
+	byID map[uint32]*zstd.Decoder
+}{byID: make(map[uint32]*zstd.Decoder)}
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("article_text", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if content, ok := args[0].([]byte); ok {
+			return articleText(content)
+		}
+		return args[0], nil
+	})
+}
+
+// registerArticleDict makes the articles compressed with a dictionary
+// readable
+func registerArticleDict(id uint32, dict []byte) error {
+	articleDecoders.Lock()
+	defer articleDecoders.Unlock()
+	if _, ok := articleDecoders.byID[id]; ok {
+		return nil
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDictRaw(id, dict))
+	if err != nil {
+		return fmt.Errorf("article dictionary %d: %w", id, err)
+	}
+	articleDecoders.byID[id] = dec
+	return nil
+}
+
+// querier is a database or transaction to read from
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// loadArticleDicts registers the compression dictionaries of a schema
+// ("main", or an attached database)
+func loadArticleDicts(q querier, schema string) error {
+	rows, err := q.Query("SELECT id, dict FROM " + schema + ".article_dicts")
+	if err != nil {
+		return fmt.Errorf("read article dictionaries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var dict []byte
+		if err := rows.Scan(&id, &dict); err != nil {
+			return err
+		}
+		if err := registerArticleDict(uint32(id), dict); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// copyArticleDicts copies the compression dictionaries between schemas,
+// so that the compressed articles copied with them stay readable
+func copyArticleDicts(tx *sql.Tx, from, to string) error {
+	if !tableExists(tx, from, "article_dicts") {
+		return nil
+	}
+	if err := loadArticleDicts(tx, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS " + to + ".article_dicts (id INTEGER PRIMARY KEY, dict BLOB NOT NULL)"); err != nil {
+		return err
+	}
+	_, err := tx.Exec("INSERT OR IGNORE INTO " + to + ".article_dicts (id, dict) SELECT id, dict FROM " + from + ".article_dicts")
+	return err
+}
+
+// articleText returns the plain text of an article's stored content
+func articleText(content []byte) (string, error) {
+	if !bytes.HasPrefix(content, zstdMagic) {
+		return string(content), nil
+	}
+	var h zstd.Header
+	if err := h.Decode(content); err != nil {
+		return "", fmt.Errorf("read compressed article: %w", err)
+	}
+	articleDecoders.RLock()
+	dec := articleDecoders.byID[h.DictionaryID]
+	articleDecoders.RUnlock()
+	if dec == nil {
+		return "", fmt.Errorf("%w: %d", ErrUnknownArticleDict, h.DictionaryID)
+	}
+	text, err := dec.DecodeAll(content, nil)
+	if err != nil {
+		return "", fmt.Errorf("decompress article: %w", err)
+	}
+	return string(text), nil
+}
+
+// CompressArticles stores the content of the plain articles compressed,
+// with a dictionary of sample articles. Searching is unchanged; reading an
+// article decompresses it. Call this after RebuildFTS and before Optimize,
+// which reclaims the space saved.
+func (d *DB) CompressArticles() error {
+	dict, err := d.sampleArticles()
+	if err != nil {
+		return err
+	}
+	if len(dict) < 8 {
+		return nil // Too little text to compress
+	}
+	sum := sha256.Sum256(dict)
+	// IDs below 32768 are reserved by zstd
+	id := binary.LittleEndian.Uint32(sum[:])&0x7fffffff | 0x8000
+	if err := registerArticleDict(id, dict); err != nil {
+		return err
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderDictRaw(id, dict), zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS article_dicts (id INTEGER PRIMARY KEY, dict BLOB NOT NULL)"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO article_dicts (id, dict) VALUES (?, ?)", int64(id), dict); err != nil {
+		return err
+	}
+	// The text is unchanged, and so is the full-text index
+	if _, err := tx.Exec("DROP TRIGGER IF EXISTS articles_au"); err != nil {
+		return err
+	}
+
+	update, err := tx.Prepare("UPDATE articles SET content = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+	type row struct {
+		id      int64
+		content string
+	}
+	for lastID := int64(-1); ; {
+		var batch []row
+		rows, err := tx.Query(`SELECT id, content FROM articles WHERE id > ? AND typeof(content) = 'text' ORDER BY id LIMIT 1000`, lastID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.content); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, r := range batch {
+			compressed := enc.EncodeAll([]byte(r.content), nil)
+			if len(compressed) >= len(r.content) {
+				continue // Short articles are kept as they are
+			}
+			if _, err := update.Exec(compressed, r.id); err != nil {
+				return fmt.Errorf("compress article %d: %w", r.id, err)
+			}
+		}
+		lastID = batch[len(batch)-1].id
+	}
+
+	if tableExists(tx, "main", "articles_fts") {
+		if err := ensureTriggers(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sampleArticles returns a compression dictionary: articles spread over
+// all dictionaries, up to maxArticleDictSize
+func (d *DB) sampleArticles() ([]byte, error) {
+	var total int64
+	if err := d.db.QueryRow(`SELECT COALESCE(SUM(LENGTH(content)), 0) FROM articles WHERE typeof(content) = 'text'`).Scan(&total); err != nil {
+		return nil, err
+	}
+	step := max(total/maxArticleDictSize, 1)
+	rows, err := d.db.Query(`SELECT content FROM articles WHERE typeof(content) = 'text' AND id % ? = 0 ORDER BY id`, step)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dict []byte
+	for rows.Next() && len(dict) < maxArticleDictSize {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return nil, err
+		}
+		dict = append(dict, content...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(dict) > maxArticleDictSize {
+		dict = dict[:maxArticleDictSize]
+	}
+	return dict, nil
+}
@@ -0,0 +1,117 @@
+package search
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// forgetArticleDicts unregisters the compression dictionaries, as in a
+// new process
+func forgetArticleDicts() {
+	articleDecoders.Lock()
+	clear(articleDecoders.byID)
+	articleDecoders.Unlock()
+}
+
+func TestCompressArticles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sanskrit.db")
+	db := createTestDBAt(t, path)
+	plain, err := db.GetArticleContents([]int64{1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatalf("GetArticleContents() error = %v", err)
+	}
+
+	if err := db.CompressArticles(); err != nil {
+		t.Fatalf("CompressArticles() error = %v", err)
+	}
+	var compressed int
+	db.db.QueryRow(`SELECT COUNT(*) FROM articles WHERE typeof(content) = 'blob'`).Scan(&compressed)
+	if compressed == 0 {
+		t.Fatal("no article was compressed")
+	}
+	db.Close()
+
+	forgetArticleDicts()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	contents, err := db.GetArticleContents([]int64{1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatalf("GetArticleContents() error = %v", err)
+	}
+	for id, want := range plain {
+		if contents[id] != want {
+			t.Errorf("article %d = %q, want %q", id, contents[id], want)
+		}
+		if got, err := db.GetArticleContent(id); err != nil || got != want {
+			t.Errorf("GetArticleContent(%d) = %q, %v, want %q", id, got, err, want)
+		}
+	}
+	if results, err := db.GetArticle(1); err != nil || len(results) != 1 || results[0].Content != plain[1] {
+		t.Errorf("GetArticle(1) = %+v, %v", results, err)
+	}
+
+	// The full-text index and the previews in results read the text
+	results, err := db.Search("weapon", ModeReverse, nil)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Word != "arma" {
+		t.Errorf("Search(weapon) = %+v, want the article arma", results)
+	}
+
+	// Removing a dictionary removes its compressed articles from the index
+	if err := db.RemoveDict("mw"); err != nil {
+		t.Fatalf("RemoveDict() error = %v", err)
+	}
+	if results, _ := db.Search("weapon", ModeReverse, nil); len(results) != 0 {
+		t.Errorf("Search(weapon) after removing mw = %+v", results)
+	}
+	if err := db.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+}
+
+func TestInstallCompressedShard(t *testing.T) {
+	dir := t.TempDir()
+	full := createTestDBAt(t, filepath.Join(dir, "full.db"))
+	defer full.Close()
+	if err := full.CompressArticles(); err != nil {
+		t.Fatalf("CompressArticles() error = %v", err)
+	}
+	shard := filepath.Join(dir, "mw.db")
+	if err := full.ExportShard("mw", shard); err != nil {
+		t.Fatalf("ExportShard() error = %v", err)
+	}
+
+	forgetArticleDicts()
+	db := createEmptyDB(t)
+	if err := db.InstallShard(shard); err != nil {
+		t.Fatalf("InstallShard() error = %v", err)
+	}
+	results, err := db.Search("weapon", ModeReverse, nil)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Search(weapon) = %+v, want 1 result", results)
+	}
+	content, err := db.GetArticleContent(results[0].ArticleID)
+	if err != nil || content != "arma n. weapon, arm" {
+		t.Errorf("GetArticleContent() = %q, %v", content, err)
+	}
+
+	// Without its dictionary a compressed article can't be read
+	var id int64
+	if err := db.db.QueryRow(`SELECT id FROM articles WHERE typeof(content) = 'blob' LIMIT 1`).Scan(&id); err != nil {
+		t.Fatalf("no compressed article installed: %v", err)
+	}
+	forgetArticleDicts()
+	if _, err := db.GetArticleContent(id); !errors.Is(err, ErrUnknownArticleDict) {
+		t.Errorf("GetArticleContent() without the dictionary error = %v, want ErrUnknownArticleDict", err)
+	}
+}
@@ -0,0 +1,425 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ErrNoSourceIDs is returned when diffing or patching a database built
+// before articles kept their IDs in the source data.
+var ErrNoSourceIDs = errors.New("the dictionary database has no source IDs; download the latest database to apply updates")
+
+// Delta is the difference between two builds of the dictionary data: the
+// dictionaries added, changed or removed, and in changed dictionaries the
+// articles added, changed or removed. Articles are keyed by their source
+// IDs, since databases built before article IDs were derived from them
+// number the articles differently; the installed articles keep their IDs,
+// and with them stars, notes and review progress. Added articles get the
+// IDs of the new build, so that its shards fit the patched database.
+type Delta struct {
+	Dicts []DictDelta `json:"dicts"`
+}
+
+// DictDelta is the change of a dictionary.
+type DictDelta struct {
+	Code     string `json:"code"`
+	Name     string `json:"name,omitempty"`
+	FromLang string `json:"from_lang,omitempty"`
+	ToLang   string `json:"to_lang,omitempty"`
+	Favorite bool   `json:"favorite,omitempty"`
+	Removed  bool   `json:"removed,omitempty"` // The whole dictionary is removed
+
+	Articles        []ArticleDelta `json:"articles,omitempty"`         // Added or changed
+	RemovedArticles []string       `json:"removed_articles,omitempty"` // Source IDs
+}
+
+// ArticleDelta is an added or changed article with everything indexed
+// from it.
+type ArticleDelta struct {
+	ID        int64           `json:"id"`
+	SourceID  string          `json:"source_id"`
+	Content   string          `json:"content"`
+	Homonym   int             `json:"homonym,omitempty"`
+	LNumber   float64         `json:"lnum,omitempty"`
+	Page      string          `json:"page,omitempty"`
+	Words     []DeltaWord     `json:"words,omitempty"`
+	Citations []DeltaCitation `json:"citations,omitempty"`
+	Roots     []DeltaRoot     `json:"roots,omitempty"`
+}
+
+// DeltaWord is a headword of an article.
+type DeltaWord struct {
+	IAST string `json:"iast"`
+	Deva string `json:"deva,omitempty"`
+}
+
+// DeltaCitation is a passage cited by an article.
+type DeltaCitation struct {
+	Work  string `json:"work"`
+	Book  int    `json:"book"`
+	Verse string `json:"verse"`
+}
+
+// DeltaRoot is a root family an article belongs to.
+type DeltaRoot struct {
+	Root string `json:"root"`
+	Kind string `json:"kind"`
+}
+
+// Empty reports whether the delta changes nothing.
+func (delta *Delta) Empty() bool {
+	return len(delta.Dicts) == 0
+}
+
+// Diff computes the delta that turns the old database into the new one.
+func Diff(oldDB, newDB *DB) (*Delta, error) {
+	oldDicts, err := dictsByCode(oldDB)
+	if err != nil {
+		return nil, err
+	}
+	newDicts, err := dictsByCode(newDB)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &Delta{}
+	for _, code := range sortedKeys(newDicts) {
+		nd := newDicts[code]
+		newArticles, err := newDB.sourceArticles(code)
+		if err != nil {
+			return nil, err
+		}
+		oldArticles := map[string]ArticleDelta{}
+		od, had := oldDicts[code]
+		if had {
+			if oldArticles, err = oldDB.sourceArticles(code); err != nil {
+				return nil, err
+			}
+		}
+
+		dd := DictDelta{Code: code, Name: nd.Name, FromLang: nd.FromLang, ToLang: nd.ToLang, Favorite: nd.Favorite}
+		for _, sourceID := range sortedKeys(newArticles) {
+			a := newArticles[sourceID]
+			o, ok := oldArticles[sourceID]
+			// The installed article keeps its ID
+			o.ID = a.ID
+			if !ok || !reflect.DeepEqual(o, a) {
+				dd.Articles = append(dd.Articles, a)
+			}
+		}
+		for _, sourceID := range sortedKeys(oldArticles) {
+			if _, ok := newArticles[sourceID]; !ok {
+				dd.RemovedArticles = append(dd.RemovedArticles, sourceID)
+			}
+		}
+		if !had || od != nd || len(dd.Articles) > 0 || len(dd.RemovedArticles) > 0 {
+			delta.Dicts = append(delta.Dicts, dd)
+		}
+	}
+	for _, code := range sortedKeys(oldDicts) {
+		if _, ok := newDicts[code]; !ok {
+			delta.Dicts = append(delta.Dicts, DictDelta{Code: code, Removed: true})
+		}
+	}
+	return delta, nil
+}
+
+// dictsByCode returns the dictionaries of a database by code
+func dictsByCode(d *DB) (map[string]Dict, error) {
+	dicts, err := d.GetDicts()
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]Dict, len(dicts))
+	for _, dict := range dicts {
+		byCode[dict.Code] = dict
+	}
+	return byCode, nil
+}
+
+// sortedKeys returns the keys of a map in order, for a stable delta
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sourceArticles loads the articles of a dictionary by source ID, with
+// their words, citations and roots in a fixed order
+func (d *DB) sourceArticles(dictCode string) (map[string]ArticleDelta, error) {
+	if !d.HasSourceIDs() {
+		return nil, ErrNoSourceIDs
+	}
+	articles := make(map[int64]*ArticleDelta)
+	rows, err := d.db.Query(`SELECT id, source_id, content, `+d.sourceColumns()+` FROM articles a WHERE dict_code = ?`, dictCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var content []byte
+		a := &ArticleDelta{}
+		if err := rows.Scan(&id, &a.SourceID, &content, &a.Homonym, &a.LNumber, &a.Page); err != nil {
+			return nil, err
+		}
+		if a.Content, err = articleText(content); err != nil {
+			return nil, err
+		}
+		if a.SourceID == "" {
+			return nil, fmt.Errorf("%w: article %d of %s", ErrNoSourceIDs, id, dictCode)
+		}
+		a.ID = id
+		articles[id] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = d.eachRow(`
+		SELECT article_id, word_iast, COALESCE(word_deva, '') FROM words
+		WHERE dict_code = ? ORDER BY article_id, word_iast, word_deva
+	`, dictCode, func(rows *sql.Rows) error {
+		var id int64
+		var w DeltaWord
+		if err := rows.Scan(&id, &w.IAST, &w.Deva); err != nil {
+			return err
+		}
+		if a := articles[id]; a != nil {
+			a.Words = append(a.Words, w)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d.hasTable("citations") {
+		err = d.eachRow(`
+			SELECT c.article_id, c.work, c.book, c.verse FROM citations c
+			JOIN articles a ON a.id = c.article_id
+			WHERE a.dict_code = ? ORDER BY c.article_id, c.work, c.book, c.verse
+		`, dictCode, func(rows *sql.Rows) error {
+			var id int64
+			var c DeltaCitation
+			if err := rows.Scan(&id, &c.Work, &c.Book, &c.Verse); err != nil {
+				return err
+			}
+			if a := articles[id]; a != nil {
+				a.Citations = append(a.Citations, c)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.hasTable("roots") {
+		err = d.eachRow(`
+			SELECT r.article_id, r.root, r.kind FROM roots r
+			JOIN articles a ON a.id = r.article_id
+			WHERE a.dict_code = ? ORDER BY r.article_id, r.root, r.kind
+		`, dictCode, func(rows *sql.Rows) error {
+			var id int64
+			var r DeltaRoot
+			if err := rows.Scan(&id, &r.Root, &r.Kind); err != nil {
+				return err
+			}
+			if a := articles[id]; a != nil {
+				a.Roots = append(a.Roots, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bySource := make(map[string]ArticleDelta, len(articles))
+	for _, a := range articles {
+		bySource[a.SourceID] = *a
+	}
+	return bySource, nil
+}
+
+// eachRow runs a query with a dictionary code and calls fn for each row
+func (d *DB) eachRow(query, dictCode string, fn func(*sql.Rows) error) error {
+	rows, err := d.db.Query(query, dictCode)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ApplyDelta applies a delta to the database in a single transaction, so
+// that a failed update leaves the database as it was. The full-text
+// indexes are updated by the triggers.
+func (d *DB) ApplyDelta(delta *Delta) error {
+	if !d.HasSourceIDs() {
+		return ErrNoSourceIDs
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ensureTriggers(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_articles_source ON articles(dict_code, source_id)`); err != nil {
+		return err
+	}
+	for _, dd := range delta.Dicts {
+		if err := applyDictDelta(tx, dd); err != nil {
+			return fmt.Errorf("update %s: %w", dd.Code, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// applyDictDelta applies the change of a dictionary. Only installed
+// dictionaries are changed; others are added from their shards.
+func applyDictDelta(tx *sql.Tx, dd DictDelta) error {
+	if dd.Removed {
+		return removeDict(tx, dd.Code)
+	}
+	result, err := tx.Exec(`
+		UPDATE dicts SET name = ?, from_lang = ?, to_lang = ?, favorite = ? WHERE code = ?
+	`, dd.Name, dd.FromLang, dd.ToLang, dd.Favorite, dd.Code)
+	if err != nil {
+		return err
+	}
+	// Dictionaries that aren't installed are skipped
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	for _, sourceID := range dd.RemovedArticles {
+		id, err := articleBySource(tx, dd.Code, sourceID)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			continue
+		}
+		if err := clearArticleIndex(tx, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM articles WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range dd.Articles {
+		id, err := articleBySource(tx, dd.Code, a.SourceID)
+		if err != nil {
+			return err
+		}
+		if id != 0 {
+			_, err = tx.Exec(`UPDATE articles SET content = ?, homonym = ?, lnum = ?, page = ? WHERE id = ?`,
+				a.Content, a.Homonym, a.LNumber, a.Page, id)
+			if err == nil {
+				err = clearArticleIndex(tx, id)
+			}
+		} else {
+			id, err = insertDeltaArticle(tx, dd.Code, a)
+		}
+		if err != nil {
+			return fmt.Errorf("article %s: %w", a.SourceID, err)
+		}
+		if err := indexArticle(tx, dd.Code, id, a); err != nil {
+			return fmt.Errorf("article %s: %w", a.SourceID, err)
+		}
+	}
+	return nil
+}
+
+// insertDeltaArticle inserts an added article with its ID in the new
+// build, or with a new ID if an article of a dictionary installed from
+// another build has it, and returns its ID
+func insertDeltaArticle(tx *sql.Tx, dictCode string, a ArticleDelta) (int64, error) {
+	var id any
+	if a.ID != 0 {
+		id = a.ID
+	}
+	for {
+		result, err := tx.Exec(`
+			INSERT INTO articles (id, dict_code, content, homonym, lnum, page, source_id) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO NOTHING
+		`, id, dictCode, a.Content, a.Homonym, a.LNumber, a.Page, a.SourceID)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			return result.LastInsertId()
+		}
+		id = nil
+	}
+}
+
+// articleBySource returns the ID of a dictionary's article with a source
+// ID, or 0 if there is none
+func articleBySource(tx *sql.Tx, dictCode, sourceID string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM articles WHERE dict_code = ? AND source_id = ?`, dictCode, sourceID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// clearArticleIndex deletes the words, citations and roots of an article
+func clearArticleIndex(tx *sql.Tx, id int64) error {
+	for _, table := range []string{"words", "citations", "roots"} {
+		if !tableExists(tx, "main", table) {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE article_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexArticle inserts the words, citations and roots of an article
+func indexArticle(tx *sql.Tx, dictCode string, id int64, a ArticleDelta) error {
+	for _, w := range a.Words {
+		if _, err := tx.Exec(`INSERT INTO words (word_iast, word_deva, article_id, dict_code) VALUES (?, ?, ?, ?)`,
+			w.IAST, w.Deva, id, dictCode); err != nil {
+			return err
+		}
+	}
+	if len(a.Citations) > 0 && tableExists(tx, "main", "citations") {
+		for _, c := range a.Citations {
+			if _, err := tx.Exec(`INSERT INTO citations (work, book, verse, article_id) VALUES (?, ?, ?, ?)`,
+				c.Work, c.Book, c.Verse, id); err != nil {
+				return err
+			}
+		}
+	}
+	if len(a.Roots) > 0 && tableExists(tx, "main", "roots") {
+		for _, r := range a.Roots {
+			if _, err := tx.Exec(`INSERT INTO roots (root, kind, article_id) VALUES (?, ?, ?)`,
+				r.Root, r.Kind, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package search
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+)
+
+type sourceArticle struct {
+	dict, sourceID, word, content string
+}
+
+// createSourceDB creates a database of articles with source IDs
+func createSourceDB(t *testing.T, path string, dicts []string, articles []sourceArticle) *DB {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InitSchemaForBulkInsert(); err != nil {
+		t.Fatal(err)
+	}
+	bi, err := db.NewBulkInserter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range dicts {
+		if err := bi.InsertDict(code, code+" dictionary", "sa", "en", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range articles {
+		src := article.SourceOfRecord(article.Record{Key: a.sourceID}, a.content)
+		id, err := bi.InsertSourceArticle(a.dict, a.sourceID, a.content, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bi.InsertWord(a.word, "", id, a.dict); err != nil {
+			t.Fatal(err)
+		}
+		if err := bi.InsertCitation("RV.", 1, "1", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bi.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RebuildFTS(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDiffAndApplyDelta(t *testing.T) {
+	dir := t.TempDir()
+	oldDB := createSourceDB(t, filepath.Join(dir, "old.db"), []string{"mw", "ap90"}, []sourceArticle{
+		{"mw", "1", "dharma", "dharma m. law"},
+		{"mw", "2", "karma", "karma n. act"},
+		{"mw", "3", "yoga", "yoga m. union"},
+		{"ap90", "1", "dharma", "dharma m. religion"},
+	})
+	newDB := createSourceDB(t, filepath.Join(dir, "new.db"), []string{"mw", "pw"}, []sourceArticle{
+		{"mw", "1", "dharma", "dharma m. law, duty"},
+		{"mw", "2", "karma", "karma n. act"},
+		{"mw", "4", "arma", "arma n. weapon"},
+		{"pw", "1", "dharma", "dharma m. Gesetz"},
+	})
+
+	dharma, err := oldDB.Search("dharma", ModeExact, []string{"mw"})
+	if err != nil || len(dharma) != 1 {
+		t.Fatalf("Search() = %v, %v", dharma, err)
+	}
+
+	delta, err := Diff(oldDB, newDB)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	changes := make(map[string]DictDelta)
+	for _, dd := range delta.Dicts {
+		changes[dd.Code] = dd
+	}
+	if mw := changes["mw"]; len(mw.Articles) != 2 || len(mw.RemovedArticles) != 1 || mw.RemovedArticles[0] != "3" {
+		t.Errorf("mw delta = %+v, want dharma changed, arma added and yoga removed", mw)
+	}
+	if !changes["ap90"].Removed || len(changes["pw"].Articles) != 1 {
+		t.Errorf("delta = %+v, want ap90 removed and pw added", delta.Dicts)
+	}
+
+	if err := oldDB.ApplyDelta(delta); err != nil {
+		t.Fatalf("ApplyDelta() error = %v", err)
+	}
+
+	// The changed article keeps its ID and is indexed with its new content
+	results, err := oldDB.Search("duty", ModeReverse, nil)
+	if err != nil || len(results) != 1 || results[0].ArticleID != dharma[0].ArticleID {
+		t.Errorf("reverse search for the changed article = %v, %v; want article %d", results, err, dharma[0].ArticleID)
+	}
+	for _, query := range []struct {
+		word string
+		mode SearchMode
+		want int
+	}{
+		{"law", ModeReverse, 1},
+		{"union", ModeReverse, 0},
+		{"yoga", ModeExact, 0},
+		{"arma", ModeExact, 1},
+		{"dharma", ModeExact, 1}, // pw isn't installed
+		{"religion", ModeReverse, 0},
+	} {
+		results, err := oldDB.Search(query.word, query.mode, nil)
+		if err != nil {
+			t.Fatalf("Search(%s) error = %v", query.word, err)
+		}
+		if len(results) != query.want {
+			t.Errorf("Search(%s) found %d results, want %d", query.word, len(results), query.want)
+		}
+	}
+	// Added articles keep their place in the printed dictionary and get
+	// their ID in the new build
+	if arma, err := oldDB.Search("arma", ModeExact, nil); err != nil || len(arma) != 1 || arma[0].LNumber != 4 {
+		t.Errorf("Search(arma) = %+v, %v; want record number 4", arma, err)
+	} else if arma[0].ArticleID != ArticleID("mw", "4") {
+		t.Errorf("added article ID = %d, want %d", arma[0].ArticleID, ArticleID("mw", "4"))
+	}
+	if codes := dictCodes(t, oldDB); len(codes) != 1 || codes[0] != "mw" {
+		t.Errorf("dicts = %v, want mw", codes)
+	}
+	if err := oldDB.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+
+	// The patched database has the new data of its dictionaries
+	again, err := Diff(oldDB, newDB)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(again.Dicts) != 1 || again.Dicts[0].Code != "pw" {
+		t.Errorf("Diff() after ApplyDelta = %+v, want only pw to add", again.Dicts)
+	}
+}
+
+func TestApplyDeltaToShards(t *testing.T) {
+	dir := t.TempDir()
+	oldDB := createSourceDB(t, filepath.Join(dir, "old.db"), []string{"mw", "ap90"}, []sourceArticle{
+		{"mw", "1", "dharma", "dharma m. law"},
+		{"ap90", "1", "dharma", "dharma m. religion"},
+	})
+	newDB := createSourceDB(t, filepath.Join(dir, "new.db"), []string{"mw", "ap90"}, []sourceArticle{
+		{"mw", "1", "dharma", "dharma m. law, duty"},
+		{"ap90", "1", "dharma", "dharma m. religion, virtue"},
+		{"ap90", "2", "karma", "karma n. act"},
+	})
+	delta, err := Diff(oldDB, newDB)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	// Only mw is installed, from its shard
+	shard := filepath.Join(dir, "mw.shard.db")
+	if err := oldDB.ExportShard("mw", shard); err != nil {
+		t.Fatal(err)
+	}
+	db := createEmptyDB(t)
+	if err := db.InstallShard(shard); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ApplyDelta(delta); err != nil {
+		t.Fatalf("ApplyDelta() error = %v", err)
+	}
+	if codes := dictCodes(t, db); len(codes) != 1 || codes[0] != "mw" {
+		t.Errorf("dicts = %v, want mw only", codes)
+	}
+	if results, _ := db.Search("duty", ModeReverse, nil); len(results) != 1 {
+		t.Errorf("reverse search for the changed mw article found %d results", len(results))
+	}
+	if results, _ := db.Search("karma", ModeExact, nil); len(results) != 0 {
+		t.Errorf("article of ap90, which isn't installed, added: %+v", results)
+	}
+
+	// The ap90 shard of the new build fits the patched database
+	shard = filepath.Join(dir, "ap90.shard.db")
+	if err := newDB.ExportShard("ap90", shard); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InstallShard(shard); err != nil {
+		t.Fatalf("InstallShard() error = %v", err)
+	}
+	if err := db.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+}
+
+func TestDiffWithoutSourceIDs(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+	if _, err := Diff(db, db); !errors.Is(err, ErrNoSourceIDs) {
+		t.Errorf("Diff() error = %v, want ErrNoSourceIDs", err)
+	}
+}
+
+func TestRebuildKeepsArticleIDs(t *testing.T) {
+	dir := t.TempDir()
+	oldDB := createSourceDB(t, filepath.Join(dir, "old.db"), []string{"mw", "ap90"}, []sourceArticle{
+		{"mw", "1", "dharma", "dharma m. law"},
+		{"mw", "2", "karma", "karma n. act"},
+		{"ap90", "1", "dharma", "dharma m. religion"},
+	})
+	// A later build with another dictionary first and new articles before
+	// the same one
+	newDB := createSourceDB(t, filepath.Join(dir, "new.db"), []string{"ap90", "mw"}, []sourceArticle{
+		{"ap90", "2", "yoga", "yoga m. union"},
+		{"ap90", "1", "dharma", "dharma m. religion"},
+		{"mw", "0", "a", "a the first letter"},
+		{"mw", "2", "karma", "karma n. act"},
+		{"mw", "1", "dharma", "dharma m. law"},
+	})
+
+	id := ArticleID("mw", "2")
+	for name, db := range map[string]*DB{"old": oldDB, "new": newDB} {
+		content, err := db.GetArticleContent(id)
+		if err != nil || content != "karma n. act" {
+			t.Errorf("%s build: article %d = %q, %v; want karma", name, id, content, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Databases from before articles had stable IDs (see ArticleID) numbered
+// them in the order they were inserted, so installing newer data moves
+// articles to other IDs. MapArticles finds where, so that user data kept
+// by article ID, e.g. starred articles, can follow them.
+
+// oldArticle is an article to find in newly installed data
+type oldArticle struct {
+	id       int64
+	dictCode string
+	word     string
+	text     string
+}
+
+// MapArticles returns the IDs that articles of the database have in the
+// database or shard at path, for those of ids whose ID differs there. An
+// article is found among the articles of its dictionary with its first
+// headword: the one with the same text, or else the only one. Articles
+// not found are left out.
+func (d *DB) MapArticles(path string, ids []int64) (map[int64]int64, error) {
+	moved := make(map[int64]int64)
+	if len(ids) == 0 {
+		return moved, nil
+	}
+	err := d.withAttached(path, func(tx *sql.Tx) error {
+		if tableExists(tx, "shard", "article_dicts") {
+			if err := loadArticleDicts(tx, "shard"); err != nil {
+				return err
+			}
+		}
+		var articles []oldArticle
+		for _, id := range ids {
+			a, err := readOldArticle(tx, id)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			articles = append(articles, a)
+		}
+		candidates, err := headwordArticles(tx, articles)
+		if err != nil {
+			return err
+		}
+		texts := make(map[int64]string)
+		for _, a := range articles {
+			var matches []int64
+			for _, id := range candidates[a.dictCode+"\x00"+a.word] {
+				text, ok := texts[id]
+				if !ok {
+					if err := tx.QueryRow("SELECT article_text(content) FROM shard.articles WHERE id = ?", id).Scan(&text); err != nil {
+						return err
+					}
+					texts[id] = text
+				}
+				if text == a.text {
+					matches = append(matches, id)
+				}
+			}
+			newID := int64(0)
+			switch all := candidates[a.dictCode+"\x00"+a.word]; {
+			case len(matches) == 1:
+				newID = matches[0]
+			case len(matches) == 0 && len(all) == 1:
+				newID = all[0]
+			}
+			if newID != 0 && newID != a.id {
+				moved[a.id] = newID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// readOldArticle reads an article of the main database with its first
+// headword
+func readOldArticle(tx *sql.Tx, id int64) (oldArticle, error) {
+	a := oldArticle{id: id}
+	err := tx.QueryRow(`
+		SELECT a.dict_code, article_text(a.content),
+			COALESCE((SELECT word_iast FROM main.words WHERE article_id = a.id ORDER BY id LIMIT 1), '')
+		FROM main.articles a WHERE a.id = ?
+	`, id).Scan(&a.dictCode, &a.text, &a.word)
+	return a, err
+}
+
+// headwordArticles returns the articles of the attached database with the
+// headwords of the articles, by dictionary code and headword. The words are
+// read in one pass, as shards have no index to look them up by.
+func headwordArticles(tx *sql.Tx, articles []oldArticle) (map[string][]int64, error) {
+	found := make(map[string][]int64)
+	for _, a := range articles {
+		found[a.dictCode+"\x00"+a.word] = nil
+	}
+	rows, err := tx.Query("SELECT dict_code, word_iast, article_id FROM shard.words")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type match struct {
+		key string
+		id  int64
+	}
+	seen := make(map[match]bool)
+	for rows.Next() {
+		var dictCode, word string
+		var id int64
+		if err := rows.Scan(&dictCode, &word, &id); err != nil {
+			return nil, err
+		}
+		key := dictCode + "\x00" + word
+		if _, ok := found[key]; ok && !seen[match{key, id}] {
+			seen[match{key, id}] = true
+			found[key] = append(found[key], id)
+		}
+	}
+	return found, rows.Err()
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMapArticles(t *testing.T) {
+	dir := t.TempDir()
+	// Articles numbered in insertion order, as before stable IDs
+	oldDB := createTestDBAt(t, filepath.Join(dir, "old.db"))
+	defer oldDB.Close()
+	oldID := func(word, dict string) int64 {
+		t.Helper()
+		results, err := oldDB.Search(word, ModeExact, []string{dict})
+		if err != nil || len(results) != 1 {
+			t.Fatalf("Search(%s, %s) = %v, %v", word, dict, results, err)
+		}
+		return results[0].ArticleID
+	}
+
+	createSourceDB(t, filepath.Join(dir, "new.db"), []string{"mw", "ap90"}, []sourceArticle{
+		{"mw", "1", "dharma", "dharma m. law, duty, virtue, righteousness, the yoga philosophy"},
+		{"mw", "2", "karma", "karma n. act, action, work, deed (corrected)"},
+		{"mw", "3", "yoga", "yoga m. union"},
+		{"mw", "4", "yoga", "yoga m. union, connection, the yoga philosophy and practice"},
+		{"mw", "5", "arma", "arma n. weapon"},
+		{"mw", "6", "arma", "arma n. arm"},
+		{"ap90", "1", "dharma", "dharma m. religion, duty, piety"},
+	})
+
+	ids := []int64{
+		oldID("dharma", "mw"), oldID("karma", "mw"), oldID("yoga", "mw"),
+		oldID("arma", "mw"), oldID("dharma", "ap90"), oldID("dharma", "pw"), 12345,
+	}
+	moved, err := oldDB.MapArticles(filepath.Join(dir, "new.db"), ids)
+	if err != nil {
+		t.Fatalf("MapArticles() error = %v", err)
+	}
+	want := map[int64]int64{
+		// The same text
+		ids[0]: ArticleID("mw", "1"),
+		// The only article with the headword
+		ids[1]: ArticleID("mw", "2"),
+		// The same text among several with the headword
+		ids[2]: ArticleID("mw", "4"),
+		ids[4]: ArticleID("ap90", "1"),
+		// Not found: several with the headword and none with the text, a
+		// dictionary that isn't installed, no article
+	}
+	if len(moved) != len(want) {
+		t.Errorf("MapArticles() = %v, want %v", moved, want)
+	}
+	for id, newID := range want {
+		if moved[id] != newID {
+			t.Errorf("article %d moved to %d, want %d", id, moved[id], newID)
+		}
+	}
+}
@@ -3,9 +3,12 @@ package search
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"strings"
 
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
 	_ "modernc.org/sqlite"
 )
 
@@ -16,11 +19,18 @@ type Result struct {
 	ArticleID int64
 	Word      string
 	Content   string
+	Homonym   int     // Homonym number of the headword, 0 if none
+	LNumber   float64 // Cologne record number, 0 if unknown
+	Page      string  // Printed page and column ("253,1"), empty if unknown
 }
 
 // DB wraps the SQLite database with FTS5 indexes.
 type DB struct {
 	db *sql.DB
+
+	// hasSource reports whether articles store their homonym number,
+	// record number and page-column, which older databases don't
+	hasSource bool
 }
 
 // Open opens or creates a dictionary database.
@@ -42,7 +52,16 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("set mmap: %w", err)
 	}
 
-	return &DB{db: db}, nil
+	d := &DB{db: db}
+	d.hasSource = d.hasColumn("articles", "homonym")
+	// Make compressed articles readable
+	if d.hasTable("article_dicts") {
+		if err := loadArticleDicts(db, "main"); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return d, nil
 }
 
 // OpenForBulkInsert opens a database optimized for bulk inserts.
@@ -90,11 +109,16 @@ func (d *DB) InitSchemaForBulkInsert() error {
 		favorite INTEGER DEFAULT 0
 	);
 
-	-- Articles (main content)
+	-- Articles (main content) with their place in the printed dictionary
+	-- and their ID in the source data, which is stable across builds
 	CREATE TABLE IF NOT EXISTS articles (
 		id INTEGER PRIMARY KEY,
 		dict_code TEXT NOT NULL,
-		content TEXT NOT NULL
+		content TEXT NOT NULL,
+		homonym INTEGER NOT NULL DEFAULT 0,
+		lnum REAL NOT NULL DEFAULT 0,
+		page TEXT NOT NULL DEFAULT '',
+		source_id TEXT NOT NULL DEFAULT ''
 	);
 
 	-- Word index for fast headword lookup
@@ -105,10 +129,34 @@ func (d *DB) InitSchemaForBulkInsert() error {
 		article_id INTEGER NOT NULL,
 		dict_code TEXT NOT NULL
 	);
+
+	-- Literary citations found in articles ("RV. x, 129, 1" is RV. 10 129.1)
+	CREATE TABLE IF NOT EXISTS citations (
+		work TEXT NOT NULL COLLATE NOCASE,
+		book INTEGER NOT NULL,
+		verse TEXT NOT NULL,
+		article_id INTEGER NOT NULL
+	);
+
+	-- Verbal roots: the root's own entries and the words derived from it
+	CREATE TABLE IF NOT EXISTS roots (
+		root TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		article_id INTEGER NOT NULL
+	);
+
+	-- Stems of secondary derivatives, turned into roots by ResolveRoots
+	CREATE TABLE IF NOT EXISTS stems (
+		stem TEXT NOT NULL,
+		article_id INTEGER NOT NULL
+	);
 	`
 
-	_, err := d.db.Exec(schema)
-	return err
+	if _, err := d.db.Exec(schema); err != nil {
+		return err
+	}
+	d.hasSource = true
+	return nil
 }
 
 // RebuildFTS creates FTS tables and populates them from existing data.
@@ -136,33 +184,61 @@ func (d *DB) RebuildFTS() error {
 		SELECT id, word_iast, word_deva FROM words;
 
 	INSERT INTO articles_fts(rowid, content)
-		SELECT id, content FROM articles;
+		SELECT id, article_text(content) FROM articles;
 
 	-- Create indexes
 	CREATE INDEX IF NOT EXISTS idx_words_article ON words(article_id);
 	CREATE INDEX IF NOT EXISTS idx_words_dict ON words(dict_code);
 	CREATE INDEX IF NOT EXISTS idx_articles_dict ON articles(dict_code);
+	CREATE INDEX IF NOT EXISTS idx_articles_source ON articles(dict_code, source_id);
+	CREATE INDEX IF NOT EXISTS idx_citations_work ON citations(work, book, verse);
+	CREATE INDEX IF NOT EXISTS idx_roots_root ON roots(root);
+	CREATE INDEX IF NOT EXISTS idx_roots_article ON roots(article_id);
+	` + ftsTriggers
+
+	_, err := d.db.Exec(fts)
+	return err
+}
 
-	-- Create triggers for future inserts
+// ftsTriggers keep the FTS indexes up to date with later changes, with
+// the text of compressed articles
+const ftsTriggers = `
 	CREATE TRIGGER IF NOT EXISTS words_ai AFTER INSERT ON words BEGIN
 		INSERT INTO words_fts(rowid, word_iast, word_deva) VALUES (new.id, new.word_iast, new.word_deva);
 	END;
 
+	CREATE TRIGGER IF NOT EXISTS words_ad AFTER DELETE ON words BEGIN
+		INSERT INTO words_fts(words_fts, rowid, word_iast, word_deva) VALUES ('delete', old.id, old.word_iast, old.word_deva);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS words_au AFTER UPDATE ON words BEGIN
+		INSERT INTO words_fts(words_fts, rowid, word_iast, word_deva) VALUES ('delete', old.id, old.word_iast, old.word_deva);
+		INSERT INTO words_fts(rowid, word_iast, word_deva) VALUES (new.id, new.word_iast, new.word_deva);
+	END;
+
 	CREATE TRIGGER IF NOT EXISTS articles_ai AFTER INSERT ON articles BEGIN
-		INSERT INTO articles_fts(rowid, content) VALUES (new.id, new.content);
+		INSERT INTO articles_fts(rowid, content) VALUES (new.id, article_text(new.content));
 	END;
-	`
 
-	_, err := d.db.Exec(fts)
-	return err
-}
+	CREATE TRIGGER IF NOT EXISTS articles_ad AFTER DELETE ON articles BEGIN
+		INSERT INTO articles_fts(articles_fts, rowid, content) VALUES ('delete', old.id, article_text(old.content));
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS articles_au AFTER UPDATE OF content ON articles BEGIN
+		INSERT INTO articles_fts(articles_fts, rowid, content) VALUES ('delete', old.id, article_text(old.content));
+		INSERT INTO articles_fts(rowid, content) VALUES (new.id, article_text(new.content));
+	END;
+`
 
 // BulkInserter provides fast bulk insert operations.
 type BulkInserter struct {
-	tx          *sql.Tx
-	stmtArticle *sql.Stmt
-	stmtWord    *sql.Stmt
-	stmtDict    *sql.Stmt
+	tx           *sql.Tx
+	stmtArticle  *sql.Stmt
+	stmtWord     *sql.Stmt
+	stmtDict     *sql.Stmt
+	stmtCitation *sql.Stmt
+	stmtRoot     *sql.Stmt
+	stmtStem     *sql.Stmt
 }
 
 // NewBulkInserter creates a new bulk inserter with prepared statements.
@@ -172,7 +248,7 @@ func (d *DB) NewBulkInserter() (*BulkInserter, error) {
 		return nil, err
 	}
 
-	stmtArticle, err := tx.Prepare("INSERT INTO articles (dict_code, content) VALUES (?, ?)")
+	stmtArticle, err := tx.Prepare("INSERT INTO articles (id, dict_code, content, homonym, lnum, page, source_id) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING")
 	if err != nil {
 		tx.Rollback()
 		return nil, err
@@ -190,11 +266,32 @@ func (d *DB) NewBulkInserter() (*BulkInserter, error) {
 		return nil, err
 	}
 
+	stmtCitation, err := tx.Prepare("INSERT INTO citations (work, book, verse, article_id) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	stmtRoot, err := tx.Prepare("INSERT INTO roots (root, kind, article_id) VALUES (?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	stmtStem, err := tx.Prepare("INSERT INTO stems (stem, article_id) VALUES (?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	return &BulkInserter{
-		tx:          tx,
-		stmtArticle: stmtArticle,
-		stmtWord:    stmtWord,
-		stmtDict:    stmtDict,
+		tx:           tx,
+		stmtArticle:  stmtArticle,
+		stmtWord:     stmtWord,
+		stmtDict:     stmtDict,
+		stmtCitation: stmtCitation,
+		stmtRoot:     stmtRoot,
+		stmtStem:     stmtStem,
 	}, nil
 }
 
@@ -208,13 +305,51 @@ func (b *BulkInserter) InsertDict(code, name, fromLang, toLang string, favorite
 	return err
 }
 
-// InsertArticle inserts an article and returns its ID.
+// InsertArticle inserts an article and returns its ID. The homonym number,
+// record number and page-column are read from the content.
 func (b *BulkInserter) InsertArticle(dictCode, content string) (int64, error) {
-	result, err := b.stmtArticle.Exec(dictCode, content)
-	if err != nil {
-		return 0, err
+	return b.InsertSourceArticle(dictCode, "", content, article.SourceOf(content))
+}
+
+// InsertSourceArticle inserts an article with its ID in the source data,
+// which keys it in delta updates, and where it stands in the printed
+// dictionary, and returns its ID.
+//
+// The ID is ArticleID(dictCode, sourceID), or the next free one if that is
+// taken, so inserting the articles in the same order gives every build the
+// same IDs. Articles without a source ID are numbered in order.
+func (b *BulkInserter) InsertSourceArticle(dictCode, sourceID, content string, src article.Source) (int64, error) {
+	var id any
+	if sourceID != "" {
+		id = ArticleID(dictCode, sourceID)
+	}
+	for {
+		result, err := b.stmtArticle.Exec(id, dictCode, content, src.Homonym, src.LNumber, src.Page, sourceID)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			return result.LastInsertId()
+		}
+		// Another article's ID has the same hash
+		id = id.(int64) + 1
 	}
-	return result.LastInsertId()
+}
+
+// ArticleID returns the ID of a dictionary's article with a source ID. It
+// depends on nothing else, so the article keeps it, and with it its stars,
+// notes and review progress, in every build of the data. IDs fit in 53 bits
+// for clients that read JSON numbers as doubles.
+func ArticleID(dictCode, sourceID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(dictCode))
+	h.Write([]byte{0})
+	h.Write([]byte(sourceID))
+	return int64(h.Sum64()>>11) | 1<<52
 }
 
 // InsertWord inserts a word record.
@@ -223,11 +358,34 @@ func (b *BulkInserter) InsertWord(wordIAST, wordDeva string, articleID int64, di
 	return err
 }
 
+// InsertCitation records that an article cites a passage of a work.
+func (b *BulkInserter) InsertCitation(work string, book int, verse string, articleID int64) error {
+	_, err := b.stmtCitation.Exec(work, book, verse, articleID)
+	return err
+}
+
+// InsertRoot records that an article is the entry of a verbal root or
+// of a word derived from it. kind is article.RootEntry or article.Primary.
+func (b *BulkInserter) InsertRoot(root string, kind article.DerivationKind, articleID int64) error {
+	_, err := b.stmtRoot.Exec(root, string(kind), articleID)
+	return err
+}
+
+// InsertStem records that an article's word is a secondary derivative of
+// a stem. Stems are traced to their roots by ResolveRoots.
+func (b *BulkInserter) InsertStem(stem string, articleID int64) error {
+	_, err := b.stmtStem.Exec(stem, articleID)
+	return err
+}
+
 // Commit commits the transaction.
 func (b *BulkInserter) Commit() error {
 	b.stmtArticle.Close()
 	b.stmtWord.Close()
 	b.stmtDict.Close()
+	b.stmtCitation.Close()
+	b.stmtRoot.Close()
+	b.stmtStem.Close()
 	return b.tx.Commit()
 }
 
@@ -243,8 +401,18 @@ const (
 	ModeFuzzy
 	// ModeReverse searches within article content.
 	ModeReverse
+	// ModeCitation finds articles citing a passage, e.g. "RV. x, 129".
+	ModeCitation
 )
 
+// ErrNoCitations is returned by citation searches on databases built
+// before citations were indexed.
+var ErrNoCitations = errors.New("this dictionary database has no citation index; download the latest database to search by citation")
+
+// ErrNoRoots is returned by root lookups on databases built before roots
+// were indexed.
+var ErrNoRoots = errors.New("this dictionary database has no root index; download the latest database to look up roots")
+
 // buildDictFilter returns a SQL filter clause and appends dict codes to args.
 // column should be "w.dict_code" or "a.dict_code" depending on the query context.
 // Returns empty string if no dict codes provided.
@@ -274,6 +442,7 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 	// Build dict filter
 	dictFilter := ""
 	args := []interface{}{}
+	source := d.sourceColumns()
 
 	switch mode {
 	case ModeExact:
@@ -284,12 +453,12 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 		args = append([]interface{}{lowerQuery, lowerQuery}, args...)
 
 		rows, err = d.db.Query(`
-			SELECT d.code, d.name, a.id, w.word_iast, ''
+			SELECT d.code, d.name, a.id, w.word_iast, '', `+source+`
 			FROM words w
 			JOIN articles a ON a.id = w.article_id
 			JOIN dicts d ON d.code = w.dict_code
 			WHERE (LOWER(w.word_iast) = ? OR LOWER(w.word_deva) = ?)`+dictFilter+`
-			ORDER BY d.favorite DESC, LENGTH(w.word_iast), d.code, w.word_iast
+			ORDER BY d.favorite DESC, LENGTH(w.word_iast), d.code, w.word_iast, homonym, lnum
 			LIMIT 1000
 		`, args...)
 
@@ -300,12 +469,12 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 		args = append([]interface{}{likeQuery, likeQuery}, args...)
 
 		rows, err = d.db.Query(`
-			SELECT d.code, d.name, a.id, w.word_iast, ''
+			SELECT d.code, d.name, a.id, w.word_iast, '', `+source+`
 			FROM words w
 			JOIN articles a ON a.id = w.article_id
 			JOIN dicts d ON d.code = w.dict_code
 			WHERE (LOWER(w.word_iast) LIKE ? OR LOWER(w.word_deva) LIKE ?)`+dictFilter+`
-			ORDER BY d.favorite DESC, LENGTH(w.word_iast), d.code, w.word_iast
+			ORDER BY d.favorite DESC, LENGTH(w.word_iast), d.code, w.word_iast, homonym, lnum
 			LIMIT 1000
 		`, args...)
 
@@ -316,12 +485,12 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 		args = append([]interface{}{likeQuery, likeQuery}, args...)
 
 		rows, err = d.db.Query(`
-			SELECT d.code, d.name, a.id, w.word_iast, ''
+			SELECT d.code, d.name, a.id, w.word_iast, '', `+source+`
 			FROM words w
 			JOIN articles a ON a.id = w.article_id
 			JOIN dicts d ON d.code = w.dict_code
 			WHERE (LOWER(w.word_iast) LIKE ? OR LOWER(w.word_deva) LIKE ?)`+dictFilter+`
-			ORDER BY d.favorite DESC, LENGTH(w.word_iast), d.code, w.word_iast
+			ORDER BY d.favorite DESC, LENGTH(w.word_iast), d.code, w.word_iast, homonym, lnum
 			LIMIT 1000
 		`, args...)
 
@@ -334,10 +503,10 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 
 		rows, err = d.db.Query(`
 			SELECT d.code, d.name, a.id,
-				CASE WHEN INSTR(a.content, ' ') > 0
-					THEN SUBSTR(a.content, 1, INSTR(a.content, ' ') - 1)
-					ELSE SUBSTR(a.content, 1, 40)
-				END, ''
+				CASE WHEN INSTR(article_text(a.content), ' ') > 0
+					THEN SUBSTR(article_text(a.content), 1, INSTR(article_text(a.content), ' ') - 1)
+					ELSE SUBSTR(article_text(a.content), 1, 40)
+				END, '', `+source+`
 			FROM articles_fts af
 			JOIN articles a ON a.id = af.rowid
 			JOIN dicts d ON d.code = a.dict_code
@@ -345,6 +514,11 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 			ORDER BY d.favorite DESC, d.code
 			LIMIT 1000
 		`, args...)
+
+	case ModeCitation:
+		// "RV. x, 129" or "RV 10.129": the work, then the locus
+		work, locus, _ := strings.Cut(query, " ")
+		return d.searchCitation(work, locus, dictCodes)
 	}
 
 	if err != nil {
@@ -354,7 +528,7 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 
 	for rows.Next() {
 		var r Result
-		if err := rows.Scan(&r.DictCode, &r.DictName, &r.ArticleID, &r.Word, &r.Content); err != nil {
+		if err := rows.Scan(&r.DictCode, &r.DictName, &r.ArticleID, &r.Word, &r.Content, &r.Homonym, &r.LNumber, &r.Page); err != nil {
 			return nil, fmt.Errorf("scan result: %w", err)
 		}
 		results = append(results, r)
@@ -363,6 +537,16 @@ func (d *DB) Search(query string, mode SearchMode, dictCodes []string) ([]Result
 	return results, rows.Err()
 }
 
+// sourceColumns selects an article's homonym number, record number and
+// page-column as homonym, lnum and page, with placeholders on databases
+// built before they were stored.
+func (d *DB) sourceColumns() string {
+	if d.hasSource {
+		return "a.homonym AS homonym, a.lnum AS lnum, a.page AS page"
+	}
+	return "0 AS homonym, 0 AS lnum, '' AS page"
+}
+
 // escapeFTS escapes special FTS5 characters in a query.
 func escapeFTS(s string) string {
 	// Escape double quotes by doubling them
@@ -415,7 +599,7 @@ func (d *DB) Optimize() error {
 // GetArticle retrieves an article by its ID.
 func (d *DB) GetArticle(articleID int64) ([]Result, error) {
 	rows, err := d.db.Query(`
-		SELECT d.code, d.name, a.id, COALESCE(w.word_iast, ''), a.content
+		SELECT d.code, d.name, a.id, COALESCE(w.word_iast, ''), a.content, `+d.sourceColumns()+`
 		FROM articles a
 		JOIN dicts d ON d.code = a.dict_code
 		LEFT JOIN words w ON w.article_id = a.id
@@ -430,7 +614,11 @@ func (d *DB) GetArticle(articleID int64) ([]Result, error) {
 	var results []Result
 	for rows.Next() {
 		var r Result
-		if err := rows.Scan(&r.DictCode, &r.DictName, &r.ArticleID, &r.Word, &r.Content); err != nil {
+		var content []byte
+		if err := rows.Scan(&r.DictCode, &r.DictName, &r.ArticleID, &r.Word, &content, &r.Homonym, &r.LNumber, &r.Page); err != nil {
+			return nil, err
+		}
+		if r.Content, err = articleText(content); err != nil {
 			return nil, err
 		}
 		results = append(results, r)
@@ -438,17 +626,19 @@ func (d *DB) GetArticle(articleID int64) ([]Result, error) {
 	return results, rows.Err()
 }
 
-// GetArticleContent retrieves content for a single article by ID.
+// GetArticleContent retrieves content for a single article by ID,
+// decompressed if it is stored compressed.
 func (d *DB) GetArticleContent(articleID int64) (string, error) {
-	var content string
+	var content []byte
 	err := d.db.QueryRow(`SELECT content FROM articles WHERE id = ?`, articleID).Scan(&content)
 	if err != nil {
 		return "", err
 	}
-	return content, nil
+	return articleText(content)
 }
 
-// GetArticleContents retrieves content for multiple articles by IDs (batch fetch).
+// GetArticleContents retrieves content for multiple articles by IDs (batch
+// fetch), decompressed like GetArticleContent.
 func (d *DB) GetArticleContents(articleIDs []int64) (map[int64]string, error) {
 	if len(articleIDs) == 0 {
 		return make(map[int64]string), nil
@@ -472,11 +662,212 @@ func (d *DB) GetArticleContents(articleIDs []int64) (map[int64]string, error) {
 	result := make(map[int64]string)
 	for rows.Next() {
 		var id int64
-		var content string
+		var content []byte
 		if err := rows.Scan(&id, &content); err != nil {
 			return nil, err
 		}
-		result[id] = content
+		if result[id], err = articleText(content); err != nil {
+			return nil, err
+		}
 	}
 	return result, rows.Err()
 }
+
+// hasTable reports whether the database has the named table.
+func (d *DB) hasTable(name string) bool {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	return err == nil && n > 0
+}
+
+// hasColumn reports whether a table of the database has the named column.
+func (d *DB) hasColumn(table, column string) bool {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	return err == nil && n > 0
+}
+
+// HasSourceIDs reports whether the articles keep their IDs in the source
+// data, which delta updates need.
+func (d *DB) HasSourceIDs() bool {
+	return d.hasColumn("articles", "source_id")
+}
+
+// HasCitations reports whether the database has a citation index.
+func (d *DB) HasCitations() bool {
+	return d.hasTable("citations")
+}
+
+// SearchByCitation finds articles citing a passage of a work.
+// work is an abbreviated title as used in the dictionaries ("RV.", "MBh.",
+// "Bg"); locus narrows the passage ("x, 129", "10.129.1", "iii") and may be
+// empty to find all citations of the work. A locus matches the passage and
+// everything below it, so "x, 129" also finds "x, 129, 1".
+func (d *DB) SearchByCitation(work, locus string) ([]Result, error) {
+	return d.searchCitation(work, locus, nil)
+}
+
+func (d *DB) searchCitation(work, locus string, dictCodes []string) ([]Result, error) {
+	if strings.TrimSpace(work) == "" {
+		return nil, nil
+	}
+	if !d.HasCitations() {
+		return nil, ErrNoCitations
+	}
+
+	works := article.WorkVariants(work)
+	args := make([]interface{}, 0, len(works)+4)
+	for _, w := range works {
+		args = append(args, w)
+	}
+	where := "c.work IN (" + strings.TrimSuffix(strings.Repeat("?,", len(works)), ",") + ")"
+
+	if strings.TrimSpace(locus) != "" {
+		book, verse, ok := article.ParseLocus(locus)
+		if !ok {
+			return nil, fmt.Errorf("invalid locus %q: use e.g. x, 129 or 10.129", locus)
+		}
+		where += " AND c.book = ?"
+		args = append(args, book)
+		if verse != "" {
+			where += " AND (c.verse = ? OR c.verse LIKE ?)"
+			args = append(args, verse, verse+".%")
+		}
+	}
+	where += buildDictFilter("a.dict_code", dictCodes, &args)
+
+	rows, err := d.db.Query(`
+		SELECT d.code, d.name, a.id,
+			COALESCE((SELECT w.word_iast FROM words w WHERE w.article_id = a.id LIMIT 1), ''), ''
+		FROM citations c
+		JOIN articles a ON a.id = c.article_id
+		JOIN dicts d ON d.code = a.dict_code
+		WHERE `+where+`
+		GROUP BY a.id
+		ORDER BY d.favorite DESC, d.code, MIN(c.book), MIN(CAST(c.verse AS INTEGER)), MIN(c.verse)
+		LIMIT 1000
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("citation query: %w", err)
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var r Result
+		if err := rows.Scan(&r.DictCode, &r.DictName, &r.ArticleID, &r.Word, &r.Content); err != nil {
+			return nil, fmt.Errorf("scan result: %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Derivative is an article of a word belonging to a root's family.
+type Derivative struct {
+	Result
+	Root string
+	Kind article.DerivationKind // RootEntry, Primary or Secondary
+}
+
+// ResolveRoots traces secondary derivatives to roots through the stems
+// they are formed from: a word "fr. kāraka" joins the family of the root
+// of kāraka. Call this after bulk insert is complete; the stems table is
+// dropped afterwards.
+func (d *DB) ResolveRoots() error {
+	_, err := d.db.Exec(`
+	INSERT INTO roots (root, kind, article_id)
+		SELECT DISTINCT r.root, 'secondary', s.article_id
+		FROM stems s
+		JOIN words w ON w.word_iast = s.stem
+		JOIN roots r ON r.article_id = w.article_id AND r.kind = 'primary'
+		WHERE NOT EXISTS (
+			SELECT 1 FROM roots x WHERE x.article_id = s.article_id AND x.root = r.root
+		);
+
+	DROP TABLE stems;
+	`)
+	return err
+}
+
+// HasRoots reports whether the database has a root index.
+func (d *DB) HasRoots() bool {
+	return d.hasTable("roots")
+}
+
+// RootOf returns the verbal roots a word belongs to: the roots it is
+// derived from, or the word itself if it is a root.
+func (d *DB) RootOf(word string) ([]string, error) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return nil, nil
+	}
+	if !d.HasRoots() {
+		return nil, ErrNoRoots
+	}
+
+	rows, err := d.db.Query(`
+		SELECT r.root
+		FROM words w
+		JOIN roots r ON r.article_id = w.article_id
+		WHERE LOWER(w.word_iast) = ? OR LOWER(w.word_deva) = ?
+		GROUP BY r.root
+		ORDER BY MIN(CASE r.kind WHEN 'root' THEN 0 WHEN 'primary' THEN 1 ELSE 2 END), COUNT(*) DESC, r.root
+	`, word, word)
+	if err != nil {
+		return nil, fmt.Errorf("root query: %w", err)
+	}
+	defer rows.Close()
+
+	var roots []string
+	for rows.Next() {
+		var root string
+		if err := rows.Scan(&root); err != nil {
+			return nil, fmt.Errorf("scan root: %w", err)
+		}
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
+
+// WordsFromRoot returns the family of a verbal root: its own entries,
+// then its primary and secondary derivatives. The root may be written with
+// the root sign and homonym number ("√ 1. kṛ").
+func (d *DB) WordsFromRoot(root string) ([]Derivative, error) {
+	root = article.NormalizeRoot(root)
+	if root == "" {
+		return nil, nil
+	}
+	if !d.HasRoots() {
+		return nil, ErrNoRoots
+	}
+
+	rows, err := d.db.Query(`
+		SELECT d.code, d.name, a.id, w.word_iast, r.root, r.kind
+		FROM roots r
+		JOIN articles a ON a.id = r.article_id
+		JOIN dicts d ON d.code = a.dict_code
+		JOIN words w ON w.article_id = a.id
+		WHERE r.root = ?
+		GROUP BY a.id, w.word_iast
+		ORDER BY CASE r.kind WHEN 'root' THEN 0 WHEN 'primary' THEN 1 ELSE 2 END,
+			LENGTH(w.word_iast), w.word_iast, d.favorite DESC, d.code
+		LIMIT 5000
+	`, root)
+	if err != nil {
+		return nil, fmt.Errorf("root family query: %w", err)
+	}
+	defer rows.Close()
+
+	var family []Derivative
+	for rows.Next() {
+		var dv Derivative
+		var kind string
+		if err := rows.Scan(&dv.DictCode, &dv.DictName, &dv.ArticleID, &dv.Word, &dv.Root, &kind); err != nil {
+			return nil, fmt.Errorf("scan derivative: %w", err)
+		}
+		dv.Kind = article.DerivationKind(kind)
+		family = append(family, dv)
+	}
+	return family, rows.Err()
+}
@@ -1,14 +1,27 @@
 package search
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
 )
 
 // createTestDB creates an in-memory database with sample dictionary data.
 func createTestDB(t *testing.T) *DB {
 	t.Helper()
+	return createTestDBAt(t, ":memory:")
+}
 
-	db, err := Open(":memory:")
+// createTestDBAt creates a database with sample dictionary data at path.
+func createTestDBAt(t *testing.T, path string) *DB {
+	t.Helper()
+
+	db, err := Open(path)
 	if err != nil {
 		t.Fatalf("Open() error = %v", err)
 	}
@@ -800,3 +813,331 @@ func TestSearchSpecialCharacters(t *testing.T) {
 		t.Errorf("Search(राम) got %d results, want 1", len(results))
 	}
 }
+
+// createCitationDB creates a database with citations indexed from article content.
+func createCitationDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InitSchemaForBulkInsert(); err != nil {
+		t.Fatalf("InitSchemaForBulkInsert() error = %v", err)
+	}
+
+	bi, err := db.NewBulkInserter()
+	if err != nil {
+		t.Fatalf("NewBulkInserter() error = %v", err)
+	}
+	bi.InsertDict("mw", "Monier-Williams", "sa", "en", true)
+	bi.InsertDict("ap90", "Apte", "sa", "en", true)
+
+	testData := []struct {
+		dictCode, word, content string
+	}{
+		{"mw", "sat", "sat mfn. being, RV. x, 129, 1; AV."},
+		{"mw", "asat", "asat mfn. not being, RV. x, 129, 1; x, 72, 2"},
+		{"mw", "karman", "karman n. act, Bhag. ii, 47"},
+		{"ap90", "karman", "karman n. action, Bg. 2. 47"},
+		{"mw", "agni", "agni m. fire, RV. i, 1, 1"},
+		{"mw", "ap", "ap f. water, RV. x, 9, 1"},
+		{"mw", "ṛta", "ṛta n. order, RV. x, 10, 1"},
+	}
+	for _, td := range testData {
+		articleID, err := bi.InsertArticle(td.dictCode, td.content)
+		if err != nil {
+			t.Fatalf("InsertArticle() error = %v", err)
+		}
+		bi.InsertWord(td.word, "", articleID, td.dictCode)
+		for _, c := range article.Citations(td.content, td.dictCode) {
+			if err := bi.InsertCitation(c.Work, c.Book, c.Verse, articleID); err != nil {
+				t.Fatalf("InsertCitation() error = %v", err)
+			}
+		}
+	}
+	if err := bi.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := db.RebuildFTS(); err != nil {
+		t.Fatalf("RebuildFTS() error = %v", err)
+	}
+	return db
+}
+
+func TestSearchByCitation(t *testing.T) {
+	db := createCitationDB(t)
+
+	tests := []struct {
+		work, locus string
+		want        []string
+	}{
+		{"RV.", "x, 129, 1", []string{"asat", "sat"}},
+		{"RV", "10.129", []string{"asat", "sat"}},
+		{"rv.", "x", []string{"ap", "asat", "sat", "ṛta"}},
+		{"RV.", "x, 72", []string{"asat"}},
+		{"RV.", "", []string{"agni", "ap", "asat", "sat", "ṛta"}},
+		{"RV.", "x, 12", nil}, // 12 must not match 129
+		{"Bhag.", "ii, 47", []string{"karman", "karman"}},
+		{"Bg", "2.47", []string{"karman", "karman"}},
+		{"MBh.", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.work+" "+tt.locus, func(t *testing.T) {
+			results, err := db.SearchByCitation(tt.work, tt.locus)
+			if err != nil {
+				t.Fatalf("SearchByCitation() error = %v", err)
+			}
+			var words []string
+			for _, r := range results {
+				words = append(words, r.Word)
+			}
+			sort.Strings(words)
+			if strings.Join(words, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SearchByCitation(%q, %q) = %v, want %v", tt.work, tt.locus, words, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchByCitationOrder(t *testing.T) {
+	db := createCitationDB(t)
+
+	// Verses are ordered by number, not as text ("9" before "10")
+	results, err := db.SearchByCitation("RV.", "x")
+	if err != nil {
+		t.Fatalf("SearchByCitation() error = %v", err)
+	}
+	var words []string
+	for _, r := range results {
+		words = append(words, r.Word)
+	}
+	if got, want := strings.Join(words, ","), "ap,ṛta,asat,sat"; got != want {
+		t.Errorf("SearchByCitation() order = %s, want %s", got, want)
+	}
+}
+
+func TestSearchModeCitation(t *testing.T) {
+	db := createCitationDB(t)
+
+	results, err := db.Search("RV. x, 129", ModeCitation, []string{"mw"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Search(ModeCitation) got %d results, want 2", len(results))
+	}
+
+	if _, err := db.Search("RV. x, abc", ModeCitation, nil); err == nil {
+		t.Error("Search() with invalid locus should fail")
+	}
+}
+
+func TestSearchByCitationOldDatabase(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+	if _, err := db.db.Exec("DROP TABLE citations"); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.HasCitations() {
+		t.Error("HasCitations() = true after dropping the table")
+	}
+	if _, err := db.SearchByCitation("RV.", "x"); !errors.Is(err, ErrNoCitations) {
+		t.Errorf("SearchByCitation() error = %v, want ErrNoCitations", err)
+	}
+}
+
+// createRootDB creates a database with root families indexed as the indexer does.
+func createRootDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InitSchemaForBulkInsert(); err != nil {
+		t.Fatalf("InitSchemaForBulkInsert() error = %v", err)
+	}
+
+	bi, err := db.NewBulkInserter()
+	if err != nil {
+		t.Fatalf("NewBulkInserter() error = %v", err)
+	}
+	bi.InsertDict("mw", "Monier-Williams", "sa", "en", true)
+
+	testData := []struct {
+		word, content string
+	}{
+		{"kṛ", "kṛ 1. cl. 8. P. Ā. karoti, to do"},
+		{"kāraka", "kāraka mfn. (fr. √ 1. kṛ) making"},
+		{"kārakatva", "kārakatva n. (fr. kāraka) agency"},
+		{"karman", "karman n. (fr. √ kṛ) act"},
+		{"gati", "gati f. (fr. √gam) going"},
+		{"agni", "agni m. fire"},
+	}
+	for _, td := range testData {
+		articleID, err := bi.InsertArticle("mw", td.content)
+		if err != nil {
+			t.Fatalf("InsertArticle() error = %v", err)
+		}
+		bi.InsertWord(td.word, "", articleID, "mw")
+		if article.IsRootEntry(td.content) {
+			bi.InsertRoot(td.word, article.RootEntry, articleID)
+		}
+		for _, d := range article.Derivations(td.content) {
+			if d.Kind == article.Primary {
+				bi.InsertRoot(d.Source, d.Kind, articleID)
+			} else {
+				bi.InsertStem(d.Source, articleID)
+			}
+		}
+	}
+	if err := bi.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := db.ResolveRoots(); err != nil {
+		t.Fatalf("ResolveRoots() error = %v", err)
+	}
+	if err := db.RebuildFTS(); err != nil {
+		t.Fatalf("RebuildFTS() error = %v", err)
+	}
+	return db
+}
+
+func TestWordsFromRoot(t *testing.T) {
+	db := createRootDB(t)
+
+	family, err := db.WordsFromRoot("√ 1. kṛ")
+	if err != nil {
+		t.Fatalf("WordsFromRoot() error = %v", err)
+	}
+	var got []string
+	for _, d := range family {
+		got = append(got, d.Word+":"+string(d.Kind))
+	}
+	want := "kṛ:root,karman:primary,kāraka:primary,kārakatva:secondary"
+	if strings.Join(got, ",") != want {
+		t.Errorf("WordsFromRoot(kṛ) = %v, want %s", got, want)
+	}
+
+	family, err = db.WordsFromRoot("sthā")
+	if err != nil || len(family) != 0 {
+		t.Errorf("WordsFromRoot(sthā) = %v, %v, want nothing", family, err)
+	}
+}
+
+func TestRootOf(t *testing.T) {
+	db := createRootDB(t)
+
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"kāraka", []string{"kṛ"}},
+		{"kārakatva", []string{"kṛ"}},
+		{"KṚ", []string{"kṛ"}},
+		{"gati", []string{"gam"}},
+		{"agni", nil},
+	}
+	for _, tt := range tests {
+		got, err := db.RootOf(tt.word)
+		if err != nil {
+			t.Fatalf("RootOf(%q) error = %v", tt.word, err)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("RootOf(%q) = %v, want %v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestRootsOldDatabase(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+	if _, err := db.db.Exec("DROP TABLE roots"); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.HasRoots() {
+		t.Error("HasRoots() = true after dropping the table")
+	}
+	if _, err := db.RootOf("kṛ"); !errors.Is(err, ErrNoRoots) {
+		t.Errorf("RootOf() error = %v, want ErrNoRoots", err)
+	}
+	if _, err := db.WordsFromRoot("kṛ"); !errors.Is(err, ErrNoRoots) {
+		t.Errorf("WordsFromRoot() error = %v, want ErrNoRoots", err)
+	}
+}
+
+func TestSearchHomonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "homonyms.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { db.Close() }()
+	if err := db.InitSchemaForBulkInsert(); err != nil {
+		t.Fatalf("InitSchemaForBulkInsert() error = %v", err)
+	}
+
+	bi, err := db.NewBulkInserter()
+	if err != nil {
+		t.Fatalf("NewBulkInserter() error = %v", err)
+	}
+	bi.InsertDict("mw", "Monier-Williams", "sa", "en", true)
+	// Inserted out of order: the search must sort by homonym, then record number
+	for _, content := range []string{
+		"<b>2. kara</b> m. a ray of light [L=52100] <pc>254,1</pc>",
+		"<b>1. kara</b> mfn. making, causing [L=52036.1] <pc>253,1</pc>",
+		"<b>1. kara</b> mfn. doing [L=52036] <pc>253,1</pc>",
+	} {
+		id, err := bi.InsertArticle("mw", content)
+		if err != nil {
+			t.Fatalf("InsertArticle() error = %v", err)
+		}
+		bi.InsertWord("kara", "", id, "mw")
+	}
+	if err := bi.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := db.RebuildFTS(); err != nil {
+		t.Fatalf("RebuildFTS() error = %v", err)
+	}
+
+	results, err := db.Search("kara", ModeExact, nil)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, fmt.Sprintf("%d/%g/%s", r.Homonym, r.LNumber, r.Page))
+	}
+	want := "1/52036/253,1 1/52036.1/253,1 2/52100/254,1"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Search(kara) = %v, want %s", got, want)
+	}
+
+	// Databases built before the columns existed still search
+	for _, col := range []string{"homonym", "lnum", "page"} {
+		if _, err := db.db.Exec("ALTER TABLE articles DROP COLUMN " + col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	if db, err = Open(path); err != nil {
+		t.Fatalf("Open() old database error = %v", err)
+	}
+	results, err = db.Search("kara", ModeExact, nil)
+	if err != nil {
+		t.Fatalf("Search() on old database error = %v", err)
+	}
+	if len(results) != 3 || results[0].Homonym != 0 {
+		t.Errorf("Search() on old database = %+v", results)
+	}
+	if _, err := db.GetArticle(results[0].ArticleID); err != nil {
+		t.Errorf("GetArticle() on old database error = %v", err)
+	}
+}
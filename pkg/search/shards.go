@@ -0,0 +1,259 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A shard is a dictionary database holding a single dictionary, cut from
+// the full database with ExportShard. Shards keep the article IDs of the
+// full database, which are the same in every build (see ArticleID), so
+// shards can be merged into a database in any combination with
+// InstallShard, also with shards or databases of other builds. They have
+// no full-text indexes; those are filled by the triggers of the database
+// they are merged into.
+//
+// Merging copies the shard's rows, and the shard file is deleted after.
+// Querying shards attached side by side was rejected: SQLite attaches at
+// most 10 databases by default, fewer than there are dictionaries; every
+// search would become a UNION over the shards' full-text indexes, ranked
+// apart; and each shard would need its own full-text indexes, which make
+// up much of the database's size. A single database keeps one index and
+// the queries of a full download.
+
+// ErrShardConflict is returned when merging a shard whose article IDs are
+// taken by another dictionary, which happens only for articles whose ID
+// hashes collided in one of the builds.
+var ErrShardConflict = errors.New("the dictionary is from a different version of the dictionary data")
+
+// shardTable is a table copied into shards, with the condition selecting
+// a dictionary's rows (the code as ?, the schema as {schema})
+type shardTable struct {
+	name    string
+	columns string
+	where   string
+}
+
+var shardTables = []shardTable{
+	{"dicts", "code, name, from_lang, to_lang, favorite", "code = ?"},
+	{"articles", "id, dict_code, content, homonym, lnum, page, source_id", "dict_code = ?"},
+	{"words", "word_iast, word_deva, article_id, dict_code", "dict_code = ?"},
+	{"citations", "work, book, verse, article_id", "article_id IN (SELECT id FROM {schema}.articles WHERE dict_code = ?)"},
+	{"roots", "root, kind, article_id", "article_id IN (SELECT id FROM {schema}.articles WHERE dict_code = ?)"},
+}
+
+// copyQuery copies a dictionary's rows of the table between schemas
+func (t shardTable) copyQuery(from, to string) string {
+	return fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s WHERE %s",
+		to, t.name, t.columns, t.columns, from, t.name, strings.ReplaceAll(t.where, "{schema}", from))
+}
+
+// Create creates an empty dictionary database to merge shards into, with
+// full-text indexes kept up to date by triggers.
+func Create(path string) (*DB, error) {
+	d, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.InitSchemaForBulkInsert(); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("init schema: %w", err)
+	}
+	if err := d.RebuildFTS(); err != nil {
+		d.Close()
+		return nil, fmt.Errorf("create FTS: %w", err)
+	}
+	if _, err := d.db.Exec("DROP TABLE IF EXISTS stems"); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// ExportShard writes a dictionary of the database to a new shard file,
+// replacing any file at path.
+func (d *DB) ExportShard(dictCode, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	shard, err := OpenForBulkInsert(path)
+	if err != nil {
+		return err
+	}
+	err = shard.InitSchemaForBulkInsert()
+	if err == nil {
+		_, err = shard.db.Exec("DROP TABLE stems")
+	}
+	shard.Close()
+	if err != nil {
+		return fmt.Errorf("init shard schema: %w", err)
+	}
+
+	return d.withAttached(path, func(tx *sql.Tx) error {
+		if err := copyArticleDicts(tx, "main", "shard"); err != nil {
+			return fmt.Errorf("copy article dictionaries: %w", err)
+		}
+		for _, t := range shardTables {
+			if !d.hasTable(t.name) {
+				continue
+			}
+			if _, err := tx.Exec(t.copyQuery("main", "shard"), dictCode); err != nil {
+				return fmt.Errorf("copy %s: %w", t.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// InstallShard merges the dictionaries of a shard file into the database,
+// replacing the copies installed before.
+func (d *DB) InstallShard(path string) error {
+	return d.withAttached(path, func(tx *sql.Tx) error {
+		var codes []string
+		rows, err := tx.Query("SELECT code FROM shard.dicts")
+		if err != nil {
+			return fmt.Errorf("read shard: %w", err)
+		}
+		for rows.Next() {
+			var code string
+			if err := rows.Scan(&code); err != nil {
+				rows.Close()
+				return err
+			}
+			codes = append(codes, code)
+		}
+		rows.Close()
+		if err := copyArticleDicts(tx, "shard", "main"); err != nil {
+			return fmt.Errorf("install article dictionaries: %w", err)
+		}
+
+		for _, code := range codes {
+			if err := removeDict(tx, code); err != nil {
+				return err
+			}
+			var conflicts int
+			err := tx.QueryRow(`
+				SELECT COUNT(*) FROM shard.articles s JOIN main.articles a ON a.id = s.id
+				WHERE s.dict_code = ?
+			`, code).Scan(&conflicts)
+			if err != nil {
+				return err
+			}
+			if conflicts > 0 {
+				return fmt.Errorf("%w: %s", ErrShardConflict, code)
+			}
+
+			for _, t := range shardTables {
+				if !tableExists(tx, "main", t.name) || !tableExists(tx, "shard", t.name) {
+					continue
+				}
+				if _, err := tx.Exec(t.copyQuery("shard", "main"), code); err != nil {
+					return fmt.Errorf("install %s of %s: %w", t.name, code, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveDict removes a dictionary from the database.
+func (d *DB) RemoveDict(dictCode string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := removeDict(tx, dictCode); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// removeDict deletes a dictionary's rows; the triggers remove them from
+// the full-text indexes
+func removeDict(tx *sql.Tx, dictCode string) error {
+	if err := ensureTriggers(tx); err != nil {
+		return err
+	}
+	statements := []struct{ table, query string }{
+		{"citations", `DELETE FROM main.citations WHERE article_id IN (SELECT id FROM main.articles WHERE dict_code = ?)`},
+		{"roots", `DELETE FROM main.roots WHERE article_id IN (SELECT id FROM main.articles WHERE dict_code = ?)`},
+		{"words", `DELETE FROM main.words WHERE dict_code = ?`},
+		{"articles", `DELETE FROM main.articles WHERE dict_code = ?`},
+		{"dicts", `DELETE FROM main.dicts WHERE code = ?`},
+	}
+	for _, stmt := range statements {
+		// Databases from before citations and roots were indexed lack their tables
+		if !tableExists(tx, "main", stmt.table) {
+			continue
+		}
+		if _, err := tx.Exec(stmt.query, dictCode); err != nil {
+			return fmt.Errorf("remove %s from %s: %w", dictCode, stmt.table, err)
+		}
+	}
+	return nil
+}
+
+// ensureTriggers adds the triggers keeping the full-text indexes up to
+// date with deletions and updates, which older databases lack, and
+// replaces article triggers from before articles were compressed
+func ensureTriggers(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		DROP TRIGGER IF EXISTS articles_ai;
+		DROP TRIGGER IF EXISTS articles_ad;
+		DROP TRIGGER IF EXISTS articles_au;
+	` + ftsTriggers); err != nil {
+		return fmt.Errorf("create FTS triggers: %w", err)
+	}
+	return nil
+}
+
+// tableExists reports whether a schema of the connection has a table
+func tableExists(tx *sql.Tx, schema, table string) bool {
+	var n int
+	err := tx.QueryRow("SELECT COUNT(*) FROM "+schema+".sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+	return err == nil && n > 0
+}
+
+// withAttached runs fn in a transaction on a connection with the shard
+// file at path attached as "shard". ATTACH applies to a single connection
+// and can't run inside a transaction.
+func (d *DB) withAttached(path string, fn func(tx *sql.Tx) error) error {
+	ctx := context.Background()
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS shard", path); err != nil {
+		return fmt.Errorf("attach %s: %w", path, err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE shard")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckIntegrity runs SQLite's integrity check on the database.
+func (d *DB) CheckIntegrity() error {
+	var result string
+	if err := d.db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// exportShards writes shards of the sample dictionaries to a temp dir and
+// returns their paths by code
+func exportShards(t *testing.T, codes ...string) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	full := createTestDBAt(t, filepath.Join(dir, "full.db"))
+	defer full.Close()
+
+	paths := make(map[string]string)
+	for _, code := range codes {
+		path := filepath.Join(dir, code+".db")
+		if err := full.ExportShard(code, path); err != nil {
+			t.Fatalf("ExportShard(%s) error = %v", code, err)
+		}
+		paths[code] = path
+	}
+	return paths
+}
+
+// createEmptyDB creates a database to install shards into
+func createEmptyDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Create(filepath.Join(t.TempDir(), "sanskrit.db"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func dictCodes(t *testing.T, db *DB) []string {
+	t.Helper()
+	dicts, err := db.GetDicts()
+	if err != nil {
+		t.Fatalf("GetDicts() error = %v", err)
+	}
+	var codes []string
+	for _, d := range dicts {
+		codes = append(codes, d.Code)
+	}
+	return codes
+}
+
+func TestInstallShard(t *testing.T) {
+	shards := exportShards(t, "mw", "ap90")
+	db := createEmptyDB(t)
+
+	for _, code := range []string{"mw", "ap90"} {
+		if err := db.InstallShard(shards[code]); err != nil {
+			t.Fatalf("InstallShard(%s) error = %v", code, err)
+		}
+	}
+	if codes := dictCodes(t, db); len(codes) != 2 {
+		t.Errorf("dicts = %v, want mw and ap90", codes)
+	}
+
+	results, err := db.Search("dharma", ModeExact, nil)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("exact search found %d results, want 2 (mw and ap90)", len(results))
+	}
+
+	// Full-text indexes are filled by the triggers
+	results, err = db.Search("philosophy", ModeReverse, nil)
+	if err != nil {
+		t.Fatalf("reverse Search() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("reverse search found %d results, want 2", len(results))
+	}
+	if err := db.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+}
+
+func TestInstallShardReplaces(t *testing.T) {
+	shards := exportShards(t, "mw")
+	db := createEmptyDB(t)
+
+	for range 2 {
+		if err := db.InstallShard(shards["mw"]); err != nil {
+			t.Fatalf("InstallShard() error = %v", err)
+		}
+	}
+	results, err := db.Search("karma", ModeExact, nil)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("found %d results after reinstalling, want 1", len(results))
+	}
+	if err := db.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+}
+
+func TestRemoveDict(t *testing.T) {
+	shards := exportShards(t, "mw", "ap90")
+	db := createEmptyDB(t)
+	for _, path := range shards {
+		if err := db.InstallShard(path); err != nil {
+			t.Fatalf("InstallShard() error = %v", err)
+		}
+	}
+
+	if err := db.RemoveDict("mw"); err != nil {
+		t.Fatalf("RemoveDict() error = %v", err)
+	}
+	if codes := dictCodes(t, db); len(codes) != 1 || codes[0] != "ap90" {
+		t.Errorf("dicts = %v, want [ap90]", codes)
+	}
+	for _, mode := range []SearchMode{ModeExact, ModeReverse} {
+		results, err := db.Search("dharma", mode, nil)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		for _, r := range results {
+			if r.DictCode == "mw" {
+				t.Errorf("mode %d found removed dictionary's article %d", mode, r.ArticleID)
+			}
+		}
+	}
+	if err := db.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity() error = %v", err)
+	}
+}
+
+func TestInstallShardConflict(t *testing.T) {
+	shards := exportShards(t, "mw")
+	db := createEmptyDB(t)
+
+	// An article of another dictionary with an ID taken by the shard, as
+	// in data from a different build
+	if _, err := db.db.Exec(`INSERT INTO articles (id, dict_code, content) VALUES (1, 'other', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InstallShard(shards["mw"]); !errors.Is(err, ErrShardConflict) {
+		t.Errorf("InstallShard() error = %v, want ErrShardConflict", err)
+	}
+	if codes := dictCodes(t, db); len(codes) != 0 {
+		t.Errorf("dicts = %v after a conflict, want none", codes)
+	}
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"strings"
+)
+
+// Installing dictionary data from before articles had stable IDs moves
+// articles to other IDs (see search.DB.MapArticles). RemapArticles moves
+// what is kept for them along.
+
+// articleTables are the tables keeping data by article ID
+var articleTables = []string{
+	"starred", "article_tags", "collection_articles", "notes", "cards",
+	"review_log", "history", "navigation",
+}
+
+// ArticleIDs returns the IDs of the articles that anything is kept for:
+// stars, tags, collections, notes, review cards, history or navigation.
+func (s *Store) ArticleIDs() ([]int64, error) {
+	var selects []string
+	for _, table := range articleTables {
+		selects = append(selects, "SELECT article_id FROM "+table)
+	}
+	rows, err := s.db.Query("SELECT article_id FROM (" + strings.Join(selects, " UNION ") + ") WHERE article_id != 0 ORDER BY article_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RemapArticles moves what is kept for articles to their new IDs, given by
+// old ID, together with the sync state of their stars and notes. Where
+// something is already kept under the new ID, e.g. a star synced from a
+// device with the new data, that is kept instead.
+func (s *Store) RemapArticles(moved map[int64]int64) error {
+	if len(moved) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for oldID, newID := range moved {
+		for _, table := range articleTables {
+			if _, err := tx.Exec("UPDATE OR IGNORE "+table+" SET article_id = ? WHERE article_id = ?", newID, oldID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE article_id = ?", oldID); err != nil {
+				return err
+			}
+		}
+		for _, kind := range []string{"star", "note"} {
+			for _, table := range []string{"sync_versions", "sync_changed"} {
+				_, err := tx.Exec("UPDATE OR REPLACE "+table+" SET entity = ? WHERE entity = ?",
+					entityKey(kind, newID), entityKey(kind, oldID))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
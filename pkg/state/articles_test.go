@@ -0,0 +1,162 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/article"
+	"github.com/licht1stein/sanskrit-upaya/pkg/search"
+)
+
+// dictArticle is an article of a test dictionary database
+type dictArticle struct {
+	dict, sourceID, word, content string
+}
+
+// createDictDB creates a dictionary database. Articles without a source ID
+// are numbered in order, as in data from before articles had stable IDs.
+func createDictDB(t *testing.T, path string, articles []dictArticle) *search.DB {
+	t.Helper()
+	db, err := search.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InitSchemaForBulkInsert(); err != nil {
+		t.Fatal(err)
+	}
+	bi, err := db.NewBulkInserter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"mw", "ap90"} {
+		if err := bi.InsertDict(code, code, "sa", "en", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range articles {
+		id, err := bi.InsertSourceArticle(a.dict, a.sourceID, a.content, article.SourceOf(a.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bi.InsertWord(a.word, "", id, a.dict); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bi.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RebuildFTS(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRemapArticles(t *testing.T) {
+	store := createTestStore(t)
+	dir := t.TempDir()
+	oldDB := createDictDB(t, filepath.Join(dir, "old.db"), []dictArticle{
+		{"mw", "", "dharma", "dharma m. law"},
+		{"ap90", "", "dharma", "dharma m. religion"},
+		{"mw", "", "karma", "karma n. act"},
+	})
+	karma, err := oldDB.Search("karma", search.ModeExact, nil)
+	if err != nil || len(karma) != 1 {
+		t.Fatalf("Search() = %v, %v", karma, err)
+	}
+	oldID := karma[0].ArticleID
+
+	// Everything kept by article ID, synced once
+	if err := store.StarArticle(oldID, "karma", "mw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.TagArticle(oldID, "verbs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateCollection("lesson"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddToCollection("lesson", oldID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveNote(oldID, "karma", "mw", "cf. kṛ"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GradeCard(oldID, GradeGood, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordSearch(HistoryEntry{Query: "karma", Mode: "Exact", Results: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetHistoryArticle("karma", oldID, "karma"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveNavigation([]NavEntry{{Query: "karma", Mode: "Exact", ArticleID: oldID}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	syncDir := t.TempDir()
+	mustSync(t, store, syncDir)
+
+	// Updated data, with stable IDs and another article first
+	newPath := filepath.Join(dir, "new.db")
+	newDB := createDictDB(t, newPath, []dictArticle{
+		{"mw", "0", "a", "a the first letter"},
+		{"mw", "2", "karma", "karma n. act"},
+		{"mw", "1", "dharma", "dharma m. law"},
+		{"ap90", "1", "dharma", "dharma m. religion"},
+	})
+	ids, err := store.ArticleIDs()
+	if err != nil || len(ids) != 1 || ids[0] != oldID {
+		t.Fatalf("ArticleIDs() = %v, %v; want [%d]", ids, err, oldID)
+	}
+	moved, err := oldDB.MapArticles(newPath, ids)
+	if err != nil {
+		t.Fatalf("MapArticles() error = %v", err)
+	}
+	newID := search.ArticleID("mw", "2")
+	if moved[oldID] != newID {
+		t.Fatalf("MapArticles() = %v, want %d moved to %d", moved, oldID, newID)
+	}
+	if err := store.RemapArticles(moved); err != nil {
+		t.Fatalf("RemapArticles() error = %v", err)
+	}
+
+	// The star survives the update
+	starred := store.GetStarredArticles()
+	if len(starred) != 1 || starred[0].ArticleID != newID {
+		t.Fatalf("GetStarredArticles() = %v, want article %d", starred, newID)
+	}
+	if content, err := newDB.GetArticleContent(starred[0].ArticleID); err != nil || content != "karma n. act" {
+		t.Errorf("starred article = %q, %v; want karma", content, err)
+	}
+	if tags := store.ArticleTags(newID); len(tags) != 1 || tags[0] != "verbs" {
+		t.Errorf("ArticleTags() = %v, want verbs", tags)
+	}
+	if articles := store.CollectionArticles("lesson"); len(articles) != 1 || articles[0].ArticleID != newID {
+		t.Errorf("CollectionArticles() = %v", articles)
+	}
+	if note, ok := store.GetNote(newID); !ok || note.Text != "cf. kṛ" {
+		t.Errorf("GetNote() = %v, %v", note, ok)
+	}
+	if notes, err := store.SearchNotes("cf"); err != nil || len(notes) != 1 || notes[0].ArticleID != newID {
+		t.Errorf("SearchNotes() = %v, %v", notes, err)
+	}
+	if reviews := store.ReviewLog(newID); len(reviews) != 1 {
+		t.Errorf("ReviewLog() = %v, want 1 review", reviews)
+	}
+	if history := store.SearchHistory("", 10); len(history) != 1 || history[0].ArticleID != newID {
+		t.Errorf("SearchHistory() = %v", history)
+	}
+	if entries, _ := store.LoadNavigation(); len(entries) != 1 || entries[0].ArticleID != newID {
+		t.Errorf("LoadNavigation() = %v", entries)
+	}
+	if ids, err := store.ArticleIDs(); err != nil || len(ids) != 1 || ids[0] != newID {
+		t.Errorf("ArticleIDs() after remap = %v, %v; want [%d]", ids, err, newID)
+	}
+
+	// The synced versions moved along, so nothing looks changed
+	if r := mustSync(t, store, syncDir); r.Sent != 0 {
+		t.Errorf("Sync() after remap = %+v, want nothing sent", r)
+	}
+}
@@ -0,0 +1,429 @@
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportVersion is the version of the format written by Export. Import
+// reads this version and older ones.
+const ExportVersion = 1
+
+// ErrUnsupportedExport is returned when importing data that is not an
+// export or was written by a newer version.
+var ErrUnsupportedExport = errors.New("unsupported export format")
+
+// sqliteTime is the layout of SQLite's CURRENT_TIMESTAMP, used for imported
+// times so they compare correctly with times written by SQLite
+const sqliteTime = "2006-01-02 15:04:05"
+
+// MergePolicy decides what Import does when the imported data and the
+// store both have a setting, note or card.
+type MergePolicy int
+
+const (
+	// MergeUnion adds whatever the store lacks and keeps the store's own
+	// settings, notes and cards.
+	MergeUnion MergePolicy = iota
+	// MergeKeepNewest adds whatever the store lacks and keeps whichever
+	// copy of a setting, note or card was changed last.
+	MergeKeepNewest
+)
+
+// Snapshot is the exported user state. Settings include the content of the
+// transliteration editor (editor_content).
+type Snapshot struct {
+	Version     int                `json:"version"`
+	ExportedAt  time.Time          `json:"exported_at"`
+	Settings    []SettingRecord    `json:"settings"`
+	History     []HistoryRecord    `json:"history"`
+	Starred     []StarredRecord    `json:"starred"`
+	Collections []CollectionRecord `json:"collections"`
+	Notes       []NoteRecord       `json:"notes"`
+	Cards       []CardRecord       `json:"cards"`
+	Reviews     []ReviewRecord     `json:"reviews"`
+}
+
+// SettingRecord is an exported setting.
+type SettingRecord struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"` // Zero for settings saved before times were kept
+}
+
+// HistoryRecord is an exported search history entry.
+type HistoryRecord struct {
+	Query       string    `json:"query"`
+	Mode        string    `json:"mode,omitempty"`
+	Dicts       []string  `json:"dicts,omitempty"`
+	Results     int       `json:"results,omitempty"`
+	ArticleID   int64     `json:"article_id,omitempty"`
+	ArticleWord string    `json:"article_word,omitempty"`
+	Count       int       `json:"count"`
+	LastUsed    time.Time `json:"last_used"`
+}
+
+// StarredRecord is an exported starred article with its tags.
+type StarredRecord struct {
+	ArticleID int64     `json:"article_id"`
+	Word      string    `json:"word"`
+	DictCode  string    `json:"dict_code"`
+	CreatedAt time.Time `json:"created_at"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+// CollectionRecord is an exported collection with its articles in order.
+type CollectionRecord struct {
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	ArticleIDs []int64   `json:"article_ids"`
+}
+
+// NoteRecord is an exported note.
+type NoteRecord struct {
+	ArticleID int64     `json:"article_id"`
+	Word      string    `json:"word"`
+	DictCode  string    `json:"dict_code"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CardRecord is the exported review schedule of an article.
+type CardRecord struct {
+	ArticleID    int64     `json:"article_id"`
+	Ease         float64   `json:"ease"`
+	Interval     int       `json:"interval_days"`
+	Repetitions  int       `json:"repetitions"`
+	Lapses       int       `json:"lapses"`
+	Due          time.Time `json:"due"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastReviewed time.Time `json:"last_reviewed"`
+}
+
+// ReviewRecord is an exported review log entry.
+type ReviewRecord struct {
+	ArticleID  int64     `json:"article_id"`
+	Grade      Grade     `json:"grade"`
+	Interval   int       `json:"interval_days"`
+	Ease       float64   `json:"ease"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+// Export writes the user state as JSON: settings, search history, starred
+// articles with their tags, collections, notes and review progress.
+func (s *Store) Export(w io.Writer) error {
+	snap, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// snapshot reads the whole user state
+func (s *Store) snapshot() (*Snapshot, error) {
+	snap := &Snapshot{Version: ExportVersion, ExportedAt: time.Now().UTC()}
+
+	err := s.queryRows("SELECT key, value, updated_at FROM settings ORDER BY key", func(rows *sql.Rows) error {
+		var r SettingRecord
+		var updated sql.NullTime
+		if err := rows.Scan(&r.Key, &r.Value, &updated); err != nil {
+			return err
+		}
+		r.UpdatedAt = updated.Time
+		snap.Settings = append(snap.Settings, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(`
+		SELECT query, mode, dicts, results, article_id, article_word, count, last_used
+		FROM history ORDER BY last_used DESC
+	`, func(rows *sql.Rows) error {
+		var r HistoryRecord
+		var dicts string
+		if err := rows.Scan(&r.Query, &r.Mode, &dicts, &r.Results, &r.ArticleID, &r.ArticleWord, &r.Count, &r.LastUsed); err != nil {
+			return err
+		}
+		if dicts != "" {
+			r.Dicts = strings.Split(dicts, ",")
+		}
+		snap.History = append(snap.History, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := s.TaggedArticles()
+	err = s.queryRows("SELECT article_id, word, dict_code, created_at FROM starred ORDER BY id", func(rows *sql.Rows) error {
+		var r StarredRecord
+		if err := rows.Scan(&r.ArticleID, &r.Word, &r.DictCode, &r.CreatedAt); err != nil {
+			return err
+		}
+		r.Tags = tags[r.ArticleID]
+		snap.Starred = append(snap.Starred, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(`
+		SELECT c.name, c.created_at, ca.article_id FROM collections c
+		LEFT JOIN collection_articles ca ON ca.collection_id = c.id
+		ORDER BY c.name COLLATE NOCASE, ca.position
+	`, func(rows *sql.Rows) error {
+		var name string
+		var created time.Time
+		var articleID sql.NullInt64
+		if err := rows.Scan(&name, &created, &articleID); err != nil {
+			return err
+		}
+		if n := len(snap.Collections); n == 0 || snap.Collections[n-1].Name != name {
+			snap.Collections = append(snap.Collections, CollectionRecord{Name: name, CreatedAt: created, ArticleIDs: []int64{}})
+		}
+		if articleID.Valid {
+			c := &snap.Collections[len(snap.Collections)-1]
+			c.ArticleIDs = append(c.ArticleIDs, articleID.Int64)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(`
+		SELECT article_id, word, dict_code, text, created_at, updated_at FROM notes ORDER BY article_id
+	`, func(rows *sql.Rows) error {
+		var r NoteRecord
+		if err := rows.Scan(&r.ArticleID, &r.Word, &r.DictCode, &r.Text, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return err
+		}
+		snap.Notes = append(snap.Notes, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(`
+		SELECT c.article_id, c.ease, c.interval_days, c.repetitions, c.lapses, c.due, c.created_at,
+			COALESCE((SELECT MAX(reviewed_at) FROM review_log r WHERE r.article_id = c.article_id), c.created_at)
+		FROM cards c ORDER BY c.article_id
+	`, func(rows *sql.Rows) error {
+		var r CardRecord
+		var due, created, reviewed int64
+		if err := rows.Scan(&r.ArticleID, &r.Ease, &r.Interval, &r.Repetitions, &r.Lapses, &due, &created, &reviewed); err != nil {
+			return err
+		}
+		r.Due, r.CreatedAt, r.LastReviewed = time.Unix(due, 0).UTC(), time.Unix(created, 0).UTC(), time.Unix(reviewed, 0).UTC()
+		snap.Cards = append(snap.Cards, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.queryRows(`
+		SELECT article_id, grade, interval_days, ease, reviewed_at FROM review_log ORDER BY reviewed_at, id
+	`, func(rows *sql.Rows) error {
+		var r ReviewRecord
+		var reviewed int64
+		if err := rows.Scan(&r.ArticleID, &r.Grade, &r.Interval, &r.Ease, &reviewed); err != nil {
+			return err
+		}
+		r.ReviewedAt = time.Unix(reviewed, 0).UTC()
+		snap.Reviews = append(snap.Reviews, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// queryRows runs a query and calls scan for each row
+func (s *Store) queryRows(query string, scan func(rows *sql.Rows) error) error {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import merges user state written by Export into the store. Everything
+// the store lacks is added; conflicts are resolved by policy. Search
+// history, stars, tags, collections and the review log are always merged,
+// and nothing is ever deleted. The import is all or nothing.
+func (s *Store) Import(r io.Reader, policy MergePolicy) error {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedExport, err)
+	}
+	if snap.Version < 1 || snap.Version > ExportVersion {
+		return fmt.Errorf("%w: version %d", ErrUnsupportedExport, snap.Version)
+	}
+	newest := policy == MergeKeepNewest
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rec := range snap.Settings {
+		query := "INSERT OR IGNORE INTO settings (key, value, updated_at) VALUES (?, ?, ?)"
+		if newest {
+			// Settings without a time lose to any setting with one
+			query = `
+				INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+				ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+				WHERE excluded.updated_at > COALESCE(settings.updated_at, '')`
+		}
+		if _, err := tx.Exec(query, rec.Key, rec.Value, nullTime(rec.UpdatedAt)); err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range snap.History {
+		_, err := tx.Exec(`
+			INSERT INTO history (query, count, last_used, mode, dicts, results, article_id, article_word)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(query) DO UPDATE SET
+				count = MAX(count, excluded.count),
+				mode = CASE WHEN excluded.last_used > last_used THEN excluded.mode ELSE mode END,
+				dicts = CASE WHEN excluded.last_used > last_used THEN excluded.dicts ELSE dicts END,
+				results = CASE WHEN excluded.last_used > last_used THEN excluded.results ELSE results END,
+				article_id = CASE WHEN excluded.last_used > last_used THEN excluded.article_id ELSE article_id END,
+				article_word = CASE WHEN excluded.last_used > last_used THEN excluded.article_word ELSE article_word END,
+				last_used = MAX(last_used, excluded.last_used)
+		`, rec.Query, rec.Count, rec.LastUsed.UTC().Format(sqliteTime),
+			rec.Mode, strings.Join(rec.Dicts, ","), rec.Results, rec.ArticleID, rec.ArticleWord)
+		if err != nil {
+			return err
+		}
+	}
+	if err := trimHistory(tx); err != nil {
+		return err
+	}
+
+	for _, rec := range snap.Starred {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO starred (article_id, word, dict_code, created_at) VALUES (?, ?, ?, ?)
+		`, rec.ArticleID, rec.Word, rec.DictCode, rec.CreatedAt.UTC().Format(sqliteTime))
+		if err != nil {
+			return err
+		}
+		for _, tag := range rec.Tags {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`
+				INSERT OR IGNORE INTO article_tags (article_id, tag_id)
+				SELECT ?, id FROM tags WHERE name = ?
+			`, rec.ArticleID, tag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, rec := range snap.Collections {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO collections (name, created_at) VALUES (?, ?)
+		`, rec.Name, rec.CreatedAt.UTC().Format(sqliteTime))
+		if err != nil {
+			return err
+		}
+		// Articles new to the collection go after the ones it has
+		for _, articleID := range rec.ArticleIDs {
+			_, err := tx.Exec(`
+				INSERT OR IGNORE INTO collection_articles (collection_id, article_id, position)
+				SELECT c.id, ?, COALESCE((SELECT MAX(position) + 1 FROM collection_articles WHERE collection_id = c.id), 0)
+				FROM collections c WHERE c.name = ?
+			`, articleID, rec.Name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, rec := range snap.Notes {
+		query := `
+			INSERT INTO notes (article_id, word, dict_code, text, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(article_id) DO NOTHING`
+		if newest {
+			query = `
+				INSERT INTO notes (article_id, word, dict_code, text, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT(article_id) DO UPDATE SET text = excluded.text, updated_at = excluded.updated_at
+				WHERE excluded.updated_at > notes.updated_at`
+		}
+		_, err := tx.Exec(query, rec.ArticleID, rec.Word, rec.DictCode, rec.Text,
+			rec.CreatedAt.UTC().Format(sqliteTime), rec.UpdatedAt.UTC().Format(sqliteTime))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Cards before the review log, whose latest review tells which card is newer
+	for _, rec := range snap.Cards {
+		query := `
+			INSERT INTO cards (article_id, ease, interval_days, repetitions, lapses, due, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(article_id) DO NOTHING`
+		if newest {
+			query = `
+				INSERT INTO cards (article_id, ease, interval_days, repetitions, lapses, due, created_at)
+				VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+				ON CONFLICT(article_id) DO UPDATE SET
+					ease = excluded.ease, interval_days = excluded.interval_days,
+					repetitions = excluded.repetitions, lapses = excluded.lapses, due = excluded.due
+				WHERE ?8 > COALESCE((SELECT MAX(reviewed_at) FROM review_log WHERE article_id = ?1), cards.created_at)`
+		}
+		_, err := tx.Exec(query, rec.ArticleID, rec.Ease, rec.Interval, rec.Repetitions, rec.Lapses,
+			rec.Due.Unix(), rec.CreatedAt.Unix(), rec.LastReviewed.Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range snap.Reviews {
+		_, err := tx.Exec(`
+			INSERT INTO review_log (article_id, grade, interval_days, ease, reviewed_at)
+			SELECT ?1, ?2, ?3, ?4, ?5
+			WHERE NOT EXISTS (
+				SELECT 1 FROM review_log WHERE article_id = ?1 AND reviewed_at = ?5 AND grade = ?2
+			)
+		`, rec.ArticleID, int(rec.Grade), rec.Interval, rec.Ease, rec.ReviewedAt.Unix())
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// nullTime formats t for SQLite, or returns NULL for the zero time
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(sqliteTime)
+}
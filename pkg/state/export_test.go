@@ -0,0 +1,150 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	src := createTestStore(t)
+	src.Set("zoom", "1.2")
+	src.Set("editor_content", "agnim īḷe")
+	src.AddHistory("agni")
+	src.AddHistory("agni")
+	src.RecordSearch(HistoryEntry{Query: "soma", Mode: "Exact", DictCodes: []string{"mw", "ap90"}, Results: 3})
+	src.SetHistoryArticle("soma", 2, "soma")
+	src.StarArticle(1, "agni", "mw")
+	src.StarArticle(2, "soma", "mw")
+	src.TagArticle(1, "Vedic gods")
+	src.AddToCollection("Lesson 1", 2)
+	src.AddToCollection("Lesson 1", 1)
+	src.SaveNote(2, "soma", "mw", "Pressed at the sacrifice")
+	now := time.Now().Truncate(time.Second)
+	src.GradeCard(1, GradeGood, now)
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(buf.Bytes(), &snap); err != nil {
+		t.Fatalf("Export() wrote invalid JSON: %v", err)
+	}
+	if snap.Version != ExportVersion || len(snap.Starred) != 2 || len(snap.Reviews) != 1 {
+		t.Errorf("Export() = %+v", snap)
+	}
+
+	dst := createTestStore(t)
+	dst.Set("zoom", "0.8")
+	dst.StarArticle(3, "yoga", "ap90")
+	if err := dst.Import(&buf, MergeUnion); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if got := dst.Get("editor_content"); got != "agnim īḷe" {
+		t.Errorf("editor_content = %q", got)
+	}
+	if got := dst.Get("zoom"); got != "0.8" {
+		t.Errorf("MergeUnion overwrote zoom: %q", got)
+	}
+	if got := dst.GetRecentHistory(10); len(got) != 2 {
+		t.Errorf("history = %v", got)
+	}
+	if got := dst.SearchHistory("soma", 10); len(got) != 1 || got[0].Mode != "Exact" || len(got[0].DictCodes) != 2 || got[0].ArticleID != 2 {
+		t.Errorf("history context = %+v", got)
+	}
+	if got := dst.GetStarredArticles(); len(got) != 3 {
+		t.Errorf("starred = %+v, want 3 articles", got)
+	}
+	if got := dst.ArticleTags(1); len(got) != 1 || got[0] != "Vedic gods" {
+		t.Errorf("tags = %v", got)
+	}
+	if got := dst.CollectionArticles("Lesson 1"); len(got) != 2 || got[0].ArticleID != 2 {
+		t.Errorf("collection = %+v", got)
+	}
+	if n, ok := dst.GetNote(2); !ok || n.Text != "Pressed at the sacrifice" {
+		t.Errorf("note = %+v, %v", n, ok)
+	}
+	if notes, _ := dst.SearchNotes("sacrifice"); len(notes) != 1 {
+		t.Errorf("imported note not searchable: %+v", notes)
+	}
+	if log := dst.ReviewLog(1); len(log) != 1 || !log[0].ReviewedAt.Equal(now) {
+		t.Errorf("review log = %+v", log)
+	}
+	if cards := dst.DueCards(now.AddDate(0, 0, 1), 0); len(cards) != 1 || cards[0].Interval != 1 {
+		t.Errorf("due cards = %+v", cards)
+	}
+
+	// Importing again changes nothing
+	buf.Reset()
+	src.Export(&buf)
+	if err := dst.Import(&buf, MergeUnion); err != nil {
+		t.Fatalf("second Import() error = %v", err)
+	}
+	if log := dst.ReviewLog(1); len(log) != 1 {
+		t.Errorf("review log after second import = %+v", log)
+	}
+	if got := dst.CollectionArticles("Lesson 1"); len(got) != 2 {
+		t.Errorf("collection after second import = %+v", got)
+	}
+}
+
+func TestImportKeepNewest(t *testing.T) {
+	store := createTestStore(t)
+	store.Set("theme", "dark")
+	store.Set("zoom", "1.0")
+	store.SaveNote(1, "agni", "mw", "local note")
+
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-24 * time.Hour)
+	snap := Snapshot{
+		Version: ExportVersion,
+		Settings: []SettingRecord{
+			{Key: "theme", Value: "light", UpdatedAt: later},
+			{Key: "zoom", Value: "2.0", UpdatedAt: earlier},
+		},
+		Notes: []NoteRecord{
+			{ArticleID: 1, Word: "agni", DictCode: "mw", Text: "imported note", CreatedAt: earlier, UpdatedAt: later},
+		},
+	}
+	data, _ := json.Marshal(snap)
+
+	// MergeUnion keeps everything local
+	if err := store.Import(bytes.NewReader(data), MergeUnion); err != nil {
+		t.Fatalf("Import(MergeUnion) error = %v", err)
+	}
+	if store.Get("theme") != "dark" {
+		t.Errorf("MergeUnion: theme = %q", store.Get("theme"))
+	}
+	if n, _ := store.GetNote(1); n.Text != "local note" {
+		t.Errorf("MergeUnion: note = %q", n.Text)
+	}
+
+	// MergeKeepNewest takes the newer copies only
+	if err := store.Import(bytes.NewReader(data), MergeKeepNewest); err != nil {
+		t.Fatalf("Import(MergeKeepNewest) error = %v", err)
+	}
+	if store.Get("theme") != "light" {
+		t.Errorf("MergeKeepNewest: theme = %q, want light", store.Get("theme"))
+	}
+	if store.Get("zoom") != "1.0" {
+		t.Errorf("MergeKeepNewest: zoom = %q, want local 1.0", store.Get("zoom"))
+	}
+	if n, _ := store.GetNote(1); n.Text != "imported note" {
+		t.Errorf("MergeKeepNewest: note = %q", n.Text)
+	}
+}
+
+func TestImportUnsupported(t *testing.T) {
+	store := createTestStore(t)
+	for _, input := range []string{"not json", `{"version": 99}`, `{}`} {
+		err := store.Import(strings.NewReader(input), MergeUnion)
+		if !errors.Is(err, ErrUnsupportedExport) {
+			t.Errorf("Import(%q) error = %v, want ErrUnsupportedExport", input, err)
+		}
+	}
+}
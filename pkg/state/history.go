@@ -0,0 +1,102 @@
+package state
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryEntry is a search from the history with the context it ran in.
+type HistoryEntry struct {
+	Query       string
+	Mode        string   // Search mode label ("Exact", "Full-text", ...), empty for searches recorded without context
+	DictCodes   []string // Dictionaries searched, in display order
+	Results     int      // Number of results found
+	ArticleID   int64    // Article last opened from the results, 0 if none
+	ArticleWord string   // Headword of that article
+	Count       int      // Times the query was searched
+	LastUsed    time.Time
+}
+
+// RecordSearch adds a search to the history with its context, or updates
+// the query's entry with the new context. The article opened from the
+// results is recorded separately with SetHistoryArticle.
+func (s *Store) RecordSearch(e HistoryEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO history (query, count, last_used, mode, dicts, results)
+		VALUES (?, 1, CURRENT_TIMESTAMP, ?, ?, ?)
+		ON CONFLICT(query) DO UPDATE SET
+			count = count + 1, last_used = CURRENT_TIMESTAMP,
+			mode = excluded.mode, dicts = excluded.dicts, results = excluded.results,
+			article_id = 0, article_word = ''
+	`, e.Query, e.Mode, strings.Join(e.DictCodes, ","), e.Results)
+	if err != nil {
+		return err
+	}
+
+	if err := trimHistory(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetHistoryArticle records the article opened from a query's results.
+func (s *Store) SetHistoryArticle(query string, articleID int64, word string) error {
+	_, err := s.db.Exec(`
+		UPDATE history SET article_id = ?, article_word = ? WHERE query = ?
+	`, articleID, word, query)
+	return err
+}
+
+// SearchHistory returns the history entries whose query or opened article
+// contains filter, most recent first. An empty filter returns all entries.
+func (s *Store) SearchHistory(filter string, limit int) []HistoryEntry {
+	pattern := "%" + escapeLike(strings.TrimSpace(filter)) + "%"
+	rows, err := s.db.Query(`
+		SELECT query, mode, dicts, results, article_id, article_word, count, last_used
+		FROM history
+		WHERE query LIKE ?1 ESCAPE '\' OR article_word LIKE ?1 ESCAPE '\'
+		ORDER BY last_used DESC, id DESC
+		LIMIT ?2
+	`, pattern, limit)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		var dicts string
+		if err := rows.Scan(&e.Query, &e.Mode, &dicts, &e.Results, &e.ArticleID, &e.ArticleWord, &e.Count, &e.LastUsed); err != nil {
+			continue
+		}
+		if dicts != "" {
+			e.DictCodes = strings.Split(dicts, ",")
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// DeleteHistory removes a query from the history.
+func (s *Store) DeleteHistory(query string) error {
+	_, err := s.db.Exec("DELETE FROM history WHERE query = ?", query)
+	return err
+}
+
+// ClearHistory removes all search history.
+func (s *Store) ClearHistory() error {
+	_, err := s.db.Exec("DELETE FROM history")
+	return err
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,76 @@
+package state
+
+import "testing"
+
+func TestRecordSearch(t *testing.T) {
+	store := createTestStore(t)
+
+	err := store.RecordSearch(HistoryEntry{Query: "agni", Mode: "Exact", DictCodes: []string{"mw", "ap90"}, Results: 4})
+	if err != nil {
+		t.Fatalf("RecordSearch() error = %v", err)
+	}
+	if err := store.SetHistoryArticle("agni", 42, "agni"); err != nil {
+		t.Fatalf("SetHistoryArticle() error = %v", err)
+	}
+
+	got := store.SearchHistory("", 10)
+	if len(got) != 1 {
+		t.Fatalf("SearchHistory() = %+v, want 1 entry", got)
+	}
+	e := got[0]
+	if e.Mode != "Exact" || len(e.DictCodes) != 2 || e.DictCodes[1] != "ap90" ||
+		e.Results != 4 || e.ArticleID != 42 || e.ArticleWord != "agni" || e.Count != 1 {
+		t.Errorf("entry = %+v", e)
+	}
+
+	// Searching again replaces the context and forgets the opened article
+	store.RecordSearch(HistoryEntry{Query: "agni", Mode: "Prefix", Results: 10})
+	e = store.SearchHistory("", 10)[0]
+	if e.Mode != "Prefix" || e.DictCodes != nil || e.Results != 10 || e.ArticleID != 0 || e.Count != 2 {
+		t.Errorf("entry after second search = %+v", e)
+	}
+}
+
+func TestSearchHistory(t *testing.T) {
+	store := createTestStore(t)
+	store.RecordSearch(HistoryEntry{Query: "agni"})
+	store.RecordSearch(HistoryEntry{Query: "soma"})
+	store.SetHistoryArticle("soma", 7, "somapa")
+	store.RecordSearch(HistoryEntry{Query: "100%_done"})
+
+	tests := []struct {
+		filter string
+		want   int
+	}{
+		{"", 3},
+		{"agn", 1},
+		{"somapa", 1}, // matches the opened article
+		{"%", 1},      // wildcards are literal
+		{"_", 1},
+		{"xyz", 0},
+	}
+	for _, tt := range tests {
+		if got := store.SearchHistory(tt.filter, 10); len(got) != tt.want {
+			t.Errorf("SearchHistory(%q) = %+v, want %d entries", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteHistory(t *testing.T) {
+	store := createTestStore(t)
+	store.RecordSearch(HistoryEntry{Query: "agni"})
+	store.RecordSearch(HistoryEntry{Query: "soma"})
+
+	if err := store.DeleteHistory("agni"); err != nil {
+		t.Fatalf("DeleteHistory() error = %v", err)
+	}
+	if got := store.GetRecentHistory(10); len(got) != 1 || got[0] != "soma" {
+		t.Errorf("history after delete = %v", got)
+	}
+	if err := store.ClearHistory(); err != nil {
+		t.Fatalf("ClearHistory() error = %v", err)
+	}
+	if got := store.GetRecentHistory(10); len(got) != 0 {
+		t.Errorf("history after clear = %v", got)
+	}
+}
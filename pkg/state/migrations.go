@@ -0,0 +1,279 @@
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrSchemaTooNew is returned when the state database was upgraded by a
+// newer version of the app than this one.
+var ErrSchemaTooNew = errors.New("state database was created by a newer version of the app")
+
+// migration upgrades the state database by one schema version.
+type migration struct {
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations are applied in order; after migrations[i] the schema version
+// (PRAGMA user_version) is i+1. Never edit or reorder released migrations,
+// only append new ones.
+//
+// Databases from before schema versions were kept are at version 0 with
+// any of the historical schemas, so the migrations up to "sync" tolerate
+// tables and columns that already exist.
+var migrations = []migration{
+	{"settings, history and starred articles", execSQL(`
+		CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			query TEXT NOT NULL UNIQUE,
+			count INTEGER DEFAULT 1,
+			last_used DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS starred (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			article_id INTEGER NOT NULL UNIQUE,
+			word TEXT NOT NULL,
+			dict_code TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)},
+	// Back/forward navigation of the last session, in visit order
+	{"navigation", execSQL(`
+		CREATE TABLE IF NOT EXISTS navigation (
+			position INTEGER PRIMARY KEY,
+			query TEXT NOT NULL,
+			mode TEXT NOT NULL,
+			article_id INTEGER NOT NULL DEFAULT 0,
+			current INTEGER NOT NULL DEFAULT 0
+		)
+	`)},
+	// Tags of starred articles (many-to-many) and named, ordered collections
+	{"tags and collections", execSQL(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE
+		);
+		CREATE TABLE IF NOT EXISTS article_tags (
+			article_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (article_id, tag_id)
+		);
+		CREATE TABLE IF NOT EXISTS collections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS collection_articles (
+			collection_id INTEGER NOT NULL,
+			article_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (collection_id, article_id)
+		)
+	`)},
+	// Personal notes on articles (markdown), full-text indexed
+	{"notes", execSQL(`
+		CREATE TABLE IF NOT EXISTS notes (
+			article_id INTEGER PRIMARY KEY,
+			word TEXT NOT NULL,
+			dict_code TEXT NOT NULL,
+			text TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts5(
+			text,
+			content='notes',
+			content_rowid='article_id',
+			tokenize='unicode61 remove_diacritics 0'
+		);
+		CREATE TRIGGER IF NOT EXISTS notes_ai AFTER INSERT ON notes BEGIN
+			INSERT INTO notes_fts(rowid, text) VALUES (new.article_id, new.text);
+		END;
+		CREATE TRIGGER IF NOT EXISTS notes_ad AFTER DELETE ON notes BEGIN
+			INSERT INTO notes_fts(notes_fts, rowid, text) VALUES ('delete', old.article_id, old.text);
+		END;
+		CREATE TRIGGER IF NOT EXISTS notes_au AFTER UPDATE ON notes BEGIN
+			INSERT INTO notes_fts(notes_fts, rowid, text) VALUES ('delete', old.article_id, old.text);
+			INSERT INTO notes_fts(rowid, text) VALUES (new.article_id, new.text);
+		END
+	`)},
+	// Flashcard scheduling of starred articles and the log of every review.
+	// Times are unix seconds so due cards can be compared in SQL.
+	{"review cards", execSQL(`
+		CREATE TABLE IF NOT EXISTS cards (
+			article_id INTEGER PRIMARY KEY,
+			ease REAL NOT NULL DEFAULT 2.5,
+			interval_days INTEGER NOT NULL DEFAULT 0,
+			repetitions INTEGER NOT NULL DEFAULT 0,
+			lapses INTEGER NOT NULL DEFAULT 0,
+			due INTEGER NOT NULL,
+			created_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS review_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			article_id INTEGER NOT NULL,
+			grade INTEGER NOT NULL,
+			interval_days INTEGER NOT NULL,
+			ease REAL NOT NULL,
+			reviewed_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_review_log_article ON review_log(article_id)
+	`)},
+	// Modification time of settings, for merging imports
+	{"settings modification time", func(tx *sql.Tx) error {
+		if hasColumn(tx, "settings", "updated_at") {
+			return nil
+		}
+		_, err := tx.Exec("ALTER TABLE settings ADD COLUMN updated_at DATETIME")
+		return err
+	}},
+	// Sync between devices: this device's ID, the last known version of
+	// each synced item and how far other devices' change logs have been read
+	{"sync", execSQL(`
+		CREATE TABLE IF NOT EXISTS sync_device (
+			id TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS sync_versions (
+			entity TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			time INTEGER NOT NULL,
+			device TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS sync_files (
+			name TEXT PRIMARY KEY,
+			offset INTEGER NOT NULL
+		)
+	`)},
+	// Search history remembers the context of each search
+	{"search history context", execSQL(`
+		ALTER TABLE history ADD COLUMN mode TEXT NOT NULL DEFAULT '';
+		ALTER TABLE history ADD COLUMN dicts TEXT NOT NULL DEFAULT '';
+		ALTER TABLE history ADD COLUMN results INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE history ADD COLUMN article_id INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE history ADD COLUMN article_word TEXT NOT NULL DEFAULT ''
+	`)},
+	// When each synced item last changed here (unix nanoseconds, kept by
+	// triggers), so that a sync stamps a change with the time it was made;
+	// and the first line of other devices' change logs, by which a
+	// compacted log is noticed
+	{"sync change times", execSQL(`
+		CREATE TABLE IF NOT EXISTS sync_changed (
+			entity TEXT PRIMARY KEY,
+			time INTEGER NOT NULL
+		);
+		CREATE TRIGGER IF NOT EXISTS starred_sync_ai AFTER INSERT ON starred BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('star:' || new.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS starred_sync_ad AFTER DELETE ON starred BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('star:' || old.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS article_tags_sync_ai AFTER INSERT ON article_tags BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('star:' || new.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS article_tags_sync_ad AFTER DELETE ON article_tags BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('star:' || old.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS tags_sync_au AFTER UPDATE OF name ON tags BEGIN
+			INSERT INTO sync_changed (entity, time)
+			SELECT 'star:' || article_id, ` + nowNanos + ` FROM article_tags WHERE tag_id = new.id ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS notes_sync_ai AFTER INSERT ON notes BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('note:' || new.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS notes_sync_au AFTER UPDATE ON notes BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('note:' || new.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		CREATE TRIGGER IF NOT EXISTS notes_sync_ad AFTER DELETE ON notes BEGIN
+			INSERT INTO sync_changed (entity, time) VALUES ('note:' || old.article_id, ` + nowNanos + `) ` + touchChanged + `;
+		END;
+		ALTER TABLE sync_files ADD COLUMN head TEXT NOT NULL DEFAULT ''
+	`)},
+}
+
+// nowNanos is the current time in unix nanoseconds in SQL, to the
+// millisecond
+const nowNanos = `CAST(unixepoch('subsec') * 1000 AS INTEGER) * 1000000`
+
+// touchChanged completes an insert into sync_changed. It is an upsert
+// rather than INSERT OR REPLACE, which the conflict policy of the statement
+// firing the trigger would override.
+const touchChanged = `ON CONFLICT(entity) DO UPDATE SET time = excluded.time`
+
+// SchemaVersion is the schema version of a database migrated by this
+// version of the app.
+var SchemaVersion = len(migrations)
+
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// migrate applies the migrations a database lacks, each in its own
+// transaction. A database with data is first copied to a backup next to it
+// ("state.db.v3.bak" for a version 3 database).
+func migrate(db *sql.DB, dbPath string) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("%w (schema version %d, this version supports %d)", ErrSchemaTooNew, version, len(migrations))
+	}
+	if version == len(migrations) {
+		return nil
+	}
+
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		return err
+	}
+	if tables > 0 {
+		if err := backup(db, fmt.Sprintf("%s.v%d.bak", dbPath, version)); err != nil {
+			return fmt.Errorf("backing up state database: %w", err)
+		}
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := migrations[i].up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %w", i+1, migrations[i].description, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backup writes a copy of the database to path, replacing an older copy
+func backup(db *sql.DB, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// hasColumn reports whether a table has a column
+func hasColumn(tx *sql.Tx, table, column string) bool {
+	var n int
+	err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+	return err == nil && n > 0
+}
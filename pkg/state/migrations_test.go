@@ -0,0 +1,212 @@
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/paths"
+)
+
+// createLegacyDB writes a state database from a fixture of an old schema
+// in an isolated data directory and returns its path.
+func createLegacyDB(t *testing.T, fixture string) string {
+	t.Helper()
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(func() { os.Unsetenv("XDG_DATA_HOME") })
+
+	dbPath, err := paths.GetStatePath()
+	if err != nil {
+		t.Fatalf("GetStatePath() error = %v", err)
+	}
+	script, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(script)); err != nil {
+		t.Fatalf("%s: %v", fixture, err)
+	}
+	return dbPath
+}
+
+func userVersion(t *testing.T, s *Store) int {
+	t.Helper()
+	var v int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// schemaOf lists every table with its columns, and the other schema
+// objects by name
+func schemaOf(t *testing.T, s *Store) []string {
+	t.Helper()
+	rows, err := s.db.Query("SELECT type, name FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objects [][2]string
+	for rows.Next() {
+		var o [2]string
+		rows.Scan(&o[0], &o[1])
+		objects = append(objects, o)
+	}
+	rows.Close()
+
+	var schema []string
+	for _, o := range objects {
+		if o[0] != "table" {
+			schema = append(schema, o[0]+" "+o[1])
+			continue
+		}
+		var columns []string
+		crows, err := s.db.Query("SELECT name, type FROM pragma_table_info(?)", o[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for crows.Next() {
+			var name, typ string
+			crows.Scan(&name, &typ)
+			columns = append(columns, name+" "+typ)
+		}
+		crows.Close()
+		sort.Strings(columns)
+		schema = append(schema, "table "+o[1]+" ("+strings.Join(columns, ", ")+")")
+	}
+	return schema
+}
+
+func TestMigrateFresh(t *testing.T) {
+	store := createTestStore(t)
+	if v := userVersion(t, store); v != SchemaVersion {
+		t.Errorf("user_version = %d, want %d", v, SchemaVersion)
+	}
+
+	// Nothing to back up in a new database
+	dbPath, _ := paths.GetStatePath()
+	if backups, _ := filepath.Glob(dbPath + ".*.bak"); len(backups) != 0 {
+		t.Errorf("new database was backed up: %v", backups)
+	}
+}
+
+func TestMigrateLegacySchemas(t *testing.T) {
+	fresh := schemaOf(t, createTestStore(t))
+
+	fixtures, err := filepath.Glob("testdata/*.sql")
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			dbPath := createLegacyDB(t, fixture)
+			store, err := Open()
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer store.Close()
+
+			if v := userVersion(t, store); v != SchemaVersion {
+				t.Errorf("user_version = %d, want %d", v, SchemaVersion)
+			}
+			if got := schemaOf(t, store); strings.Join(got, "\n") != strings.Join(fresh, "\n") {
+				t.Errorf("migrated schema differs from a new database:\n%s\nwant:\n%s",
+					strings.Join(got, "\n"), strings.Join(fresh, "\n"))
+			}
+
+			// The data survives and a backup of the old database was taken
+			if got := store.Get("zoom"); got != "120" {
+				t.Errorf("zoom = %q", got)
+			}
+			if got := store.GetRecentHistory(10); len(got) != 1 || got[0] != "agni" {
+				t.Errorf("history = %v", got)
+			}
+			if !store.IsStarred(1) {
+				t.Error("starred article lost")
+			}
+			backups, _ := filepath.Glob(dbPath + ".v*.bak")
+			if len(backups) != 1 {
+				t.Fatalf("backups = %v, want one", backups)
+			}
+			backup, err := sql.Open("sqlite", backups[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer backup.Close()
+			var starred int
+			if err := backup.QueryRow("SELECT COUNT(*) FROM starred").Scan(&starred); err != nil || starred != 1 {
+				t.Errorf("backup has %d starred articles, error %v", starred, err)
+			}
+
+			// Every feature works on the migrated database
+			if err := store.Set("zoom", "150"); err != nil {
+				t.Errorf("Set() error = %v", err)
+			}
+			if err := store.TagArticle(1, "fire"); err != nil {
+				t.Errorf("TagArticle() error = %v", err)
+			}
+			if err := store.AddToCollection("Lesson 2", 1); err != nil {
+				t.Errorf("AddToCollection() error = %v", err)
+			}
+			if err := store.SaveNote(1, "agni", "mw", "sacrificial fire, Latin ignis"); err != nil {
+				t.Errorf("SaveNote() error = %v", err)
+			}
+			if notes, err := store.SearchNotes("ignis"); err != nil || len(notes) != 1 {
+				t.Errorf("SearchNotes() = %v, %v", notes, err)
+			}
+			if _, err := store.GradeCard(1, GradeGood, time.Now()); err != nil {
+				t.Errorf("GradeCard() error = %v", err)
+			}
+			if _, err := store.Sync(t.TempDir()); err != nil {
+				t.Errorf("Sync() error = %v", err)
+			}
+			if err := store.Export(new(strings.Builder)); err != nil {
+				t.Errorf("Export() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateOnce(t *testing.T) {
+	dbPath := createLegacyDB(t, "testdata/legacy_0_baseline.sql")
+	store, err := Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	store.Close()
+	os.Remove(dbPath + ".v0.bak")
+
+	// An up-to-date database is neither migrated nor backed up again
+	store, err = Open()
+	if err != nil {
+		t.Fatalf("second Open() error = %v", err)
+	}
+	defer store.Close()
+	if backups, _ := filepath.Glob(dbPath + ".*.bak"); len(backups) != 0 {
+		t.Errorf("up-to-date database was backed up: %v", backups)
+	}
+}
+
+func TestMigrateTooNew(t *testing.T) {
+	dbPath := createLegacyDB(t, "testdata/legacy_0_baseline.sql")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("PRAGMA user_version = 999")
+	db.Close()
+
+	if _, err := Open(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Open() error = %v, want ErrSchemaTooNew", err)
+	}
+}
@@ -0,0 +1,146 @@
+package state
+
+import (
+	"strings"
+	"time"
+)
+
+// Note is a personal note on a dictionary article, in markdown.
+type Note struct {
+	ArticleID int64
+	Word      string
+	DictCode  string
+	Text      string
+	Snippet   string // Matching excerpt with [brackets] around hits, set by SearchNotes
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// scanNote reads article_id, word, dict_code, text, created_at, updated_at
+// and, when withSnippet is set, a trailing snippet column
+func scanNote(scan func(dest ...interface{}) error, withSnippet bool) (Note, error) {
+	var n Note
+	dest := []interface{}{&n.ArticleID, &n.Word, &n.DictCode, &n.Text, &n.CreatedAt, &n.UpdatedAt}
+	if withSnippet {
+		dest = append(dest, &n.Snippet)
+	}
+	if err := scan(dest...); err != nil {
+		return Note{}, err
+	}
+	return n, nil
+}
+
+// SaveNote stores the note on an article and stars the article if it is
+// not starred yet. Saving blank text deletes the note; the article stays
+// starred.
+func (s *Store) SaveNote(articleID int64, word, dictCode, text string) error {
+	if strings.TrimSpace(text) == "" {
+		return s.DeleteNote(articleID)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO notes (article_id, word, dict_code, text) VALUES (?, ?, ?, ?)
+		ON CONFLICT(article_id) DO UPDATE SET text = excluded.text, updated_at = CURRENT_TIMESTAMP
+		WHERE text != excluded.text
+	`, articleID, word, dictCode, text)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO starred (article_id, word, dict_code) VALUES (?, ?, ?)
+	`, articleID, word, dictCode)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetNote returns the note on an article, if there is one.
+func (s *Store) GetNote(articleID int64) (Note, bool) {
+	row := s.db.QueryRow(`
+		SELECT article_id, word, dict_code, text, created_at, updated_at
+		FROM notes WHERE article_id = ?
+	`, articleID)
+	n, err := scanNote(row.Scan, false)
+	if err != nil {
+		return Note{}, false
+	}
+	return n, true
+}
+
+// DeleteNote removes the note on an article.
+func (s *Store) DeleteNote(articleID int64) error {
+	_, err := s.db.Exec("DELETE FROM notes WHERE article_id = ?", articleID)
+	return err
+}
+
+// Notes returns all notes, most recently updated first.
+func (s *Store) Notes() []Note {
+	rows, err := s.db.Query(`
+		SELECT article_id, word, dict_code, text, created_at, updated_at
+		FROM notes ORDER BY updated_at DESC, article_id
+	`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		if n, err := scanNote(rows.Scan, false); err == nil {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
+
+// SearchNotes finds notes containing every word of the query, matching
+// word prefixes ("sacri" finds "sacrifice"), best matches first.
+func (s *Store) SearchNotes(query string) ([]Note, error) {
+	ftsQuery := notesQuery(query)
+	if ftsQuery == "" {
+		return nil, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT n.article_id, n.word, n.dict_code, n.text, n.created_at, n.updated_at,
+			snippet(notes_fts, 0, '[', ']', '…', 12)
+		FROM notes_fts
+		JOIN notes n ON n.article_id = notes_fts.rowid
+		WHERE notes_fts MATCH ?
+		ORDER BY bm25(notes_fts), n.updated_at DESC
+		LIMIT 1000
+	`, ftsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		n, err := scanNote(rows.Scan, true)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// notesQuery turns words typed by the user into an FTS5 query matching
+// all of them as prefixes: sacred fire -> "sacred"* "fire"*
+func notesQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveNote(t *testing.T) {
+	store := createTestStore(t)
+
+	if _, ok := store.GetNote(7); ok {
+		t.Fatal("GetNote() found a note before saving")
+	}
+	if err := store.SaveNote(7, "agni", "mw", "Compare *Ignis*; see RV. i, 1"); err != nil {
+		t.Fatalf("SaveNote() error = %v", err)
+	}
+
+	n, ok := store.GetNote(7)
+	if !ok {
+		t.Fatal("GetNote() found no note after saving")
+	}
+	if n.Word != "agni" || n.DictCode != "mw" || n.Text != "Compare *Ignis*; see RV. i, 1" {
+		t.Errorf("GetNote() = %+v", n)
+	}
+	if time.Since(n.CreatedAt) > time.Hour || n.UpdatedAt.Before(n.CreatedAt) {
+		t.Errorf("timestamps = %v, %v", n.CreatedAt, n.UpdatedAt)
+	}
+
+	// A note stars its article
+	if !store.IsStarred(7) {
+		t.Error("SaveNote() did not star the article")
+	}
+
+	// Updating keeps one note
+	store.SaveNote(7, "agni", "mw", "Fire god")
+	if notes := store.Notes(); len(notes) != 1 || notes[0].Text != "Fire god" {
+		t.Errorf("Notes() after update = %+v", notes)
+	}
+
+	// Blank text deletes the note but keeps the star
+	if err := store.SaveNote(7, "agni", "mw", "  "); err != nil {
+		t.Fatalf("SaveNote() blank error = %v", err)
+	}
+	if _, ok := store.GetNote(7); ok {
+		t.Error("blank SaveNote() kept the note")
+	}
+	if !store.IsStarred(7) {
+		t.Error("deleting the note unstarred the article")
+	}
+}
+
+func TestSearchNotes(t *testing.T) {
+	store := createTestStore(t)
+	store.SaveNote(1, "agni", "mw", "The sacrificial fire; cf. Latin ignis")
+	store.SaveNote(2, "soma", "mw", "Pressed at the sacrifice")
+	store.SaveNote(3, "yoga", "ap90", "Patañjali's definition: citta-vṛtti-nirodha")
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"sacri", 2},
+		{"sacrificial fire", 1},
+		{"patañjali", 1},
+		{"vṛtti", 1},
+		{`"quoted`, 0},
+		{"", 0},
+		{"water", 0},
+	}
+	for _, tt := range tests {
+		notes, err := store.SearchNotes(tt.query)
+		if err != nil {
+			t.Fatalf("SearchNotes(%q) error = %v", tt.query, err)
+		}
+		if len(notes) != tt.want {
+			t.Errorf("SearchNotes(%q) got %d notes, want %d", tt.query, len(notes), tt.want)
+		}
+	}
+
+	notes, _ := store.SearchNotes("ignis")
+	if len(notes) != 1 || notes[0].Snippet != "The sacrificial fire; cf. Latin [ignis]" {
+		t.Errorf("SearchNotes(ignis) = %+v", notes)
+	}
+
+	// Deleted notes leave the index
+	store.DeleteNote(1)
+	if notes, _ := store.SearchNotes("ignis"); len(notes) != 0 {
+		t.Errorf("SearchNotes() after delete = %+v", notes)
+	}
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/licht1stein/sanskrit-upaya/pkg/paths"
+)
+
+// DefaultProfile is the profile used when none is chosen. Its state is the
+// database from before profiles existed.
+const DefaultProfile = "default"
+
+// maxProfileName is the longest profile name allowed, in characters
+const maxProfileName = 40
+
+// ErrInvalidProfile is returned for profile names that can't be used as a
+// directory name.
+var ErrInvalidProfile = errors.New("profile names may only contain letters, digits, spaces, '-' and '_'")
+
+// ErrProfileNotFound is returned when deleting a profile that doesn't exist.
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ValidateProfile checks that a profile name is usable: letters, digits,
+// spaces, '-' and '_', not starting or ending with a space.
+func ValidateProfile(name string) error {
+	if name == "" || name != strings.TrimSpace(name) || len([]rune(name)) > maxProfileName {
+		return ErrInvalidProfile
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_' {
+			return ErrInvalidProfile
+		}
+	}
+	return nil
+}
+
+// profileStatePath returns the path of a profile's state database
+func profileStatePath(profile string) (string, error) {
+	if err := ValidateProfile(profile); err != nil {
+		return "", fmt.Errorf("%w: %q", err, profile)
+	}
+	if strings.EqualFold(profile, DefaultProfile) {
+		return paths.GetStatePath()
+	}
+	return paths.GetProfileStatePath(profile)
+}
+
+// Profiles lists the profiles with a state database: the default profile
+// first, then the others by name.
+func Profiles() ([]string, error) {
+	profilesDir, err := paths.GetProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() || ValidateProfile(e.Name()) != nil || strings.EqualFold(e.Name(), DefaultProfile) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(profilesDir, e.Name(), "state.db")); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// DeleteProfile removes a profile and all its data. The default profile
+// can't be deleted.
+func DeleteProfile(name string) error {
+	if err := ValidateProfile(name); err != nil {
+		return err
+	}
+	if strings.EqualFold(name, DefaultProfile) {
+		return errors.New("the default profile can't be deleted")
+	}
+	profilesDir, err := paths.GetProfilesDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(profilesDir, name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return ErrProfileNotFound
+	}
+	return os.RemoveAll(dir)
+}
+
+// LastProfile returns the profile remembered with SetLastProfile, or the
+// default profile if none was or it has been deleted since.
+func LastProfile() string {
+	dataDir, err := paths.GetDataDir()
+	if err != nil {
+		return DefaultProfile
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, "last_profile"))
+	if err != nil {
+		return DefaultProfile
+	}
+	name := strings.TrimSpace(string(data))
+	profiles, err := Profiles()
+	if err != nil {
+		return DefaultProfile
+	}
+	for _, p := range profiles {
+		if p == name {
+			return name
+		}
+	}
+	return DefaultProfile
+}
+
+// SetLastProfile remembers the profile to open at the next start.
+func SetLastProfile(name string) error {
+	if err := ValidateProfile(name); err != nil {
+		return err
+	}
+	dataDir, err := paths.GetDataDir()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dataDir, "last_profile"), []byte(name+"\n"), 0644)
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestOpenProfile(t *testing.T) {
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	def, err := Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer def.Close()
+	asha, err := OpenProfile("Asha K")
+	if err != nil {
+		t.Fatalf("OpenProfile() error = %v", err)
+	}
+	defer asha.Close()
+
+	// Profiles keep their data apart
+	def.Set("zoom", "120")
+	def.StarArticle(1, "agni", "mw")
+	asha.AddHistory("soma")
+	if got := asha.Get("zoom"); got != "" {
+		t.Errorf("profile sees default profile's setting: %q", got)
+	}
+	if asha.IsStarred(1) {
+		t.Error("profile sees default profile's star")
+	}
+	if got := def.GetRecentHistory(10); len(got) != 0 {
+		t.Errorf("default profile sees profile's history: %v", got)
+	}
+	if def.Profile() != DefaultProfile || asha.Profile() != "Asha K" {
+		t.Errorf("Profile() = %q, %q", def.Profile(), asha.Profile())
+	}
+
+	profiles, err := Profiles()
+	if err != nil {
+		t.Fatalf("Profiles() error = %v", err)
+	}
+	if len(profiles) != 2 || profiles[0] != DefaultProfile || profiles[1] != "Asha K" {
+		t.Errorf("Profiles() = %v", profiles)
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	for _, name := range []string{"asha", "Asha K", "lab-2_b", "अनन्त"} {
+		if err := ValidateProfile(name); err != nil {
+			t.Errorf("ValidateProfile(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", " asha", "../asha", "a/b", "a.b", "asha\n"} {
+		if err := ValidateProfile(name); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("ValidateProfile(%q) error = %v, want ErrInvalidProfile", name, err)
+		}
+		if _, err := OpenProfile(name); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("OpenProfile(%q) error = %v, want ErrInvalidProfile", name, err)
+		}
+	}
+}
+
+func TestDeleteProfile(t *testing.T) {
+	os.Setenv("XDG_DATA_HOME", t.TempDir())
+	defer os.Unsetenv("XDG_DATA_HOME")
+
+	store, err := OpenProfile("asha")
+	if err != nil {
+		t.Fatalf("OpenProfile() error = %v", err)
+	}
+	store.Close()
+	if err := SetLastProfile("asha"); err != nil {
+		t.Fatalf("SetLastProfile() error = %v", err)
+	}
+	if got := LastProfile(); got != "asha" {
+		t.Errorf("LastProfile() = %q, want asha", got)
+	}
+
+	if err := DeleteProfile("asha"); err != nil {
+		t.Fatalf("DeleteProfile() error = %v", err)
+	}
+	if profiles, _ := Profiles(); len(profiles) != 1 {
+		t.Errorf("Profiles() after delete = %v", profiles)
+	}
+	if got := LastProfile(); got != DefaultProfile {
+		t.Errorf("LastProfile() after delete = %q, want default", got)
+	}
+	if err := DeleteProfile("asha"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("second DeleteProfile() error = %v, want ErrProfileNotFound", err)
+	}
+	if err := DeleteProfile(DefaultProfile); err == nil {
+		t.Error("DeleteProfile(default) succeeded")
+	}
+}
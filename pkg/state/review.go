@@ -0,0 +1,237 @@
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+// Review errors
+var (
+	ErrInvalidGrade = errors.New("grade must be between 0 and 5")
+	ErrNotStarred   = errors.New("article is not starred")
+)
+
+// Grade rates how well a card was recalled, on the SM-2 scale from 0
+// (complete blackout) to 5 (perfect recall). Grades below 3 are lapses.
+type Grade int
+
+// The four grades offered when reviewing.
+const (
+	GradeAgain Grade = 1 // Forgotten
+	GradeHard  Grade = 3 // Recalled with serious difficulty
+	GradeGood  Grade = 4 // Recalled after some hesitation
+	GradeEasy  Grade = 5 // Recalled at once
+)
+
+const (
+	initialEase = 2.5
+	minimumEase = 1.3
+	// A forgotten card comes back within the same session
+	relearnDelay = 10 * time.Minute
+)
+
+// Card is a starred article studied as a flashcard.
+type Card struct {
+	ArticleID   int64
+	Word        string
+	DictCode    string
+	Ease        float64 // SM-2 easiness factor
+	Interval    int     // Days until the next review, 0 while relearning
+	Repetitions int     // Successful reviews in a row
+	Lapses      int     // Times the card was forgotten after being learned
+	Due         time.Time
+	New         bool // Never reviewed
+}
+
+// Review is an entry of the review log.
+type Review struct {
+	ArticleID  int64
+	Grade      Grade
+	Interval   int     // Interval scheduled by the review, in days
+	Ease       float64 // Easiness factor after the review
+	ReviewedAt time.Time
+}
+
+// Schedule applies the SM-2 algorithm to a card graded at now and returns
+// the card with its new easiness, interval and due date.
+func Schedule(c Card, g Grade, now time.Time) Card {
+	if c.New || c.Ease == 0 {
+		c.Ease = initialEase
+	}
+	c.New = false
+
+	if g < 3 {
+		if c.Repetitions > 0 {
+			c.Lapses++
+		}
+		c.Repetitions = 0
+		c.Interval = 0
+		c.Due = now.Add(relearnDelay)
+	} else {
+		c.Repetitions++
+		switch c.Repetitions {
+		case 1:
+			c.Interval = 1
+		case 2:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Round(float64(c.Interval) * c.Ease))
+		}
+		c.Due = now.AddDate(0, 0, c.Interval)
+	}
+
+	q := float64(5 - g)
+	c.Ease += 0.1 - q*(0.08+q*0.02)
+	if c.Ease < minimumEase {
+		c.Ease = minimumEase
+	}
+	return c
+}
+
+// DueCards returns the cards to review at now: cards due by then, earliest
+// first, followed by starred articles never reviewed, oldest star first.
+// New cards are limited to newPerDay, less those introduced since the
+// start of now's day.
+func (s *Store) DueCards(now time.Time, newPerDay int) []Card {
+	rows, err := s.db.Query(`
+		SELECT s.article_id, s.word, s.dict_code, c.ease, c.interval_days, c.repetitions, c.lapses, c.due
+		FROM cards c
+		JOIN starred s ON s.article_id = c.article_id
+		WHERE c.due <= ?
+		ORDER BY c.due, s.article_id
+	`, now.Unix())
+	if err != nil {
+		return nil
+	}
+	var cards []Card
+	for rows.Next() {
+		var c Card
+		var due int64
+		if err := rows.Scan(&c.ArticleID, &c.Word, &c.DictCode, &c.Ease, &c.Interval, &c.Repetitions, &c.Lapses, &due); err == nil {
+			c.Due = time.Unix(due, 0)
+			cards = append(cards, c)
+		}
+	}
+	rows.Close()
+
+	year, month, day := now.Date()
+	startOfDay := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	var introduced int
+	s.db.QueryRow("SELECT COUNT(*) FROM cards WHERE created_at >= ?", startOfDay.Unix()).Scan(&introduced)
+	if newPerDay -= introduced; newPerDay <= 0 {
+		return cards
+	}
+
+	rows, err = s.db.Query(`
+		SELECT article_id, word, dict_code FROM starred
+		WHERE article_id NOT IN (SELECT article_id FROM cards)
+		ORDER BY created_at, id
+		LIMIT ?
+	`, newPerDay)
+	if err != nil {
+		return cards
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := Card{Ease: initialEase, Due: now, New: true}
+		if err := rows.Scan(&c.ArticleID, &c.Word, &c.DictCode); err == nil {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// ReviewCounts returns how many cards are due at now and how many starred
+// articles have never been reviewed.
+func (s *Store) ReviewCounts(now time.Time) (due, unseen int) {
+	s.db.QueryRow(`
+		SELECT COUNT(*) FROM cards c JOIN starred s ON s.article_id = c.article_id
+		WHERE c.due <= ?
+	`, now.Unix()).Scan(&due)
+	s.db.QueryRow(`
+		SELECT COUNT(*) FROM starred WHERE article_id NOT IN (SELECT article_id FROM cards)
+	`).Scan(&unseen)
+	return due, unseen
+}
+
+// GradeCard records a review of a starred article at now, schedules its
+// next review and returns the updated card.
+func (s *Store) GradeCard(articleID int64, g Grade, now time.Time) (Card, error) {
+	if g < 0 || g > 5 {
+		return Card{}, ErrInvalidGrade
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Card{}, err
+	}
+	defer tx.Rollback()
+
+	c := Card{ArticleID: articleID}
+	err = tx.QueryRow("SELECT word, dict_code FROM starred WHERE article_id = ?", articleID).Scan(&c.Word, &c.DictCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Card{}, ErrNotStarred
+	}
+	if err != nil {
+		return Card{}, err
+	}
+
+	var due int64
+	err = tx.QueryRow(`
+		SELECT ease, interval_days, repetitions, lapses, due FROM cards WHERE article_id = ?
+	`, articleID).Scan(&c.Ease, &c.Interval, &c.Repetitions, &c.Lapses, &due)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.New = true
+	} else if err != nil {
+		return Card{}, err
+	}
+
+	c = Schedule(c, g, now)
+	_, err = tx.Exec(`
+		INSERT INTO cards (article_id, ease, interval_days, repetitions, lapses, due, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(article_id) DO UPDATE SET
+			ease = excluded.ease, interval_days = excluded.interval_days,
+			repetitions = excluded.repetitions, lapses = excluded.lapses, due = excluded.due
+	`, articleID, c.Ease, c.Interval, c.Repetitions, c.Lapses, c.Due.Unix(), now.Unix())
+	if err != nil {
+		return Card{}, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO review_log (article_id, grade, interval_days, ease, reviewed_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, articleID, int(g), c.Interval, c.Ease, now.Unix())
+	if err != nil {
+		return Card{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Card{}, err
+	}
+	return c, nil
+}
+
+// ReviewLog returns the reviews of an article, oldest first.
+func (s *Store) ReviewLog(articleID int64) []Review {
+	rows, err := s.db.Query(`
+		SELECT article_id, grade, interval_days, ease, reviewed_at FROM review_log
+		WHERE article_id = ? ORDER BY reviewed_at, id
+	`, articleID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var reviews []Review
+	for rows.Next() {
+		var r Review
+		var at int64
+		if err := rows.Scan(&r.ArticleID, &r.Grade, &r.Interval, &r.Ease, &at); err == nil {
+			r.ReviewedAt = time.Unix(at, 0)
+			reviews = append(reviews, r)
+		}
+	}
+	return reviews
+}
@@ -0,0 +1,121 @@
+package state
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := Card{New: true}
+
+	// Good, Good, Good: 1 day, 6 days, then interval × ease
+	wantIntervals := []int{1, 6, 15}
+	for i, want := range wantIntervals {
+		c = Schedule(c, GradeGood, now)
+		if c.Interval != want {
+			t.Fatalf("review %d: Interval = %d, want %d", i+1, c.Interval, want)
+		}
+		if !c.Due.Equal(now.AddDate(0, 0, want)) {
+			t.Errorf("review %d: Due = %v", i+1, c.Due)
+		}
+	}
+	if c.Ease != initialEase || c.Repetitions != 3 || c.New {
+		t.Errorf("after three Good: %+v", c)
+	}
+
+	// Forgetting restarts the card within the session and lowers its ease
+	c = Schedule(c, GradeAgain, now)
+	if c.Interval != 0 || c.Repetitions != 0 || c.Lapses != 1 {
+		t.Errorf("after Again: %+v", c)
+	}
+	if !c.Due.Equal(now.Add(relearnDelay)) {
+		t.Errorf("after Again: Due = %v", c.Due)
+	}
+	if math.Abs(c.Ease-1.96) > 1e-9 {
+		t.Errorf("after Again: Ease = %v, want 1.96", c.Ease)
+	}
+
+	// Easy raises the ease; ease never drops below the minimum
+	if e := Schedule(Card{New: true}, GradeEasy, now).Ease; math.Abs(e-2.6) > 1e-9 {
+		t.Errorf("Easy ease = %v, want 2.6", e)
+	}
+	low := Card{Ease: minimumEase, Repetitions: 3, Interval: 10}
+	if e := Schedule(low, GradeHard, now).Ease; e != minimumEase {
+		t.Errorf("Hard at minimum ease = %v", e)
+	}
+}
+
+func TestDueCards(t *testing.T) {
+	store := createTestStore(t)
+	now := time.Now()
+
+	store.StarArticle(1, "agni", "mw")
+	store.StarArticle(2, "soma", "mw")
+	store.StarArticle(3, "yoga", "ap90")
+
+	// Everything starred starts as a new card, up to the daily limit
+	cards := store.DueCards(now, 2)
+	if len(cards) != 2 || !cards[0].New || !cards[1].New {
+		t.Fatalf("DueCards() = %+v, want 2 new cards", cards)
+	}
+
+	// Reviewed cards leave the queue until due and use up the new-card limit
+	if _, err := store.GradeCard(1, GradeGood, now); err != nil {
+		t.Fatalf("GradeCard() error = %v", err)
+	}
+	cards = store.DueCards(now, 2)
+	if len(cards) != 1 || cards[0].ArticleID == 1 {
+		t.Errorf("DueCards() after review = %+v", cards)
+	}
+	if due, unseen := store.ReviewCounts(now); due != 0 || unseen != 2 {
+		t.Errorf("ReviewCounts() = %d, %d, want 0, 2", due, unseen)
+	}
+
+	// A day later the card is due again, ahead of new cards
+	cards = store.DueCards(now.AddDate(0, 0, 1), 20)
+	if len(cards) != 3 || cards[0].ArticleID != 1 || cards[0].New || cards[0].Interval != 1 {
+		t.Errorf("DueCards() next day = %+v", cards)
+	}
+
+	// Unstarred articles are not studied
+	store.UnstarArticle(1)
+	if due, _ := store.ReviewCounts(now.AddDate(0, 0, 1)); due != 0 {
+		t.Errorf("ReviewCounts() after unstar = %d due", due)
+	}
+}
+
+func TestGradeCard(t *testing.T) {
+	store := createTestStore(t)
+	now := time.Now().Truncate(time.Second)
+
+	if _, err := store.GradeCard(9, GradeGood, now); !errors.Is(err, ErrNotStarred) {
+		t.Errorf("GradeCard() unstarred error = %v, want ErrNotStarred", err)
+	}
+	store.StarArticle(9, "dharma", "mw")
+	if _, err := store.GradeCard(9, 6, now); !errors.Is(err, ErrInvalidGrade) {
+		t.Errorf("GradeCard() grade 6 error = %v, want ErrInvalidGrade", err)
+	}
+
+	store.GradeCard(9, GradeGood, now)
+	c, err := store.GradeCard(9, GradeAgain, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GradeCard() error = %v", err)
+	}
+	if c.Word != "dharma" || c.Lapses != 1 || c.Interval != 0 {
+		t.Errorf("GradeCard() = %+v", c)
+	}
+
+	log := store.ReviewLog(9)
+	if len(log) != 2 {
+		t.Fatalf("ReviewLog() = %+v, want 2 reviews", log)
+	}
+	if log[0].Grade != GradeGood || log[0].Interval != 1 || !log[0].ReviewedAt.Equal(now) {
+		t.Errorf("ReviewLog()[0] = %+v", log[0])
+	}
+	if log[1].Grade != GradeAgain || log[1].Ease != c.Ease {
+		t.Errorf("ReviewLog()[1] = %+v", log[1])
+	}
+}
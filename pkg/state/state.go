@@ -5,69 +5,45 @@ package state
 import (
 	"database/sql"
 
-	"github.com/licht1stein/sanskrit-upaya/pkg/paths"
 	_ "modernc.org/sqlite"
 )
 
 // Store provides persistent key-value storage.
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	profile string
 }
 
-// Open opens or creates the state database.
+// Open opens or creates the state database of the default profile.
 func Open() (*Store, error) {
-	dbPath, err := paths.GetStatePath()
-	if err != nil {
-		return nil, err
-	}
+	return OpenProfile(DefaultProfile)
+}
 
-	db, err := sql.Open("sqlite", dbPath)
+// OpenProfile opens or creates the state database of a profile.
+func OpenProfile(profile string) (*Store, error) {
+	dbPath, err := profileStatePath(profile)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create tables if not exists
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS settings (
-			key TEXT PRIMARY KEY,
-			value TEXT NOT NULL
-		)
-	`)
+	// Periodic sync writes from the background; wait for locks instead of failing
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
-		db.Close()
 		return nil, err
 	}
 
-	// Search history table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS history (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			query TEXT NOT NULL UNIQUE,
-			count INTEGER DEFAULT 1,
-			last_used DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
+	// Bring the schema up to date, backing up the database first
+	if err := migrate(db, dbPath); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	// Starred articles table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS starred (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			article_id INTEGER NOT NULL UNIQUE,
-			word TEXT NOT NULL,
-			dict_code TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		db.Close()
-		return nil, err
-	}
+	return &Store{db: db, profile: profile}, nil
+}
 
-	return &Store{db: db}, nil
+// Profile returns the name of the store's profile.
+func (s *Store) Profile() string {
+	return s.profile
 }
 
 // Close closes the database connection.
@@ -88,8 +64,9 @@ func (s *Store) Get(key string) string {
 // Set stores a key-value pair.
 func (s *Store) Set(key, value string) error {
 	_, err := s.db.Exec(`
-		INSERT INTO settings (key, value) VALUES (?, ?)
-		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+		WHERE value != excluded.value
 	`, key, value)
 	return err
 }
@@ -130,17 +107,20 @@ func (s *Store) AddHistory(query string) error {
 		return err
 	}
 
-	// Clean up entries beyond the 1000 most recent
-	_, err = tx.Exec(`
+	if err := trimHistory(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// trimHistory cleans up entries beyond the 1000 most recent
+func trimHistory(tx *sql.Tx) error {
+	_, err := tx.Exec(`
 		DELETE FROM history WHERE id NOT IN (
 			SELECT id FROM history ORDER BY last_used DESC LIMIT 1000
 		)
 	`)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return err
 }
 
 // GetRecentHistory returns the most recent history entries.
@@ -174,9 +154,33 @@ func (s *Store) StarArticle(articleID int64, word, dictCode string) error {
 	return err
 }
 
-// UnstarArticle removes an article from starred.
+// UnstarArticle removes an article from starred, along with its tags and
+// collection memberships.
 func (s *Store) UnstarArticle(articleID int64) error {
-	_, err := s.db.Exec("DELETE FROM starred WHERE article_id = ?", articleID)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := unstar(tx, articleID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// unstar removes a starred article, its tags and collection memberships
+func unstar(tx *sql.Tx, articleID int64) error {
+	for _, stmt := range []string{
+		"DELETE FROM starred WHERE article_id = ?",
+		"DELETE FROM article_tags WHERE article_id = ?",
+		"DELETE FROM collection_articles WHERE article_id = ?",
+	} {
+		if _, err := tx.Exec(stmt, articleID); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM article_tags)")
 	return err
 }
 
@@ -214,3 +218,65 @@ func (s *Store) GetStarredArticles() []StarredArticle {
 	}
 	return results
 }
+
+// NavEntry is one step of the back/forward navigation history.
+type NavEntry struct {
+	Query     string
+	Mode      string
+	ArticleID int64 // Article being viewed, 0 if none
+}
+
+// SaveNavigation replaces the stored navigation history.
+// current is the index of the entry being viewed.
+func (s *Store) SaveNavigation(entries []NavEntry, current int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM navigation"); err != nil {
+		return err
+	}
+	for i, e := range entries {
+		_, err := tx.Exec(`
+			INSERT INTO navigation (position, query, mode, article_id, current)
+			VALUES (?, ?, ?, ?, ?)
+		`, i, e.Query, e.Mode, e.ArticleID, i == current)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LoadNavigation returns the stored navigation history and the index of
+// the current entry. The index is -1 when the history is empty.
+func (s *Store) LoadNavigation() ([]NavEntry, int) {
+	rows, err := s.db.Query(`
+		SELECT query, mode, article_id, current FROM navigation
+		ORDER BY position
+	`)
+	if err != nil {
+		return nil, -1
+	}
+	defer rows.Close()
+
+	var entries []NavEntry
+	current := -1
+	for rows.Next() {
+		var e NavEntry
+		var isCurrent bool
+		if err := rows.Scan(&e.Query, &e.Mode, &e.ArticleID, &isCurrent); err == nil {
+			if isCurrent {
+				current = len(entries)
+			}
+			entries = append(entries, e)
+		}
+	}
+	if current == -1 && len(entries) > 0 {
+		current = len(entries) - 1
+	}
+	return entries, current
+}
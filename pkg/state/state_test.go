@@ -430,3 +430,43 @@ func TestEmptyResults(t *testing.T) {
 		t.Errorf("GetStarredArticles on empty store = %v, want nil or empty", starred)
 	}
 }
+
+func TestSaveLoadNavigation(t *testing.T) {
+	store := createTestStore(t)
+
+	entries, current := store.LoadNavigation()
+	if len(entries) != 0 || current != -1 {
+		t.Fatalf("LoadNavigation() on empty store = %v, %d", entries, current)
+	}
+
+	want := []NavEntry{
+		{Query: "agni", Mode: "Exact", ArticleID: 12},
+		{Query: "fire", Mode: "Full-text"},
+		{Query: "dharma", Mode: "Prefix", ArticleID: 7},
+	}
+	if err := store.SaveNavigation(want, 1); err != nil {
+		t.Fatalf("SaveNavigation() error = %v", err)
+	}
+
+	entries, current = store.LoadNavigation()
+	if current != 1 {
+		t.Errorf("current = %d, want 1", current)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entries[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	// Saving again replaces the previous history
+	if err := store.SaveNavigation(want[:1], 0); err != nil {
+		t.Fatalf("SaveNavigation() error = %v", err)
+	}
+	entries, current = store.LoadNavigation()
+	if len(entries) != 1 || current != 0 {
+		t.Errorf("after replace got %v, %d; want 1 entry at 0", entries, current)
+	}
+}
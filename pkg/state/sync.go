@@ -0,0 +1,543 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Synced items are starred articles with their tags ("star:<article id>")
+// and notes ("note:<article id>").
+const (
+	starEntity = "star"
+	noteEntity = "note"
+)
+
+// syncLogExt is the extension of the change logs in a sync folder
+const syncLogExt = ".jsonl"
+
+// A device's change log grows by a line for every change. Once it has
+// over compactLines lines, and twice as many as there are synced items,
+// it is rewritten with only the last version of each item. Deleted items
+// keep a line each, so a log stays within a few hundred bytes per item
+// ever synced, plus compactLines lines.
+const compactLines = 1000
+
+// logHeader is the entity of the line a compacted log starts with. Its
+// time tells one compaction from the next; other devices read a log from
+// the start when its first line changes.
+const logHeader = "log"
+
+// SyncResult tells what a sync exchanged.
+type SyncResult struct {
+	Sent     int // Local changes written to this device's log
+	Received int // Changes from other devices applied here
+}
+
+// change is one line of a device's change log. Value is the item as JSON,
+// or empty when the item was deleted.
+type change struct {
+	Time   int64           `json:"time"` // Unix nanoseconds
+	Device string          `json:"device"`
+	Entity string          `json:"entity"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// newer reports whether the change wins over a version made at time by
+// device: later changes win, ties go to the greater device ID
+func (c change) newer(time int64, device string) bool {
+	if c.Time != time {
+		return c.Time > time
+	}
+	return c.Device > device
+}
+
+// version is the last known state of a synced item
+type version struct {
+	Value  string
+	Time   int64
+	Device string
+}
+
+type starValue struct {
+	Word     string   `json:"word"`
+	DictCode string   `json:"dict_code"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+type noteValue struct {
+	Word     string `json:"word"`
+	DictCode string `json:"dict_code"`
+	Text     string `json:"text"`
+}
+
+// DeviceID returns the random ID naming this device's change log,
+// created on first use.
+func (s *Store) DeviceID() (string, error) {
+	var id string
+	err := s.db.QueryRow("SELECT id FROM sync_device LIMIT 1").Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id = hex.EncodeToString(b)
+	if _, err := s.db.Exec("INSERT INTO sync_device (id) VALUES (?)", id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Sync exchanges starred articles, their tags and notes with other devices
+// through a shared folder, such as one kept in sync by Syncthing or
+// Dropbox. Each device appends its changes to its own log in the folder
+// and reads the others' logs; no server is involved.
+//
+// Local changes are picked up by comparing the stars and notes with their
+// last synced versions, and are stamped with the time they were made. When
+// two devices change the same item, the later change wins, ties going to
+// the greater device ID.
+func (s *Store) Sync(dir string) (SyncResult, error) {
+	var result SyncResult
+	device, err := s.DeviceID()
+	if err != nil {
+		return result, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return result, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	local, err := syncedItems(tx, 0)
+	if err != nil {
+		return result, err
+	}
+	versions, err := syncVersions(tx)
+	if err != nil {
+		return result, err
+	}
+	changed, err := changeTimes(tx)
+	if err != nil {
+		return result, err
+	}
+
+	// A folder without this device's log gets all known versions, so a
+	// new or emptied folder catches up
+	logPath := filepath.Join(dir, device+syncLogExt)
+	_, statErr := os.Stat(logPath)
+	fresh := os.IsNotExist(statErr)
+
+	now := time.Now().UnixNano()
+	var changes []change
+	for _, entity := range unionKeys(local, versions) {
+		value := local[entity]
+		if v, known := versions[entity]; known && v.Value == value {
+			if fresh {
+				changes = append(changes, change{Time: v.Time, Device: v.Device, Entity: entity, Value: rawValue(value)})
+			}
+			continue
+		}
+		t, ok := changed[entity]
+		if !ok {
+			t = now // Changed before change times were kept
+		}
+		if v, known := versions[entity]; known && t <= v.Time {
+			// The change replaced that version, whatever the clocks say
+			t = v.Time + 1
+		}
+		changes = append(changes, change{Time: t, Device: device, Entity: entity, Value: rawValue(value)})
+		if err := setVersion(tx, entity, version{Value: value, Time: t, Device: device}); err != nil {
+			return result, err
+		}
+		versions[entity] = version{Value: value, Time: t, Device: device}
+		result.Sent++
+	}
+	if err := appendChanges(logPath, changes); err != nil {
+		return result, err
+	}
+	if err := compactLog(logPath, device, versions); err != nil {
+		return result, err
+	}
+
+	// Merge the other devices' logs from where the last sync stopped
+	files, err := filepath.Glob(filepath.Join(dir, "*"+syncLogExt))
+	if err != nil {
+		return result, err
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		name := filepath.Base(path)
+		if name == device+syncLogExt {
+			continue
+		}
+		var offset int64
+		var head string
+		tx.QueryRow("SELECT offset, head FROM sync_files WHERE name = ?", name).Scan(&offset, &head)
+		remote, next, head, err := readChanges(path, offset, head)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, c := range remote {
+			v, known := versions[c.Entity]
+			if known && !c.newer(v.Time, v.Device) {
+				continue
+			}
+			value, err := applyChange(tx, c)
+			if err != nil {
+				return result, fmt.Errorf("%s: %w", name, err)
+			}
+			// The version records the item as stored here, which may differ
+			// in detail (a tag's spelling) from the change
+			v = version{Value: value, Time: c.Time, Device: c.Device}
+			if err := setVersion(tx, c.Entity, v); err != nil {
+				return result, err
+			}
+			versions[c.Entity] = v
+			result.Received++
+		}
+		_, err = tx.Exec(`
+			INSERT INTO sync_files (name, offset, head) VALUES (?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET offset = excluded.offset, head = excluded.head
+		`, name, next, head)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+// syncedItems returns the current value of every synced item, or only of
+// the items of one article when articleID is not 0
+func syncedItems(tx *sql.Tx, articleID int64) (map[string]string, error) {
+	items := make(map[string]string)
+
+	stars := make(map[int64]*starValue)
+	var order []int64
+	rows, err := tx.Query("SELECT article_id, word, dict_code FROM starred WHERE ?1 = 0 OR article_id = ?1", articleID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var sv starValue
+		if err := rows.Scan(&id, &sv.Word, &sv.DictCode); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stars[id] = &sv
+		order = append(order, id)
+	}
+	rows.Close()
+
+	rows, err = tx.Query(`
+		SELECT at.article_id, t.name FROM article_tags at
+		JOIN tags t ON t.id = at.tag_id
+		WHERE ?1 = 0 OR at.article_id = ?1
+		ORDER BY t.name COLLATE NOCASE
+	`, articleID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if sv := stars[id]; sv != nil {
+			sv.Tags = append(sv.Tags, tag)
+		}
+	}
+	rows.Close()
+
+	for _, id := range order {
+		b, _ := json.Marshal(stars[id])
+		items[entityKey(starEntity, id)] = string(b)
+	}
+
+	rows, err = tx.Query("SELECT article_id, word, dict_code, text FROM notes WHERE ?1 = 0 OR article_id = ?1", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var nv noteValue
+		if err := rows.Scan(&id, &nv.Word, &nv.DictCode, &nv.Text); err != nil {
+			return nil, err
+		}
+		b, _ := json.Marshal(nv)
+		items[entityKey(noteEntity, id)] = string(b)
+	}
+	return items, rows.Err()
+}
+
+func syncVersions(tx *sql.Tx) (map[string]version, error) {
+	rows, err := tx.Query("SELECT entity, value, time, device FROM sync_versions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]version)
+	for rows.Next() {
+		var entity string
+		var v version
+		if err := rows.Scan(&entity, &v.Value, &v.Time, &v.Device); err != nil {
+			return nil, err
+		}
+		versions[entity] = v
+	}
+	return versions, rows.Err()
+}
+
+// changeTimes returns when each synced item last changed here
+func changeTimes(tx *sql.Tx) (map[string]int64, error) {
+	rows, err := tx.Query("SELECT entity, time FROM sync_changed")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make(map[string]int64)
+	for rows.Next() {
+		var entity string
+		var t int64
+		if err := rows.Scan(&entity, &t); err != nil {
+			return nil, err
+		}
+		times[entity] = t
+	}
+	return times, rows.Err()
+}
+
+func setVersion(tx *sql.Tx, entity string, v version) error {
+	_, err := tx.Exec(`
+		INSERT INTO sync_versions (entity, value, time, device) VALUES (?, ?, ?, ?)
+		ON CONFLICT(entity) DO UPDATE SET value = excluded.value, time = excluded.time, device = excluded.device
+	`, entity, v.Value, v.Time, v.Device)
+	return err
+}
+
+// applyChange stores a change from another device and returns the item's
+// resulting value
+func applyChange(tx *sql.Tx, c change) (string, error) {
+	kind, idStr, _ := strings.Cut(c.Entity, ":")
+	articleID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid sync entity %q", c.Entity)
+	}
+	deleted := len(c.Value) == 0 || string(c.Value) == "null"
+
+	switch kind {
+	case starEntity:
+		if deleted {
+			err = unstar(tx, articleID)
+			break
+		}
+		var sv starValue
+		if err := json.Unmarshal(c.Value, &sv); err != nil {
+			return "", err
+		}
+		err = applyStar(tx, articleID, sv)
+	case noteEntity:
+		if deleted {
+			_, err = tx.Exec("DELETE FROM notes WHERE article_id = ?", articleID)
+			break
+		}
+		var nv noteValue
+		if err := json.Unmarshal(c.Value, &nv); err != nil {
+			return "", err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO notes (article_id, word, dict_code, text) VALUES (?, ?, ?, ?)
+			ON CONFLICT(article_id) DO UPDATE SET text = excluded.text, updated_at = CURRENT_TIMESTAMP
+			WHERE text != excluded.text
+		`, articleID, nv.Word, nv.DictCode, nv.Text)
+	default:
+		// Items of a newer version of the app are left alone
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	items, err := syncedItems(tx, articleID)
+	if err != nil {
+		return "", err
+	}
+	return items[c.Entity], nil
+}
+
+// applyStar stars an article with exactly the given tags
+func applyStar(tx *sql.Tx, articleID int64, sv starValue) error {
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO starred (article_id, word, dict_code) VALUES (?, ?, ?)
+	`, articleID, sv.Word, sv.DictCode)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM article_tags WHERE article_id = ?", articleID); err != nil {
+		return err
+	}
+	for _, tag := range sv.Tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO article_tags (article_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?
+		`, articleID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM article_tags)")
+	return err
+}
+
+// appendChanges adds changes to the end of a change log
+func appendChanges(path string, changes []change) error {
+	if len(changes) == 0 {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compactLog rewrites a device's change log with a header and the last
+// version of each item, once it has grown enough (see compactLines)
+func compactLog(path, device string, versions map[string]version) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := bytes.Count(data, []byte("\n"))
+	if lines <= compactLines || lines <= 2*len(versions) {
+		return nil
+	}
+
+	changes := []change{{Time: time.Now().UnixNano(), Device: device, Entity: logHeader}}
+	for _, entity := range unionKeys(nil, versions) {
+		v := versions[entity]
+		changes = append(changes, change{Time: v.Time, Device: v.Device, Entity: entity, Value: rawValue(v.Value)})
+	}
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := appendChanges(tmp, changes); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readChanges reads the complete lines of a change log from offset on and
+// returns them with the offset to continue from and the log's first line.
+// A log whose first line isn't head, or that is shorter than offset, was
+// replaced and is read from the start. Lines that do not parse, such as
+// ones cut short by a file sync in progress, end the read.
+func readChanges(path string, offset int64, head string) ([]change, int64, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, offset, head, err
+	}
+	first, _, complete := bytes.Cut(data, []byte("\n"))
+	if !complete {
+		first = nil
+	}
+	if string(first) != head || offset > int64(len(data)) {
+		offset = 0
+	}
+	head = string(first)
+
+	var changes []change
+	r := bufio.NewReader(bytes.NewReader(data[offset:]))
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break // Incomplete last line, read it next time
+		}
+		if err != nil {
+			return nil, offset, head, err
+		}
+		var c change
+		if json.Unmarshal(line, &c) != nil || c.Entity == "" {
+			break
+		}
+		if c.Entity != logHeader {
+			changes = append(changes, c)
+		}
+		offset += int64(len(line))
+	}
+	return changes, offset, head, nil
+}
+
+func entityKey(kind string, articleID int64) string {
+	return kind + ":" + strconv.FormatInt(articleID, 10)
+}
+
+// rawValue returns a stored value as JSON, nil for a deleted item
+func rawValue(value string) json.RawMessage {
+	if value == "" {
+		return nil
+	}
+	return json.RawMessage(value)
+}
+
+// unionKeys returns the items present locally or synced before, sorted
+func unionKeys(local map[string]string, versions map[string]version) []string {
+	keys := make([]string, 0, len(local))
+	for k := range local {
+		keys = append(keys, k)
+	}
+	for k := range versions {
+		if _, ok := local[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,189 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mustSync(t *testing.T, s *Store, dir string) SyncResult {
+	t.Helper()
+	result, err := s.Sync(dir)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	return result
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	laptop := createTestStore(t)
+	desktop := createTestStore(t)
+
+	laptopID, _ := laptop.DeviceID()
+	desktopID, _ := desktop.DeviceID()
+	if laptopID == "" || laptopID == desktopID {
+		t.Fatalf("device IDs = %q, %q", laptopID, desktopID)
+	}
+	if id, _ := laptop.DeviceID(); id != laptopID {
+		t.Errorf("DeviceID() changed from %q to %q", laptopID, id)
+	}
+
+	// Stars, tags and notes travel from one device to the other
+	laptop.StarArticle(1, "agni", "mw")
+	laptop.TagArticle(1, "Vedic gods")
+	laptop.SaveNote(2, "soma", "mw", "Pressed at the sacrifice")
+	if r := mustSync(t, laptop, dir); r.Sent != 3 || r.Received != 0 {
+		t.Errorf("laptop Sync() = %+v, want 3 sent", r)
+	}
+	if r := mustSync(t, desktop, dir); r.Sent != 0 || r.Received != 3 {
+		t.Errorf("desktop Sync() = %+v, want 3 received", r)
+	}
+	if !desktop.IsStarred(1) || !desktop.IsStarred(2) {
+		t.Error("stars were not synced")
+	}
+	if tags := desktop.ArticleTags(1); len(tags) != 1 || tags[0] != "Vedic gods" {
+		t.Errorf("tags = %v", tags)
+	}
+	if n, ok := desktop.GetNote(2); !ok || n.Text != "Pressed at the sacrifice" {
+		t.Errorf("note = %+v, %v", n, ok)
+	}
+
+	// Deletions travel too
+	desktop.UnstarArticle(1)
+	mustSync(t, desktop, dir)
+	mustSync(t, laptop, dir)
+	if laptop.IsStarred(1) {
+		t.Error("unstar was not synced")
+	}
+
+	// Conflicting edits: the later change wins on both devices
+	laptop.SaveNote(2, "soma", "mw", "laptop edit")
+	time.Sleep(5 * time.Millisecond)
+	desktop.SaveNote(2, "soma", "mw", "desktop edit")
+	mustSync(t, laptop, dir)
+	mustSync(t, desktop, dir)
+	mustSync(t, laptop, dir)
+	for name, s := range map[string]*Store{"laptop": laptop, "desktop": desktop} {
+		if n, _ := s.GetNote(2); n.Text != "desktop edit" {
+			t.Errorf("%s note = %q, want the later desktop edit", name, n.Text)
+		}
+	}
+
+	// Once in agreement nothing more is exchanged
+	for _, s := range []*Store{laptop, desktop} {
+		if r := mustSync(t, s, dir); r.Sent != 0 || r.Received != 0 {
+			t.Errorf("Sync() after agreement = %+v", r)
+		}
+	}
+}
+
+func TestSyncNewFolder(t *testing.T) {
+	laptop := createTestStore(t)
+	laptop.StarArticle(1, "agni", "mw")
+	laptop.SaveNote(1, "agni", "mw", "fire")
+	mustSync(t, laptop, t.TempDir())
+
+	// Moving to a new folder writes what the old one had
+	dir := t.TempDir()
+	if r := mustSync(t, laptop, dir); r.Sent != 0 {
+		t.Errorf("Sync() to new folder = %+v, want nothing new sent", r)
+	}
+	phone := createTestStore(t)
+	if r := mustSync(t, phone, dir); r.Received != 2 {
+		t.Errorf("Sync() from new folder = %+v, want 2 received", r)
+	}
+	if n, ok := phone.GetNote(1); !ok || n.Text != "fire" {
+		t.Errorf("note = %+v, %v", n, ok)
+	}
+}
+
+func TestSyncPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	laptop := createTestStore(t)
+	desktop := createTestStore(t)
+	laptop.StarArticle(1, "agni", "mw")
+	mustSync(t, laptop, dir)
+
+	// A line still being written by the file sync is read next time
+	id, _ := laptop.DeviceID()
+	path := filepath.Join(dir, id+syncLogExt)
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"time":1,"device":"` + id + `","entity":"star:2","val`)
+	f.Close()
+
+	if r := mustSync(t, desktop, dir); r.Received != 1 {
+		t.Errorf("Sync() = %+v, want 1 received", r)
+	}
+
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`ue":{"word":"soma","dict_code":"mw"}}` + "\n")
+	f.Close()
+	if r := mustSync(t, desktop, dir); r.Received != 1 || !desktop.IsStarred(2) {
+		t.Errorf("Sync() after completed line = %+v", r)
+	}
+}
+
+func TestSyncEditTime(t *testing.T) {
+	dir := t.TempDir()
+	laptop := createTestStore(t)
+	desktop := createTestStore(t)
+	laptop.SaveNote(1, "agni", "mw", "fire")
+	mustSync(t, laptop, dir)
+	mustSync(t, desktop, dir)
+
+	// The later edit wins even when the device that made the earlier one
+	// syncs last
+	laptop.SaveNote(1, "agni", "mw", "earlier laptop edit")
+	time.Sleep(5 * time.Millisecond)
+	desktop.SaveNote(1, "agni", "mw", "later desktop edit")
+	mustSync(t, desktop, dir)
+	mustSync(t, laptop, dir)
+	mustSync(t, desktop, dir)
+	for name, s := range map[string]*Store{"laptop": laptop, "desktop": desktop} {
+		if n, _ := s.GetNote(1); n.Text != "later desktop edit" {
+			t.Errorf("%s note = %q, want the later desktop edit", name, n.Text)
+		}
+	}
+}
+
+func TestSyncCompaction(t *testing.T) {
+	dir := t.TempDir()
+	laptop := createTestStore(t)
+	desktop := createTestStore(t)
+	laptop.StarArticle(1, "agni", "mw")
+	mustSync(t, laptop, dir)
+	mustSync(t, desktop, dir)
+
+	// A long history of changes superseded since
+	id, _ := laptop.DeviceID()
+	path := filepath.Join(dir, id+syncLogExt)
+	var history []change
+	for i := range compactLines {
+		history = append(history, change{Time: int64(i + 1), Device: id, Entity: "note:9", Value: json.RawMessage(`{"word":"x","dict_code":"mw","text":"draft"}`)})
+	}
+	if err := appendChanges(path, history); err != nil {
+		t.Fatal(err)
+	}
+
+	laptop.StarArticle(2, "soma", "mw")
+	mustSync(t, laptop, dir)
+	data, _ := os.ReadFile(path)
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("compacted log has %d lines, want a header and 2 items", lines)
+	}
+
+	// Other devices read the compacted log from the start
+	if r := mustSync(t, desktop, dir); r.Received != 1 || !desktop.IsStarred(2) {
+		t.Errorf("Sync() after compaction = %+v, want soma received", r)
+	}
+	if _, ok := desktop.GetNote(9); ok {
+		t.Error("a superseded change was applied")
+	}
+	if r := mustSync(t, desktop, dir); r.Received != 0 {
+		t.Errorf("Sync() again = %+v, want nothing received", r)
+	}
+}
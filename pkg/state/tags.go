@@ -0,0 +1,381 @@
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrEmptyName is returned when a tag or collection name is blank.
+var ErrEmptyName = errors.New("name cannot be empty")
+
+// ErrNameTaken is returned when renaming a collection to a name in use.
+var ErrNameTaken = errors.New("a collection with this name already exists")
+
+// Tag is a tag and the number of starred articles carrying it.
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// Collection is a named collection and the number of articles in it.
+type Collection struct {
+	Name  string
+	Count int
+}
+
+// cleanName trims a tag or collection name
+func cleanName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrEmptyName
+	}
+	return name, nil
+}
+
+// queryStarred runs a query returning article_id, word, dict_code rows
+func (s *Store) queryStarred(query string, args ...interface{}) []StarredArticle {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var results []StarredArticle
+	for rows.Next() {
+		var sa StarredArticle
+		if err := rows.Scan(&sa.ArticleID, &sa.Word, &sa.DictCode); err == nil {
+			results = append(results, sa)
+		}
+	}
+	return results
+}
+
+// TagArticle adds a tag to a starred article, creating the tag if needed.
+// Tag names are case-insensitive; the first spelling used is kept.
+func (s *Store) TagArticle(articleID int64, tag string) error {
+	tag, err := cleanName(tag)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO article_tags (article_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?
+	`, articleID, tag)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UntagArticle removes a tag from an article. Tags left without articles
+// are deleted.
+func (s *Store) UntagArticle(articleID int64, tag string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM article_tags
+		WHERE article_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)
+	`, articleID, strings.TrimSpace(tag))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM article_tags)"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ArticleTags returns the tags of an article in alphabetical order.
+func (s *Store) ArticleTags(articleID int64) []string {
+	rows, err := s.db.Query(`
+		SELECT t.name FROM article_tags at
+		JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id = ?
+		ORDER BY t.name
+	`, articleID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// TaggedArticles returns the tags of every tagged starred article,
+// keyed by article ID, each list in alphabetical order.
+func (s *Store) TaggedArticles() map[int64][]string {
+	rows, err := s.db.Query(`
+		SELECT at.article_id, t.name FROM article_tags at
+		JOIN tags t ON t.id = at.tag_id
+		JOIN starred s ON s.article_id = at.article_id
+		ORDER BY t.name
+	`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err == nil {
+			tags[id] = append(tags[id], name)
+		}
+	}
+	return tags
+}
+
+// Tags returns all tags with their article counts, in alphabetical order.
+func (s *Store) Tags() []Tag {
+	rows, err := s.db.Query(`
+		SELECT t.name, COUNT(s.article_id) FROM tags t
+		JOIN article_tags at ON at.tag_id = t.id
+		JOIN starred s ON s.article_id = at.article_id
+		GROUP BY t.id
+		ORDER BY t.name
+	`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.Name, &t.Count); err == nil {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// ArticlesByTag returns the starred articles carrying a tag, newest first.
+func (s *Store) ArticlesByTag(tag string) []StarredArticle {
+	return s.queryStarred(`
+		SELECT s.article_id, s.word, s.dict_code FROM starred s
+		JOIN article_tags at ON at.article_id = s.article_id
+		JOIN tags t ON t.id = at.tag_id
+		WHERE t.name = ?
+		ORDER BY s.created_at DESC
+	`, strings.TrimSpace(tag))
+}
+
+// RenameTag renames a tag. If a tag with the new name already exists the
+// two are merged: articles of the old tag get the existing one.
+func (s *Store) RenameTag(oldName, newName string) error {
+	newName, err := cleanName(newName)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldID, newID int64
+	if err := tx.QueryRow("SELECT id FROM tags WHERE name = ?", strings.TrimSpace(oldName)).Scan(&oldID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	err = tx.QueryRow("SELECT id FROM tags WHERE name = ?", newName).Scan(&newID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || newID == oldID:
+		// Plain rename, possibly only changing case
+		if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", newName, oldID); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		// Merge into the existing tag
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO article_tags (article_id, tag_id)
+			SELECT article_id, ? FROM article_tags WHERE tag_id = ?
+		`, newID, oldID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM article_tags WHERE tag_id = ?", oldID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", oldID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTag removes a tag from all articles.
+func (s *Store) DeleteTag(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	name = strings.TrimSpace(name)
+	if _, err := tx.Exec("DELETE FROM article_tags WHERE tag_id = (SELECT id FROM tags WHERE name = ?)", name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE name = ?", name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateCollection creates an empty collection. Creating an existing
+// collection is not an error.
+func (s *Store) CreateCollection(name string) error {
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("INSERT OR IGNORE INTO collections (name) VALUES (?)", name)
+	return err
+}
+
+// RenameCollection renames a collection. It returns ErrNameTaken if
+// another collection already has the new name.
+func (s *Store) RenameCollection(oldName, newName string) error {
+	newName, err := cleanName(newName)
+	if err != nil {
+		return err
+	}
+	oldName = strings.TrimSpace(oldName)
+	if !strings.EqualFold(oldName, newName) {
+		var n int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM collections WHERE name = ?", newName).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrNameTaken
+		}
+	}
+	_, err = s.db.Exec("UPDATE collections SET name = ? WHERE name = ?", newName, oldName)
+	return err
+}
+
+// DeleteCollection deletes a collection. Its articles stay starred.
+func (s *Store) DeleteCollection(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	name = strings.TrimSpace(name)
+	if _, err := tx.Exec("DELETE FROM collection_articles WHERE collection_id = (SELECT id FROM collections WHERE name = ?)", name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM collections WHERE name = ?", name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Collections returns all collections with their article counts, in
+// alphabetical order.
+func (s *Store) Collections() []Collection {
+	rows, err := s.db.Query(`
+		SELECT c.name, COUNT(s.article_id) FROM collections c
+		LEFT JOIN collection_articles ca ON ca.collection_id = c.id
+		LEFT JOIN starred s ON s.article_id = ca.article_id
+		GROUP BY c.id
+		ORDER BY c.name
+	`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.Name, &c.Count); err == nil {
+			collections = append(collections, c)
+		}
+	}
+	return collections
+}
+
+// AddToCollection appends a starred article to a collection, creating the
+// collection if needed. Adding an article twice keeps its first position.
+func (s *Store) AddToCollection(name string, articleID int64) error {
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT OR IGNORE INTO collections (name) VALUES (?)", name); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO collection_articles (collection_id, article_id, position)
+		SELECT c.id, ?, COALESCE((SELECT MAX(position) + 1 FROM collection_articles WHERE collection_id = c.id), 0)
+		FROM collections c WHERE c.name = ?
+	`, articleID, name)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveFromCollection removes an article from a collection.
+func (s *Store) RemoveFromCollection(name string, articleID int64) error {
+	_, err := s.db.Exec(`
+		DELETE FROM collection_articles
+		WHERE article_id = ? AND collection_id = (SELECT id FROM collections WHERE name = ?)
+	`, articleID, strings.TrimSpace(name))
+	return err
+}
+
+// CollectionArticles returns the starred articles of a collection in the
+// order they were added.
+func (s *Store) CollectionArticles(name string) []StarredArticle {
+	return s.queryStarred(`
+		SELECT s.article_id, s.word, s.dict_code FROM collection_articles ca
+		JOIN collections c ON c.id = ca.collection_id
+		JOIN starred s ON s.article_id = ca.article_id
+		WHERE c.name = ?
+		ORDER BY ca.position
+	`, strings.TrimSpace(name))
+}
@@ -0,0 +1,147 @@
+package state
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func starredIDs(articles []StarredArticle) []int64 {
+	var ids []int64
+	for _, a := range articles {
+		ids = append(ids, a.ArticleID)
+	}
+	return ids
+}
+
+func TestTagArticle(t *testing.T) {
+	store := createTestStore(t)
+	store.StarArticle(1, "dharma", "mw")
+	store.StarArticle(2, "karma", "mw")
+
+	for _, tt := range []struct {
+		id  int64
+		tag string
+	}{{1, "ethics"}, {1, "Veda"}, {2, "ethics"}, {2, " ETHICS "}} {
+		if err := store.TagArticle(tt.id, tt.tag); err != nil {
+			t.Fatalf("TagArticle(%d, %q) error = %v", tt.id, tt.tag, err)
+		}
+	}
+	if err := store.TagArticle(1, "  "); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("TagArticle() with blank tag error = %v, want ErrEmptyName", err)
+	}
+
+	if got := store.ArticleTags(1); !reflect.DeepEqual(got, []string{"ethics", "Veda"}) {
+		t.Errorf("ArticleTags(1) = %v", got)
+	}
+	if got := store.Tags(); !reflect.DeepEqual(got, []Tag{{"ethics", 2}, {"Veda", 1}}) {
+		t.Errorf("Tags() = %v", got)
+	}
+	if got := starredIDs(store.ArticlesByTag("Ethics")); len(got) != 2 {
+		t.Errorf("ArticlesByTag(Ethics) = %v, want both articles", got)
+	}
+	if got := store.TaggedArticles(); len(got) != 2 || len(got[1]) != 2 {
+		t.Errorf("TaggedArticles() = %v", got)
+	}
+
+	// Removing the last use of a tag deletes it
+	if err := store.UntagArticle(1, "veda"); err != nil {
+		t.Fatalf("UntagArticle() error = %v", err)
+	}
+	if got := store.Tags(); len(got) != 1 {
+		t.Errorf("Tags() after untag = %v", got)
+	}
+
+	// Unstarring drops the article's tags
+	store.UnstarArticle(2)
+	if got := store.ArticlesByTag("ethics"); !reflect.DeepEqual(starredIDs(got), []int64{1}) {
+		t.Errorf("ArticlesByTag() after unstar = %v", got)
+	}
+	if got := store.ArticleTags(2); len(got) != 0 {
+		t.Errorf("ArticleTags(2) after unstar = %v", got)
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	store := createTestStore(t)
+	store.StarArticle(1, "dharma", "mw")
+	store.StarArticle(2, "karma", "mw")
+	store.TagArticle(1, "ethic")
+	store.TagArticle(1, "ethics")
+	store.TagArticle(2, "ethic")
+
+	// Plain rename
+	if err := store.RenameTag("ethic", "morals"); err != nil {
+		t.Fatalf("RenameTag() error = %v", err)
+	}
+	if got := store.Tags(); !reflect.DeepEqual(got, []Tag{{"ethics", 1}, {"morals", 2}}) {
+		t.Errorf("Tags() after rename = %v", got)
+	}
+
+	// Renaming onto an existing tag merges them
+	if err := store.RenameTag("morals", "Ethics"); err != nil {
+		t.Fatalf("RenameTag() merge error = %v", err)
+	}
+	if got := store.Tags(); !reflect.DeepEqual(got, []Tag{{"ethics", 2}}) {
+		t.Errorf("Tags() after merge = %v", got)
+	}
+
+	if err := store.RenameTag("missing", "x"); err != nil {
+		t.Errorf("RenameTag() of missing tag error = %v", err)
+	}
+	if err := store.DeleteTag("ethics"); err != nil {
+		t.Fatalf("DeleteTag() error = %v", err)
+	}
+	if got := store.ArticleTags(1); len(got) != 0 {
+		t.Errorf("ArticleTags(1) after delete = %v", got)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	store := createTestStore(t)
+	store.StarArticle(1, "dharma", "mw")
+	store.StarArticle(2, "karma", "mw")
+	store.StarArticle(3, "yoga", "ap90")
+
+	if err := store.CreateCollection("Gītā ch. 2"); err != nil {
+		t.Fatalf("CreateCollection() error = %v", err)
+	}
+	for _, id := range []int64{3, 1, 3} {
+		if err := store.AddToCollection("Gītā ch. 2", id); err != nil {
+			t.Fatalf("AddToCollection() error = %v", err)
+		}
+	}
+	store.AddToCollection("Reading", 2)
+
+	if got := starredIDs(store.CollectionArticles("gītā ch. 2")); !reflect.DeepEqual(got, []int64{3, 1}) {
+		t.Errorf("CollectionArticles() = %v, want [3 1]", got)
+	}
+	if got := store.Collections(); !reflect.DeepEqual(got, []Collection{{"Gītā ch. 2", 2}, {"Reading", 1}}) {
+		t.Errorf("Collections() = %v", got)
+	}
+
+	if err := store.RenameCollection("Reading", "Gītā ch. 2"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("RenameCollection() onto existing error = %v, want ErrNameTaken", err)
+	}
+	if err := store.RenameCollection("Reading", "To read"); err != nil {
+		t.Fatalf("RenameCollection() error = %v", err)
+	}
+	if got := starredIDs(store.CollectionArticles("To read")); !reflect.DeepEqual(got, []int64{2}) {
+		t.Errorf("CollectionArticles() after rename = %v", got)
+	}
+
+	store.RemoveFromCollection("Gītā ch. 2", 3)
+	if got := starredIDs(store.CollectionArticles("Gītā ch. 2")); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("CollectionArticles() after remove = %v", got)
+	}
+
+	if err := store.DeleteCollection("To read"); err != nil {
+		t.Fatalf("DeleteCollection() error = %v", err)
+	}
+	if !store.IsStarred(2) {
+		t.Error("deleting a collection unstarred its article")
+	}
+	if got := store.Collections(); len(got) != 1 {
+		t.Errorf("Collections() after delete = %v", got)
+	}
+}
@@ -0,0 +1,25 @@
+-- Schema of the first release: settings, history and starred articles.
+-- Databases of this age have no schema version (user_version 0).
+
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	query TEXT NOT NULL UNIQUE,
+	count INTEGER DEFAULT 1,
+	last_used DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE starred (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	article_id INTEGER NOT NULL UNIQUE,
+	word TEXT NOT NULL,
+	dict_code TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO settings (key, value) VALUES ('zoom', '120');
+INSERT INTO settings (key, value) VALUES ('editor_content', 'agnim īḷe');
+INSERT INTO history (query, count, last_used) VALUES ('agni', 3, '2024-01-05 10:00:00');
+INSERT INTO starred (article_id, word, dict_code, created_at) VALUES (1, 'agni', 'mw', '2024-01-05 10:01:00');
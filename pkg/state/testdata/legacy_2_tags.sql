@@ -0,0 +1,57 @@
+-- Adds tags and collections of starred articles.
+-- Databases of this age have no schema version (user_version 0).
+
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	query TEXT NOT NULL UNIQUE,
+	count INTEGER DEFAULT 1,
+	last_used DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE starred (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	article_id INTEGER NOT NULL UNIQUE,
+	word TEXT NOT NULL,
+	dict_code TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE navigation (
+	position INTEGER PRIMARY KEY,
+	query TEXT NOT NULL,
+	mode TEXT NOT NULL,
+	article_id INTEGER NOT NULL DEFAULT 0,
+	current INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE
+);
+CREATE TABLE article_tags (
+	article_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (article_id, tag_id)
+);
+CREATE TABLE collections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE collection_articles (
+	collection_id INTEGER NOT NULL,
+	article_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (collection_id, article_id)
+);
+
+INSERT INTO settings (key, value) VALUES ('zoom', '120');
+INSERT INTO settings (key, value) VALUES ('editor_content', 'agnim īḷe');
+INSERT INTO history (query, count, last_used) VALUES ('agni', 3, '2024-01-05 10:00:00');
+INSERT INTO starred (article_id, word, dict_code, created_at) VALUES (1, 'agni', 'mw', '2024-01-05 10:01:00');
+INSERT INTO navigation (position, query, mode, article_id, current) VALUES (0, 'agni', 'Exact', 1, 1);
+INSERT INTO tags (id, name) VALUES (1, 'Vedic gods');
+INSERT INTO article_tags (article_id, tag_id) VALUES (1, 1);
+INSERT INTO collections (id, name) VALUES (1, 'Lesson 1');
+INSERT INTO collection_articles (collection_id, article_id, position) VALUES (1, 1, 0);
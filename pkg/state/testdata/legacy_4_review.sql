@@ -0,0 +1,102 @@
+-- Adds review cards and the review log.
+-- Databases of this age have no schema version (user_version 0).
+
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	query TEXT NOT NULL UNIQUE,
+	count INTEGER DEFAULT 1,
+	last_used DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE starred (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	article_id INTEGER NOT NULL UNIQUE,
+	word TEXT NOT NULL,
+	dict_code TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE navigation (
+	position INTEGER PRIMARY KEY,
+	query TEXT NOT NULL,
+	mode TEXT NOT NULL,
+	article_id INTEGER NOT NULL DEFAULT 0,
+	current INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE
+);
+CREATE TABLE article_tags (
+	article_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (article_id, tag_id)
+);
+CREATE TABLE collections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE collection_articles (
+	collection_id INTEGER NOT NULL,
+	article_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (collection_id, article_id)
+);
+CREATE TABLE notes (
+	article_id INTEGER PRIMARY KEY,
+	word TEXT NOT NULL,
+	dict_code TEXT NOT NULL,
+	text TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE VIRTUAL TABLE notes_fts USING fts5(
+	text,
+	content='notes',
+	content_rowid='article_id',
+	tokenize='unicode61 remove_diacritics 0'
+);
+CREATE TRIGGER notes_ai AFTER INSERT ON notes BEGIN
+	INSERT INTO notes_fts(rowid, text) VALUES (new.article_id, new.text);
+END;
+CREATE TRIGGER notes_ad AFTER DELETE ON notes BEGIN
+	INSERT INTO notes_fts(notes_fts, rowid, text) VALUES ('delete', old.article_id, old.text);
+END;
+CREATE TRIGGER notes_au AFTER UPDATE ON notes BEGIN
+	INSERT INTO notes_fts(notes_fts, rowid, text) VALUES ('delete', old.article_id, old.text);
+	INSERT INTO notes_fts(rowid, text) VALUES (new.article_id, new.text);
+END;
+CREATE TABLE cards (
+	article_id INTEGER PRIMARY KEY,
+	ease REAL NOT NULL DEFAULT 2.5,
+	interval_days INTEGER NOT NULL DEFAULT 0,
+	repetitions INTEGER NOT NULL DEFAULT 0,
+	lapses INTEGER NOT NULL DEFAULT 0,
+	due INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE TABLE review_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	article_id INTEGER NOT NULL,
+	grade INTEGER NOT NULL,
+	interval_days INTEGER NOT NULL,
+	ease REAL NOT NULL,
+	reviewed_at INTEGER NOT NULL
+);
+CREATE INDEX idx_review_log_article ON review_log(article_id);
+
+INSERT INTO settings (key, value) VALUES ('zoom', '120');
+INSERT INTO settings (key, value) VALUES ('editor_content', 'agnim īḷe');
+INSERT INTO history (query, count, last_used) VALUES ('agni', 3, '2024-01-05 10:00:00');
+INSERT INTO starred (article_id, word, dict_code, created_at) VALUES (1, 'agni', 'mw', '2024-01-05 10:01:00');
+INSERT INTO navigation (position, query, mode, article_id, current) VALUES (0, 'agni', 'Exact', 1, 1);
+INSERT INTO tags (id, name) VALUES (1, 'Vedic gods');
+INSERT INTO article_tags (article_id, tag_id) VALUES (1, 1);
+INSERT INTO collections (id, name) VALUES (1, 'Lesson 1');
+INSERT INTO collection_articles (collection_id, article_id, position) VALUES (1, 1, 0);
+INSERT INTO notes (article_id, word, dict_code, text) VALUES (1, 'agni', 'mw', 'The sacrificial fire');
+INSERT INTO cards (article_id, ease, interval_days, repetitions, lapses, due, created_at) VALUES (1, 2.5, 1, 1, 0, 1704535200, 1704448800);
+INSERT INTO review_log (article_id, grade, interval_days, ease, reviewed_at) VALUES (1, 4, 1, 2.5, 1704448800);
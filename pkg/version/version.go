@@ -105,3 +105,19 @@ func parseVersion(v string) []int {
 
 	return result
 }
+
+// IsNewer reports whether version latest is newer than current. Versions
+// are dot-separated numbers with an optional "v" prefix ("v1.2.3", or
+// dates like "2025.06.01" for dictionary data).
+func IsNewer(latest, current string) bool {
+	return isNewer(latest, current)
+}
+
+// AtLeast reports whether version v satisfies a minimum version. Dev builds
+// and an empty minimum satisfy any minimum.
+func AtLeast(v, minimum string) bool {
+	if v == "dev" || v == "" || minimum == "" {
+		return true
+	}
+	return !isNewer(minimum, v)
+}
@@ -61,3 +61,22 @@ func TestParseVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestAtLeast(t *testing.T) {
+	tests := []struct {
+		v, minimum string
+		expected   bool
+	}{
+		{"v1.2.0", "v1.2.0", true},
+		{"v1.3.0", "v1.2.0", true},
+		{"v1.1.9", "v1.2.0", false},
+		{"v1.1.9", "", true},
+		{"dev", "v9.0.0", true},
+	}
+
+	for _, tt := range tests {
+		if got := AtLeast(tt.v, tt.minimum); got != tt.expected {
+			t.Errorf("AtLeast(%q, %q) = %v, want %v", tt.v, tt.minimum, got, tt.expected)
+		}
+	}
+}